@@ -3,6 +3,7 @@ package moqtransport
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -55,7 +56,8 @@ type Stream interface {
 // ReceiveStream is the interface implemented by the receiving end of unidirectional
 // streams.
 type ReceiveStream interface {
-	// Read reads from the stream.
+	// Read reads from the stream. If the peer reset the stream, Read returns
+	// a *StreamResetError.
 	io.Reader
 
 	// Stop stops reading from the stream and sends a signal to the sender to
@@ -83,6 +85,36 @@ type SendStream interface {
 
 var ErrDatagramSupportDisabled = errors.New("datagram support disabled")
 
+// StreamResetError is returned by ReceiveStream.Read if the peer reset the
+// stream.
+type StreamResetError struct {
+	// ErrorCode is the error code sent by the peer.
+	ErrorCode uint64
+
+	// Err is the error returned by the underlying stream.
+	Err error
+}
+
+func (e *StreamResetError) Error() string {
+	return fmt.Sprintf("stream reset by peer, error code: %v", e.ErrorCode)
+}
+
+func (e *StreamResetError) Unwrap() error {
+	return e.Err
+}
+
+// DatagramTooLargeError is returned by Connection.SendDatagram if the datagram
+// is larger than the connection can currently send.
+type DatagramTooLargeError struct {
+	// MaxDatagramSize is the maximum size of a datagram the connection can
+	// currently send.
+	MaxDatagramSize int64
+}
+
+func (e *DatagramTooLargeError) Error() string {
+	return fmt.Sprintf("datagram too large, max datagram size: %v", e.MaxDatagramSize)
+}
+
 // Connection is the interface of a QUIC/WebTransport connection. New Transports
 // expect an implementation of this interface as the underlying connection.
 // Implementations based on quic-go and webtransport-go are provided in quicmoq
@@ -110,11 +142,12 @@ type Connection interface {
 	// opened.
 	OpenUniStreamSync(context.Context) (SendStream, error)
 
-	// SendDatagram sends a datagram.
+	// SendDatagram sends a datagram. It returns a *DatagramTooLargeError if
+	// the datagram is too large to be sent.
 	SendDatagram([]byte) error
 
 	// ReceiveDatagram receives the next datagram, blocking until one is
-	// available.
+	// available. The caller takes ownership of the returned slice.
 	ReceiveDatagram(context.Context) ([]byte, error)
 
 	// CloseWithError closes the connection with an error code and a reason
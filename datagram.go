@@ -0,0 +1,14 @@
+package moqtransport
+
+// DatagramOption is a functional option for sending objects with
+// SendDatagram.
+type DatagramOption func(*DatagramOptions)
+
+// WithDatagramStreamFallback sends objects that are too large for a datagram
+// on a new subgroup stream containing only that object instead of returning a
+// *DatagramTooLargeError. The subgroup ID of the stream is the object ID.
+func WithDatagramStreamFallback() DatagramOption {
+	return func(opts *DatagramOptions) {
+		opts.StreamFallback = true
+	}
+}
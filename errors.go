@@ -47,6 +47,14 @@ const (
 	ErrorCodeSubscribeDoneTooFarBehind      uint64 = 0x06
 )
 
+// Data stream reset error codes
+const (
+	ErrorCodeStreamInternal        uint64 = 0x00
+	ErrorCodeStreamCancelled       uint64 = 0x01
+	ErrorCodeStreamDeliveryTimeout uint64 = 0x02
+	ErrorCodeStreamSessionClosed   uint64 = 0x03
+)
+
 // Fetch error codes
 const (
 	ErrorCodeFetchInternal                  uint64 = 0x00
@@ -54,6 +62,7 @@ const (
 	ErrorCodeFetchTimeout                   uint64 = 0x02
 	ErrorCodeFetchNotSupported              uint64 = 0x03
 	ErrorCodeFetchTrackDoesNotExist         uint64 = 0x04
+	ErrorCodeFetchInvalidRange              uint64 = 0x05
 	ErrorCodeFetchNoObjects                 uint64 = 0x06
 	ErrorCodeFetchInvalidJoiningSubscribeID uint64 = 0x07
 	ErrorCodeFetchMalformedAuthToken        uint64 = 0x10
@@ -7,7 +7,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -29,9 +28,7 @@ type moqHandler struct {
 	publish       bool
 	subscribe     bool
 	nextSessionID atomic.Uint64
-	publishers    map[moqtransport.Publisher]struct{}
-	lock          sync.Mutex
-	largestGroup  atomic.Uint64
+	track         *moqtransport.TrackWriter
 }
 
 func (h *moqHandler) runClient(ctx context.Context, wt bool) error {
@@ -128,26 +125,11 @@ func (h *moqHandler) getSubscribeHandler(sessionID uint64) moqtransport.Subscrib
 			w.Reject(moqtransport.ErrorCodeSubscribeTrackDoesNotExist, "unknown track")
 			return
 		}
-		largestGroup := h.largestGroup.Load()
-		err := w.Accept(moqtransport.WithLargestLocation(
-			&moqtransport.Location{
-				Group:  largestGroup,
-				Object: 0,
-			}))
-		if err != nil {
+		if err := h.track.Accept(w); err != nil {
 			log.Printf("failed to accept subscription: %v", err)
 			return
 		}
-		log.Printf("sessionNr: %d accepted subscription for namespace %v track %v", sessionID, m.Namespace, m.Track)
-		datePublisher := &publisher{
-			p:           w,
-			sessionID:   sessionID,
-			subscribeID: m.RequestID,
-			trackAlias:  m.TrackAlias,
-		}
-		h.lock.Lock()
-		h.publishers[datePublisher] = struct{}{}
-		h.lock.Unlock()
+		log.Printf("sessionNr: %d accepted subscription for namespace %v track %v with requestID %d and trackAlias %d", sessionID, m.Namespace, m.Track, m.RequestID, m.TrackAlias)
 	})
 }
 
@@ -209,33 +191,15 @@ func (h *moqHandler) subscribeAndRead(s *moqtransport.Session, namespace []strin
 
 func (h *moqHandler) setupDateTrack() {
 	ticker := time.NewTicker(time.Second)
-	groupID := 0
 	for ts := range ticker.C {
-		h.lock.Lock()
-		for p := range h.publishers {
-			sg, err := p.OpenSubgroup(uint64(groupID), 0, 0)
-			if err != nil {
-				log.Printf("failed to open new subgroup: %v", err)
-				p.CloseWithError(moqtransport.ErrorCodeSubscribeDoneSubscriptionEnded, "")
-				delete(h.publishers, p)
-				continue
-			}
-			if _, err := sg.WriteObject(0, []byte(fmt.Sprintf("%v", ts))); err != nil {
-				log.Printf("failed to write time to subgroup: %v", err)
-			}
-			sg.Close()
-			// if err := p.SendDatagram(moqtransport.Object{
-			// 	GroupID:    uint64(groupID),
-			// 	SubGroupID: 0,
-			// 	ObjectID:   0,
-			// 	Payload:    []byte(fmt.Sprintf("%v", ts)),
-			// }); err != nil {
-			// 	log.Printf("failed to write time to publisher: %v", err)
-			// }
+		if _, err := h.track.WriteObject([]byte(fmt.Sprintf("%v", ts))); err != nil {
+			log.Printf("failed to write time to track: %v", err)
+			return
+		}
+		if err := h.track.NewGroup(); err != nil {
+			log.Printf("failed to start new group: %v", err)
+			return
 		}
-		h.lock.Unlock()
-		h.largestGroup.Store(uint64(groupID))
-		groupID++
 	}
 }
 
@@ -98,29 +98,29 @@ func runServer(opts *options) error {
 		}
 	}
 	h := &moqHandler{
-		server:     true,
-		addr:       opts.addr,
-		tlsConfig:  tlsConfig,
-		namespace:  []string{opts.namespace},
-		trackname:  opts.trackname,
-		publish:    opts.publish,
-		subscribe:  opts.subscribe,
-		publishers: make(map[moqtransport.Publisher]struct{}),
+		server:    true,
+		addr:      opts.addr,
+		tlsConfig: tlsConfig,
+		namespace: []string{opts.namespace},
+		trackname: opts.trackname,
+		publish:   opts.publish,
+		subscribe: opts.subscribe,
+		track:     moqtransport.NewTrackWriter(),
 	}
 	return h.runServer(context.TODO())
 }
 
 func runClient(opts *options) error {
 	h := &moqHandler{
-		server:     false,
-		quic:       !opts.webtransport,
-		addr:       opts.addr,
-		tlsConfig:  nil,
-		namespace:  []string{opts.namespace},
-		trackname:  opts.trackname,
-		publish:    opts.publish,
-		subscribe:  opts.subscribe,
-		publishers: make(map[moqtransport.Publisher]struct{}),
+		server:    false,
+		quic:      !opts.webtransport,
+		addr:      opts.addr,
+		tlsConfig: nil,
+		namespace: []string{opts.namespace},
+		trackname: opts.trackname,
+		publish:   opts.publish,
+		subscribe: opts.subscribe,
+		track:     moqtransport.NewTrackWriter(),
 	}
 	return h.runClient(context.TODO(), opts.webtransport)
 }
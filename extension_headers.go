@@ -0,0 +1,246 @@
+package moqtransport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	errExtensionHeaderParity      = errors.New("extension header type parity does not match value encoding")
+	errDuplicateExtensionHeader   = errors.New("duplicate extension header type")
+	errInvalidExtensionHeaderType = errors.New("invalid extension header type")
+)
+
+// ExtensionHeader describes a known object extension header type. Even types
+// carry a varint value, odd types carry a byte value.
+type ExtensionHeader interface {
+	// Type returns the extension header type.
+	Type() uint64
+
+	// Name returns a human readable name of the extension header.
+	Name() string
+
+	// Validate returns an error if kvp is not a valid value for the header.
+	Validate(kvp KeyValuePair) error
+}
+
+// TypedExtensionHeader is an ExtensionHeader that encodes and decodes values
+// of type T. Publishers and subscribers using the same TypedExtensionHeader
+// encode and decode the header consistently.
+type TypedExtensionHeader[T any] struct {
+	typ    uint64
+	name   string
+	encode func(T) KeyValuePair
+	decode func(KeyValuePair) (T, error)
+}
+
+// NewVarIntExtensionHeader returns a TypedExtensionHeader for an extension
+// header carrying a varint value. typ must be even. It panics otherwise.
+func NewVarIntExtensionHeader[T any](typ uint64, name string, encode func(T) uint64, decode func(uint64) (T, error)) *TypedExtensionHeader[T] {
+	if typ%2 != 0 {
+		panic(fmt.Sprintf("%v: %v is odd", errExtensionHeaderParity, typ))
+	}
+	return &TypedExtensionHeader[T]{
+		typ:  typ,
+		name: name,
+		encode: func(v T) KeyValuePair {
+			return KeyValuePair{
+				Type:        typ,
+				ValueVarInt: encode(v),
+			}
+		},
+		decode: func(kvp KeyValuePair) (T, error) {
+			return decode(kvp.ValueVarInt)
+		},
+	}
+}
+
+// NewBytesExtensionHeader returns a TypedExtensionHeader for an extension
+// header carrying a byte value. typ must be odd. It panics otherwise.
+func NewBytesExtensionHeader[T any](typ uint64, name string, encode func(T) []byte, decode func([]byte) (T, error)) *TypedExtensionHeader[T] {
+	if typ%2 != 1 {
+		panic(fmt.Sprintf("%v: %v is even", errExtensionHeaderParity, typ))
+	}
+	return &TypedExtensionHeader[T]{
+		typ:  typ,
+		name: name,
+		encode: func(v T) KeyValuePair {
+			return KeyValuePair{
+				Type:       typ,
+				ValueBytes: encode(v),
+			}
+		},
+		decode: func(kvp KeyValuePair) (T, error) {
+			return decode(kvp.ValueBytes)
+		},
+	}
+}
+
+// Type implements ExtensionHeader.
+func (h *TypedExtensionHeader[T]) Type() uint64 {
+	return h.typ
+}
+
+// Name implements ExtensionHeader.
+func (h *TypedExtensionHeader[T]) Name() string {
+	return h.name
+}
+
+// Validate implements ExtensionHeader.
+func (h *TypedExtensionHeader[T]) Validate(kvp KeyValuePair) error {
+	_, err := h.Decode(kvp)
+	return err
+}
+
+// Encode encodes v as a key-value pair.
+func (h *TypedExtensionHeader[T]) Encode(v T) KeyValuePair {
+	return h.encode(v)
+}
+
+// Decode decodes the value of kvp.
+func (h *TypedExtensionHeader[T]) Decode(kvp KeyValuePair) (T, error) {
+	if kvp.Type != h.typ {
+		var zero T
+		return zero, fmt.Errorf("%w: got %v, expected %v", errInvalidExtensionHeaderType, kvp.Type, h.typ)
+	}
+	return h.decode(kvp)
+}
+
+// Get decodes the first header of type h in headers. It returns false if
+// headers doesn't contain the header.
+func (h *TypedExtensionHeader[T]) Get(headers KVPList) (T, bool, error) {
+	kvp, ok := headers.GetParameter(h.typ)
+	if !ok {
+		var zero T
+		return zero, false, nil
+	}
+	v, err := h.Decode(kvp)
+	return v, true, err
+}
+
+// Set encodes v and returns headers with the header set to v. An existing
+// header of the same type is replaced.
+func (h *TypedExtensionHeader[T]) Set(headers KVPList, v T) KVPList {
+	kvp := h.encode(v)
+	for i, e := range headers {
+		if e.Type == h.typ {
+			headers[i] = kvp
+			return headers
+		}
+	}
+	return append(headers, kvp)
+}
+
+// Extension headers defined by the LOC container format
+// (draft-ietf-moq-loc).
+var (
+	// CaptureTimestampExtension carries the wall clock time at which the
+	// media in the object was captured, encoded in microseconds since the Unix
+	// epoch.
+	CaptureTimestampExtension = NewVarIntExtensionHeader(0x02, "capture_timestamp",
+		func(t time.Time) uint64 {
+			return uint64(t.UnixMicro())
+		},
+		func(v uint64) (time.Time, error) {
+			return time.UnixMicro(int64(v)), nil
+		},
+	)
+
+	// VideoFrameMarkingExtension carries the video frame marking flags.
+	VideoFrameMarkingExtension = NewVarIntExtensionHeader(0x04, "video_frame_marking",
+		func(v uint64) uint64 {
+			return v
+		},
+		func(v uint64) (uint64, error) {
+			return v, nil
+		},
+	)
+
+	// AudioLevelExtension carries the audio level of the object.
+	AudioLevelExtension = NewVarIntExtensionHeader(0x06, "audio_level",
+		func(v uint64) uint64 {
+			return v
+		},
+		func(v uint64) (uint64, error) {
+			return v, nil
+		},
+	)
+
+	// VideoConfigExtension carries codec specific video configuration, e.g. an
+	// AVC decoder configuration record.
+	VideoConfigExtension = NewBytesExtensionHeader(0x0d, "video_config",
+		func(v []byte) []byte {
+			return v
+		},
+		func(v []byte) ([]byte, error) {
+			return v, nil
+		},
+	)
+)
+
+// ExtensionHeaderRegistry is a set of known extension headers.
+type ExtensionHeaderRegistry struct {
+	lock    sync.RWMutex
+	headers map[uint64]ExtensionHeader
+}
+
+// NewExtensionHeaderRegistry returns a new registry containing headers. It
+// panics if headers contains duplicate types.
+func NewExtensionHeaderRegistry(headers ...ExtensionHeader) *ExtensionHeaderRegistry {
+	r := &ExtensionHeaderRegistry{
+		lock:    sync.RWMutex{},
+		headers: map[uint64]ExtensionHeader{},
+	}
+	for _, h := range headers {
+		if err := r.Register(h); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// DefaultExtensionHeaderRegistry contains the extension headers known by this
+// package.
+var DefaultExtensionHeaderRegistry = NewExtensionHeaderRegistry(
+	CaptureTimestampExtension,
+	VideoFrameMarkingExtension,
+	AudioLevelExtension,
+	VideoConfigExtension,
+)
+
+// Register adds h to the registry. It returns an error if a header with the
+// same type is already registered.
+func (r *ExtensionHeaderRegistry) Register(h ExtensionHeader) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.headers[h.Type()]; ok {
+		return fmt.Errorf("%w: %v", errDuplicateExtensionHeader, h.Type())
+	}
+	r.headers[h.Type()] = h
+	return nil
+}
+
+// Lookup returns the extension header registered for typ.
+func (r *ExtensionHeaderRegistry) Lookup(typ uint64) (ExtensionHeader, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	h, ok := r.headers[typ]
+	return h, ok
+}
+
+// Validate validates all headers with a registered type. Headers with unknown
+// types are ignored.
+func (r *ExtensionHeaderRegistry) Validate(headers KVPList) error {
+	for _, kvp := range headers {
+		h, ok := r.Lookup(kvp.Type)
+		if !ok {
+			continue
+		}
+		if err := h.Validate(kvp); err != nil {
+			return fmt.Errorf("invalid %v extension header: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
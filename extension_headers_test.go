@@ -0,0 +1,89 @@
+package moqtransport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedExtensionHeader(t *testing.T) {
+	t.Run("capture_timestamp_round_trip", func(t *testing.T) {
+		ts := time.UnixMicro(1_700_000_000_123_456)
+		headers := CaptureTimestampExtension.Set(nil, ts)
+		assert.Equal(t, KVPList{
+			KeyValuePair{Type: 0x02, ValueVarInt: 1_700_000_000_123_456},
+		}, headers)
+
+		res, ok, err := CaptureTimestampExtension.Get(headers)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, ts.Equal(res))
+	})
+
+	t.Run("set_replaces_existing", func(t *testing.T) {
+		headers := VideoConfigExtension.Set(nil, []byte("a"))
+		headers = AudioLevelExtension.Set(headers, 3)
+		headers = VideoConfigExtension.Set(headers, []byte("b"))
+		assert.Equal(t, KVPList{
+			KeyValuePair{Type: 0x0d, ValueBytes: []byte("b")},
+			KeyValuePair{Type: 0x06, ValueVarInt: 3},
+		}, headers)
+	})
+
+	t.Run("get_missing", func(t *testing.T) {
+		_, ok, err := AudioLevelExtension.Get(KVPList{})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("decode_wrong_type", func(t *testing.T) {
+		_, err := AudioLevelExtension.Decode(KeyValuePair{Type: 0x04})
+		assert.ErrorIs(t, err, errInvalidExtensionHeaderType)
+	})
+
+	t.Run("panics_on_parity_mismatch", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewVarIntExtensionHeader(0x03, "odd", func(v uint64) uint64 { return v }, func(v uint64) (uint64, error) { return v, nil })
+		})
+		assert.Panics(t, func() {
+			NewBytesExtensionHeader(0x02, "even", func(v []byte) []byte { return v }, func(v []byte) ([]byte, error) { return v, nil })
+		})
+	})
+}
+
+func TestExtensionHeaderRegistry(t *testing.T) {
+	t.Run("lookup", func(t *testing.T) {
+		h, ok := DefaultExtensionHeaderRegistry.Lookup(0x02)
+		assert.True(t, ok)
+		assert.Equal(t, "capture_timestamp", h.Name())
+
+		_, ok = DefaultExtensionHeaderRegistry.Lookup(0x40)
+		assert.False(t, ok)
+	})
+
+	t.Run("rejects_duplicates", func(t *testing.T) {
+		r := NewExtensionHeaderRegistry(AudioLevelExtension)
+		assert.ErrorIs(t, r.Register(AudioLevelExtension), errDuplicateExtensionHeader)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		positive := NewVarIntExtensionHeader(0x40, "positive",
+			func(v uint64) uint64 { return v },
+			func(v uint64) (uint64, error) {
+				if v == 0 {
+					return 0, assert.AnError
+				}
+				return v, nil
+			},
+		)
+		r := NewExtensionHeaderRegistry(positive)
+		assert.NoError(t, r.Validate(KVPList{
+			KeyValuePair{Type: 0x40, ValueVarInt: 1},
+			KeyValuePair{Type: 0x42, ValueVarInt: 0},
+		}))
+		assert.ErrorIs(t, r.Validate(KVPList{
+			KeyValuePair{Type: 0x40, ValueVarInt: 0},
+		}), assert.AnError)
+	})
+}
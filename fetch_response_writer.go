@@ -1,24 +1,142 @@
 package moqtransport
 
-type fetchResponseWriter struct {
+import "errors"
+
+// FetchOKOption is a functional option for configuring FETCH_OK responses.
+type FetchOKOption func(*FetchOkOptions)
+
+// WithFetchOKGroupOrder sets the order in which the groups of the fetch are
+// delivered. Default is the group order requested by the subscriber, or
+// GroupOrderAscending if the subscriber did not request a group order.
+func WithFetchOKGroupOrder(groupOrder GroupOrder) FetchOKOption {
+	return func(opts *FetchOkOptions) {
+		opts.GroupOrder = groupOrder
+	}
+}
+
+// WithEndOfTrack indicates that the fetched range includes the end of the
+// track. Default is false.
+func WithEndOfTrack(endOfTrack bool) FetchOKOption {
+	return func(opts *FetchOkOptions) {
+		opts.EndOfTrack = endOfTrack
+	}
+}
+
+// WithEndLocation sets the location of the last object delivered by the
+// fetch. Default is the end of the range of joining fetches and group 0,
+// object 0 for standalone fetches.
+func WithEndLocation(location Location) FetchOKOption {
+	return func(opts *FetchOkOptions) {
+		opts.EndLocation = location
+	}
+}
+
+// WithFetchOKParameters sets additional key-value parameters for the
+// response.
+func WithFetchOKParameters(parameters KVPList) FetchOKOption {
+	return func(opts *FetchOkOptions) {
+		opts.Parameters = parameters
+	}
+}
+
+// FetchResponseWriter is used to respond to FETCH messages and to send the
+// fetched objects.
+type FetchResponseWriter struct {
 	id         uint64
 	session    *Session
 	localTrack *localTrack
 	handled    bool
+
+	// namespace, track, start, end and groupOrder describe the requested
+	// objects. joining is set for joining fetches, whose range is resolved
+	// from the joined subscription with joiningRequestID.
+	namespace        []string
+	track            string
+	start            Location
+	end              Location
+	groupOrder       GroupOrder
+	joining          bool
+	joiningRequestID uint64
+}
+
+// Accept accepts the fetch with the given options.
+//
+// Default behavior when no options are provided:
+//   - GroupOrder: the requested group order, GroupOrderAscending if none was
+//     requested
+//   - EndOfTrack: false
+//   - EndLocation: the end of the range of joining fetches, group 0, object
+//     0 for standalone fetches
+//   - Parameters: empty
+func (w *FetchResponseWriter) Accept(options ...FetchOKOption) error {
+	w.handled = true
+	opts := &FetchOkOptions{
+		GroupOrder:  GroupOrderAscending,
+		EndOfTrack:  false,
+		EndLocation: Location{Group: 0, Object: 0},
+		Parameters:  KVPList{},
+	}
+	if w.groupOrder == GroupOrderDescending {
+		opts.GroupOrder = GroupOrderDescending
+	}
+	if w.joining {
+		opts.EndLocation = w.end
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return w.session.acceptFetchWithOptions(w.id, opts)
+}
+
+// Reject rejects the fetch with code and reason.
+func (w *FetchResponseWriter) Reject(code uint64, reason string) error {
+	w.handled = true
+	return w.session.rejectFetch(w.id, code, reason)
+}
+
+// FetchStream returns the stream to write the fetched objects to. The stream
+// is opened on the first call.
+func (w *FetchResponseWriter) FetchStream() (*FetchStream, error) {
+	return w.localTrack.getFetchStream()
+}
+
+// AcceptFromStore answers the FETCH with the objects of the requested range
+// held by the ObjectStore of the Session. It sends FETCH_OK and writes the
+// objects to a new fetch stream, or sends FETCH_ERROR if the store holds no
+// objects in the range. If the store does not hold any objects of the track,
+// AcceptFromStore returns ErrTrackNotStored without answering the FETCH, so
+// that the handler can answer it otherwise.
+func (w *FetchResponseWriter) AcceptFromStore() error {
+	err := w.session.fetchFromStore(w.localTrack, w.namespace, w.track, w.start, w.end, w.groupOrder)
+	if !errors.Is(err, ErrTrackNotStored) {
+		w.handled = true
+	}
+	return err
+}
+
+// fetchResponseWriter adapts a FetchResponseWriter to ResponseWriter for
+// fetches passed to the Handler.
+type fetchResponseWriter struct {
+	*FetchResponseWriter
 }
 
 // Accept implements ResponseWriter.
-func (f *fetchResponseWriter) Accept() error {
-	f.handled = true
-	return f.session.acceptFetch(f.id)
+func (f fetchResponseWriter) Accept() error {
+	return f.FetchResponseWriter.Accept()
+}
+
+// joiningFetchResponseWriter adapts a FetchResponseWriter of a joining fetch
+// to JoiningFetchPublisher for fetches passed to the Handler.
+type joiningFetchResponseWriter struct {
+	fetchResponseWriter
 }
 
-// Reject implements ResponseWriter.
-func (f *fetchResponseWriter) Reject(code uint64, reason string) error {
-	f.handled = true
-	return f.session.rejectFetch(f.id, code, reason)
+// JoiningRequestID implements JoiningFetchPublisher.
+func (f joiningFetchResponseWriter) JoiningRequestID() uint64 {
+	return f.joiningRequestID
 }
 
-func (f *fetchResponseWriter) FetchStream() (*FetchStream, error) {
-	return f.localTrack.getFetchStream()
+// Range implements JoiningFetchPublisher.
+func (f joiningFetchResponseWriter) Range() (start, end Location) {
+	return f.start, f.end
 }
@@ -6,18 +6,23 @@ import (
 	"github.com/mengelbart/qlog/moqt"
 )
 
+// FetchStream writes the objects of a FETCH response. Each object is written
+// with its full payload. Streaming payloads with an ObjectWriter is only
+// supported on subgroups, see Subgroup.OpenObject.
 type FetchStream struct {
+	track   *localTrack
 	stream  SendStream
 	qlogger *qlog.Logger
 }
 
-func newFetchStream(stream SendStream, requestID uint64, qlogger *qlog.Logger) (*FetchStream, error) {
+func newFetchStream(track *localTrack, stream SendStream) (*FetchStream, error) {
+	qlogger := track.qlogger
 	fhm := &wire.FetchHeaderMessage{
-		RequestID: requestID,
+		RequestID: track.requestID,
 	}
 	buf := make([]byte, 0, 24)
 	buf = fhm.Append(buf)
-	_, err := stream.Write(buf)
+	_, err := track.write(stream, 0, 0, buf)
 	if err != nil {
 		return nil, err
 	}
@@ -29,6 +34,7 @@ func newFetchStream(stream SendStream, requestID uint64, qlogger *qlog.Logger) (
 		})
 	}
 	return &FetchStream{
+		track:   track,
 		stream:  stream,
 		qlogger: qlogger,
 	}, nil
@@ -39,19 +45,59 @@ func (f *FetchStream) WriteObject(
 	priority uint8,
 	payload []byte,
 ) (int, error) {
+	if err := f.writeObject(groupID, subgroupID, objectID, priority, ObjectStatusNormal, nil, payload); err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// WriteObjectWithExtensions writes an object carrying extension headers to the
+// fetch stream.
+func (f *FetchStream) WriteObjectWithExtensions(
+	groupID, subgroupID, objectID uint64,
+	priority uint8,
+	extensions KVPList,
+	payload []byte,
+) (int, error) {
+	if err := f.writeObject(groupID, subgroupID, objectID, priority, ObjectStatusNormal, extensions, payload); err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// WriteStatus writes an object without payload carrying status to the fetch
+// stream, e.g. to signal that an object in the requested range does not exist
+// or that the group or track ended.
+func (f *FetchStream) WriteStatus(
+	groupID, subgroupID, objectID uint64,
+	priority uint8,
+	status ObjectStatus,
+) error {
+	return f.writeObject(groupID, subgroupID, objectID, priority, status, nil, nil)
+}
+
+func (f *FetchStream) writeObject(
+	groupID, subgroupID, objectID uint64,
+	priority uint8,
+	status ObjectStatus,
+	extensions KVPList,
+	payload []byte,
+) error {
+	ext := extensions.ToWire()
 	buf := make([]byte, 0, 1400)
 	fo := wire.ObjectMessage{
-		GroupID:           groupID,
-		SubgroupID:        subgroupID,
-		ObjectID:          objectID,
-		PublisherPriority: priority,
-		ObjectStatus:      0,
-		ObjectPayload:     payload,
+		GroupID:                groupID,
+		SubgroupID:             subgroupID,
+		ObjectID:               objectID,
+		PublisherPriority:      priority,
+		ObjectExtensionHeaders: ext,
+		ObjectStatus:           status,
+		ObjectPayload:          payload,
 	}
 	buf = fo.AppendFetch(buf)
-	_, err := f.stream.Write(buf)
+	_, err := f.track.write(f.stream, priority, groupID, buf)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	if f.qlogger != nil {
 		f.qlogger.Log(moqt.FetchObjectEvent{
@@ -60,10 +106,11 @@ func (f *FetchStream) WriteObject(
 			GroupID:                groupID,
 			SubgroupID:             subgroupID,
 			ObjectID:               objectID,
-			ExtensionHeadersLength: 0,
-			ExtensionHeaders:       nil,
+			PublisherPriority:      priority,
+			ExtensionHeadersLength: ext.EncodedLength(),
+			ExtensionHeaders:       ext.QlogExtensionHeaders(),
 			ObjectPayloadLength:    uint64(len(payload)),
-			ObjectStatus:           0,
+			ObjectStatus:           uint64(status),
 			ObjectPayload: qlog.RawInfo{
 				Length:        uint64(len(payload)),
 				PayloadLength: uint64(len(payload)),
@@ -71,9 +118,16 @@ func (f *FetchStream) WriteObject(
 			},
 		})
 	}
-	return len(payload), nil
+	return nil
 }
 
 func (f *FetchStream) Close() error {
 	return f.stream.Close()
 }
+
+// Reset resets the fetch stream with code, e.g. ErrorCodeStreamInternal, to
+// end the fetch before all objects were written. The subscriber's fetch ends
+// with a *StreamResetError.
+func (f *FetchStream) Reset(code uint64) {
+	f.stream.Reset(uint32(code))
+}
@@ -0,0 +1,764 @@
+package moqtransport
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// defaultSegmentSize is the default number of bytes after which a
+// FileObjectStore starts a new segment file for a track.
+const defaultSegmentSize = 16 << 20
+
+// fileRecordHeaderLen is the length of the header preceding each record in a
+// segment file. The header holds the length of the record body and the CRC-32
+// checksum of the body, both as 32 bit big-endian integers.
+const fileRecordHeaderLen = 8
+
+const segmentFileExt = ".seg"
+
+var (
+	errFileObjectStoreClosed = errors.New("file object store closed")
+	errInvalidFileRecord     = errors.New("invalid file object store record")
+)
+
+// FileObjectStoreOption is a functional option for configuring a
+// FileObjectStore.
+type FileObjectStoreOption func(*FileObjectStoreOptions)
+
+// WithSegmentSize sets the number of bytes after which a new segment file is
+// started for a track. Retention removes whole segments, so smaller segments
+// allow more precise retention. Default is 16 MiB.
+func WithSegmentSize(size uint64) FileObjectStoreOption {
+	return func(opts *FileObjectStoreOptions) {
+		opts.SegmentSize = size
+	}
+}
+
+// WithRetentionAge removes objects that were stored longer than d. Default is
+// 0, which keeps objects regardless of their age.
+func WithRetentionAge(d time.Duration) FileObjectStoreOption {
+	return func(opts *FileObjectStoreOptions) {
+		opts.MaxAge = d
+	}
+}
+
+// WithRetentionSize removes the oldest segments of a track when the segment
+// files of the track exceed size bytes. The segment that objects are
+// currently appended to is never removed by size. Default is 0, which keeps
+// segments regardless of their size.
+func WithRetentionSize(size uint64) FileObjectStoreOption {
+	return func(opts *FileObjectStoreOptions) {
+		opts.MaxBytes = size
+	}
+}
+
+// FileObjectStore is an ObjectStore that keeps objects in files on the local
+// filesystem, so that stored tracks outlive the process. Each track is stored
+// in a directory below the directory of the store as a sequence of
+// append-only segment files. The objects in the segments are indexed by group
+// and object ID in memory. The index is rebuilt from the segment files when
+// the store is opened. A record that was only partially written, for example
+// because the process crashed, is truncated from the end of its segment.
+//
+// Storing an object at a location that is already stored replaces the object,
+// but the previous record stays in its segment file until the segment is
+// removed by retention or rewritten by Compact.
+//
+// Records are written without syncing the files, so stored objects survive a
+// crash of the process, but not necessarily a crash of the operating system.
+type FileObjectStore struct {
+	logger *slog.Logger
+
+	dir         string
+	segmentSize uint64
+	maxAge      time.Duration
+	maxBytes    uint64
+	now         func() time.Time
+
+	lock   sync.Mutex
+	closed bool
+	tracks map[string]*fileStoreTrack
+}
+
+type fileStoreTrack struct {
+	dir      string
+	segments []*fileSegment // ordered by ID
+	groups   map[uint64]map[uint64]*fileStoreEntry
+	largest  *Location
+}
+
+type fileSegment struct {
+	id     uint64
+	file   *os.File
+	size   uint64
+	newest time.Time // time the newest record of the segment was stored
+}
+
+type fileStoreEntry struct {
+	segment *fileSegment
+	offset  int64
+	length  int // length of the record including the header
+	status  ObjectStatus
+	stored  time.Time
+}
+
+// OpenFileObjectStore opens the FileObjectStore in dir and creates dir if it
+// does not exist. Objects stored in dir by a previous FileObjectStore are
+// loaded. The store must be closed with Close.
+//
+// Default behavior when no options are provided:
+//   - SegmentSize: 16 MiB
+//   - MaxAge: 0 (objects are not removed by age)
+//   - MaxBytes: 0 (objects are not removed by size)
+func OpenFileObjectStore(dir string, options ...FileObjectStoreOption) (*FileObjectStore, error) {
+	opts := &FileObjectStoreOptions{
+		SegmentSize: defaultSegmentSize,
+		MaxAge:      0,
+		MaxBytes:    0,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileObjectStore{
+		logger:      defaultLogger,
+		dir:         dir,
+		segmentSize: opts.SegmentSize,
+		maxAge:      opts.MaxAge,
+		maxBytes:    opts.MaxBytes,
+		now:         time.Now,
+		lock:        sync.Mutex{},
+		closed:      false,
+		tracks:      map[string]*fileStoreTrack{},
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := s.loadTrack(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			s.closeFiles()
+			return nil, err
+		}
+		s.tracks[entry.Name()] = t
+	}
+	return s, nil
+}
+
+// Put implements ObjectStore.
+func (s *FileObjectStore) Put(namespace []string, track string, o Object) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errFileObjectStoreClosed
+	}
+	name := fileStoreTrackName(namespace, track)
+	t, ok := s.tracks[name]
+	if !ok {
+		dir := filepath.Join(s.dir, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		t = &fileStoreTrack{
+			dir:      dir,
+			segments: []*fileSegment{},
+			groups:   map[uint64]map[uint64]*fileStoreEntry{},
+			largest:  nil,
+		}
+		s.tracks[name] = t
+	}
+	stored := s.now()
+	record := appendFileRecord(nil, o, stored)
+	seg, err := s.activeSegment(t, uint64(len(record)))
+	if err != nil {
+		return err
+	}
+	if _, err = seg.file.Write(record); err != nil {
+		// Remove what was written of the record, so that later records are
+		// not appended to a partial one.
+		if truncErr := seg.file.Truncate(int64(seg.size)); truncErr != nil {
+			s.logger.Error("failed to truncate segment after failed write", "segment", seg.file.Name(), "error", truncErr)
+		}
+		return err
+	}
+	t.add(o.GroupID, o.ObjectID, &fileStoreEntry{
+		segment: seg,
+		offset:  int64(seg.size),
+		length:  len(record),
+		status:  o.Status,
+		stored:  stored,
+	})
+	seg.size += uint64(len(record))
+	seg.newest = stored
+	s.applyRetention(t)
+	return nil
+}
+
+// Objects implements ObjectStore. The objects of each group are read while
+// holding the lock of the store and yielded after releasing it.
+func (s *FileObjectStore) Objects(namespace []string, track string, start, end Location, order GroupOrder) iter.Seq2[Object, error] {
+	return func(yield func(Object, error) bool) {
+		groupIDs, err := s.groupIDs(namespace, track, start, end, order)
+		if err != nil {
+			yield(Object{}, err)
+			return
+		}
+		for _, groupID := range groupIDs {
+			objects, err := s.group(namespace, track, groupID, start, end)
+			for _, o := range objects {
+				if !yield(o, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(Object{}, err)
+				return
+			}
+		}
+	}
+}
+
+// groupIDs returns the IDs of the stored groups of a track from start to end
+// in order.
+func (s *FileObjectStore) groupIDs(namespace []string, track string, start, end Location, order GroupOrder) ([]uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil, errFileObjectStoreClosed
+	}
+	t, ok := s.tracks[fileStoreTrackName(namespace, track)]
+	if !ok {
+		return nil, nil
+	}
+	s.applyRetention(t)
+	groupIDs := []uint64{}
+	for id := range t.groups {
+		if id >= start.Group && id <= end.Group {
+			groupIDs = append(groupIDs, id)
+		}
+	}
+	slices.Sort(groupIDs)
+	if order == GroupOrderDescending {
+		slices.Reverse(groupIDs)
+	}
+	return groupIDs, nil
+}
+
+// group reads the stored objects of a group from start to end ordered by
+// object ID. If reading an object fails, group returns the objects read
+// before and the error.
+func (s *FileObjectStore) group(namespace []string, track string, groupID uint64, start, end Location) ([]Object, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil, errFileObjectStoreClosed
+	}
+	t, ok := s.tracks[fileStoreTrackName(namespace, track)]
+	if !ok {
+		return nil, nil
+	}
+	group := t.groups[groupID]
+	objectIDs := []uint64{}
+	for id := range group {
+		l := Location{Group: groupID, Object: id}
+		if !locationBefore(l, start, false) && !locationBefore(end, l, false) && !s.expired(group[id]) {
+			objectIDs = append(objectIDs, id)
+		}
+	}
+	slices.Sort(objectIDs)
+	objects := make([]Object, 0, len(objectIDs))
+	for _, id := range objectIDs {
+		o, err := group[id].read()
+		if err != nil {
+			return objects, fmt.Errorf("failed to read object %v/%v: %w", groupID, id, err)
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// Largest implements ObjectStore. The largest location includes objects that
+// were removed by retention since the store was opened.
+func (s *FileObjectStore) Largest(namespace []string, track string) (Location, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return Location{}, false
+	}
+	t, ok := s.tracks[fileStoreTrackName(namespace, track)]
+	if !ok || t.largest == nil {
+		return Location{}, false
+	}
+	return *t.largest, true
+}
+
+// Compact rewrites the segments of all tracks, so that they only contain the
+// records of the currently stored objects. Records of replaced and expired
+// objects are removed.
+func (s *FileObjectStore) Compact() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errFileObjectStoreClosed
+	}
+	for _, t := range s.tracks {
+		if err := s.compact(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the segment files. The store cannot be used after Close.
+func (s *FileObjectStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errFileObjectStoreClosed
+	}
+	s.closed = true
+	return s.closeFiles()
+}
+
+// closeFiles closes the segment files of all tracks. s.lock must be held.
+func (s *FileObjectStore) closeFiles() error {
+	var errs []error
+	for _, t := range s.tracks {
+		for _, seg := range t.segments {
+			if err := seg.file.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadTrack opens the segments in dir and rebuilds their index.
+func (s *FileObjectStore) loadTrack(dir string) (*fileStoreTrack, error) {
+	t := &fileStoreTrack{
+		dir:      dir,
+		segments: []*fileSegment{},
+		groups:   map[uint64]map[uint64]*fileStoreEntry{},
+		largest:  nil,
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint64{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentFileExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		f, err := os.OpenFile(segmentPath(dir, id), os.O_RDWR|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errors.Join(err, t.close())
+		}
+		seg := &fileSegment{
+			id:     id,
+			file:   f,
+			size:   0,
+			newest: time.Time{},
+		}
+		t.segments = append(t.segments, seg)
+		if err := s.loadSegment(t, seg); err != nil {
+			return nil, errors.Join(err, t.close())
+		}
+	}
+	return t, nil
+}
+
+// loadSegment adds the records of seg to the index of t. Records after the
+// first invalid record are truncated.
+func (s *FileObjectStore) loadSegment(t *fileStoreTrack, seg *fileSegment) error {
+	info, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := uint64(info.Size())
+	r := bufio.NewReader(io.NewSectionReader(seg.file, 0, info.Size()))
+	header := make([]byte, fileRecordHeaderLen)
+	body := []byte{}
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			break
+		}
+		// A corrupted length must not allocate more than the rest of the
+		// segment.
+		length := binary.BigEndian.Uint32(header)
+		if uint64(length) > fileSize-seg.size-fileRecordHeaderLen {
+			break
+		}
+		body = slices.Grow(body[:0], int(length))[:length]
+		if _, err := io.ReadFull(r, body); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		o, stored, err := parseFileRecordBody(body, false)
+		if err != nil {
+			break
+		}
+		t.add(o.GroupID, o.ObjectID, &fileStoreEntry{
+			segment: seg,
+			offset:  int64(seg.size),
+			length:  fileRecordHeaderLen + len(body),
+			status:  o.Status,
+			stored:  stored,
+		})
+		seg.size += uint64(fileRecordHeaderLen + len(body))
+		if stored.After(seg.newest) {
+			seg.newest = stored
+		}
+	}
+	s.logger.Warn("truncating invalid records at end of segment", "segment", seg.file.Name(), "offset", seg.size)
+	return seg.file.Truncate(int64(seg.size))
+}
+
+// activeSegment returns the segment a record of length bytes is appended to
+// and starts a new segment if the current one is full. s.lock must be held.
+func (s *FileObjectStore) activeSegment(t *fileStoreTrack, length uint64) (*fileSegment, error) {
+	if len(t.segments) > 0 {
+		seg := t.segments[len(t.segments)-1]
+		if seg.size == 0 || seg.size+length <= s.segmentSize {
+			return seg, nil
+		}
+	}
+	return t.newSegment()
+}
+
+// applyRetention removes the segments of t that only hold expired records and
+// the oldest segments of t while t exceeds the maximum size. s.lock must be
+// held.
+func (s *FileObjectStore) applyRetention(t *fileStoreTrack) {
+	if s.maxAge > 0 {
+		deadline := s.now().Add(-s.maxAge)
+		for len(t.segments) > 0 && !t.segments[0].newest.After(deadline) {
+			s.removeSegment(t, t.segments[0])
+		}
+	}
+	if s.maxBytes > 0 {
+		for len(t.segments) > 1 && t.size() > s.maxBytes {
+			s.removeSegment(t, t.segments[0])
+		}
+	}
+}
+
+// expired reports whether e was stored longer than the maximum age.
+func (s *FileObjectStore) expired(e *fileStoreEntry) bool {
+	return s.maxAge > 0 && !e.stored.After(s.now().Add(-s.maxAge))
+}
+
+// removeSegment removes seg and the objects stored in it from t. s.lock must
+// be held.
+func (s *FileObjectStore) removeSegment(t *fileStoreTrack, seg *fileSegment) {
+	t.segments = slices.DeleteFunc(t.segments, func(x *fileSegment) bool {
+		return x == seg
+	})
+	for groupID, group := range t.groups {
+		for objectID, e := range group {
+			if e.segment == seg {
+				delete(group, objectID)
+			}
+		}
+		if len(group) == 0 {
+			delete(t.groups, groupID)
+		}
+	}
+	if err := seg.file.Close(); err != nil {
+		s.logger.Error("failed to close segment", "segment", seg.file.Name(), "error", err)
+	}
+	if err := os.Remove(seg.file.Name()); err != nil {
+		s.logger.Error("failed to remove segment", "segment", seg.file.Name(), "error", err)
+	}
+}
+
+// compact copies the records of the objects stored in t to new segments and
+// removes the old segments. The old segments are removed after the new ones
+// were written, so that no objects are lost if compaction is interrupted. If
+// compaction fails, the new segments are removed and t is left unchanged.
+// s.lock must be held.
+func (s *FileObjectStore) compact(t *fileStoreTrack) (err error) {
+	old := slices.Clone(t.segments)
+	defer func() {
+		if err != nil {
+			s.rollback(t, old)
+		}
+	}()
+	type item struct {
+		location Location
+		entry    *fileStoreEntry
+	}
+	items := []item{}
+	for groupID, group := range t.groups {
+		for objectID, e := range group {
+			if !s.expired(e) {
+				items = append(items, item{
+					location: Location{Group: groupID, Object: objectID},
+					entry:    e,
+				})
+			}
+		}
+	}
+	slices.SortFunc(items, func(a, b item) int {
+		if locationBefore(a.location, b.location, false) {
+			return -1
+		}
+		return 1
+	})
+	groups := map[uint64]map[uint64]*fileStoreEntry{}
+	var seg *fileSegment
+	for _, it := range items {
+		record := make([]byte, it.entry.length)
+		if _, err := it.entry.segment.file.ReadAt(record, it.entry.offset); err != nil {
+			return err
+		}
+		if seg == nil || seg.size+uint64(len(record)) > s.segmentSize {
+			var err error
+			if seg, err = t.newSegment(); err != nil {
+				return err
+			}
+		}
+		if _, err := seg.file.Write(record); err != nil {
+			return err
+		}
+		e := *it.entry
+		e.segment = seg
+		e.offset = int64(seg.size)
+		if _, ok := groups[it.location.Group]; !ok {
+			groups[it.location.Group] = map[uint64]*fileStoreEntry{}
+		}
+		groups[it.location.Group][it.location.Object] = &e
+		seg.size += uint64(len(record))
+		if e.stored.After(seg.newest) {
+			seg.newest = e.stored
+		}
+	}
+	t.groups = groups
+	for _, seg := range old {
+		s.removeSegment(t, seg)
+	}
+	return nil
+}
+
+// rollback removes the segments of t that are not in old after a failed
+// compaction. s.lock must be held.
+func (s *FileObjectStore) rollback(t *fileStoreTrack, old []*fileSegment) {
+	for _, seg := range t.segments[len(old):] {
+		if err := seg.file.Close(); err != nil {
+			s.logger.Error("failed to close segment", "segment", seg.file.Name(), "error", err)
+		}
+		if err := os.Remove(seg.file.Name()); err != nil {
+			s.logger.Error("failed to remove segment", "segment", seg.file.Name(), "error", err)
+		}
+	}
+	t.segments = old
+}
+
+// add adds e to the index at groupID and objectID and replaces an existing
+// entry. The largest location of t is updated.
+func (t *fileStoreTrack) add(groupID, objectID uint64, e *fileStoreEntry) {
+	group, ok := t.groups[groupID]
+	if !ok {
+		group = map[uint64]*fileStoreEntry{}
+		t.groups[groupID] = group
+	}
+	group[objectID] = e
+	l := Location{Group: groupID, Object: objectID}
+	if t.largest == nil || locationBefore(*t.largest, l, false) {
+		t.largest = &l
+	}
+}
+
+// newSegment creates a segment following the last segment of t.
+func (t *fileStoreTrack) newSegment() (*fileSegment, error) {
+	id := uint64(0)
+	if len(t.segments) > 0 {
+		id = t.segments[len(t.segments)-1].id + 1
+	}
+	f, err := os.OpenFile(segmentPath(t.dir, id), os.O_RDWR|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &fileSegment{
+		id:     id,
+		file:   f,
+		size:   0,
+		newest: time.Time{},
+	}
+	t.segments = append(t.segments, seg)
+	return seg, nil
+}
+
+// size returns the number of bytes of the segments of t.
+func (t *fileStoreTrack) size() uint64 {
+	size := uint64(0)
+	for _, seg := range t.segments {
+		size += seg.size
+	}
+	return size
+}
+
+func (t *fileStoreTrack) close() error {
+	var errs []error
+	for _, seg := range t.segments {
+		if err := seg.file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// read reads the object of e from its segment.
+func (e *fileStoreEntry) read() (Object, error) {
+	record := make([]byte, e.length)
+	if _, err := e.segment.file.ReadAt(record, e.offset); err != nil {
+		return Object{}, err
+	}
+	o, _, err := parseFileRecordBody(record[fileRecordHeaderLen:], true)
+	return o, err
+}
+
+// appendFileRecord appends the record of o stored at stored to buf. The
+// record body holds the varint encoded group ID, object ID, subgroup ID,
+// publisher priority, status, storage time in nanoseconds since the Unix
+// epoch and number of extension headers, followed by the extension headers
+// and the payload.
+func appendFileRecord(buf []byte, o Object, stored time.Time) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, fileRecordHeaderLen)...)
+	buf = quicvarint.Append(buf, o.GroupID)
+	buf = quicvarint.Append(buf, o.ObjectID)
+	buf = quicvarint.Append(buf, o.SubGroupID)
+	buf = quicvarint.Append(buf, uint64(o.PublisherPriority))
+	buf = quicvarint.Append(buf, uint64(o.Status))
+	buf = quicvarint.Append(buf, uint64(stored.UnixNano()))
+	buf = quicvarint.Append(buf, uint64(len(o.ExtensionHeaders)))
+	for _, h := range o.ExtensionHeaders {
+		buf = quicvarint.Append(buf, h.Type)
+		if h.Type%2 == 1 {
+			buf = quicvarint.Append(buf, uint64(len(h.ValueBytes)))
+			buf = append(buf, h.ValueBytes...)
+		} else {
+			buf = quicvarint.Append(buf, h.ValueVarInt)
+		}
+	}
+	buf = append(buf, o.Payload...)
+	body := buf[start+fileRecordHeaderLen:]
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[start+4:], crc32.ChecksumIEEE(body))
+	return buf
+}
+
+// parseFileRecordBody parses the body of a record written by
+// appendFileRecord. Extension headers and payload are only parsed if full is
+// set.
+func parseFileRecordBody(body []byte, full bool) (Object, time.Time, error) {
+	fields := make([]uint64, 7)
+	for i := range fields {
+		v, n, err := quicvarint.Parse(body)
+		if err != nil {
+			return Object{}, time.Time{}, errInvalidFileRecord
+		}
+		fields[i] = v
+		body = body[n:]
+	}
+	o := Object{
+		GroupID:              fields[0],
+		ObjectID:             fields[1],
+		ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+		SubGroupID:           fields[2],
+		PublisherPriority:    uint8(fields[3]),
+		Status:               ObjectStatus(fields[4]),
+		ExtensionHeaders:     nil,
+		Payload:              nil,
+	}
+	stored := time.Unix(0, int64(fields[5]))
+	if !full {
+		return o, stored, nil
+	}
+	for range fields[6] {
+		if o.ExtensionHeaders == nil {
+			o.ExtensionHeaders = KVPList{}
+		}
+		var h KeyValuePair
+		var n int
+		var err error
+		if h.Type, n, err = quicvarint.Parse(body); err != nil {
+			return Object{}, time.Time{}, errInvalidFileRecord
+		}
+		body = body[n:]
+		if h.Type%2 == 1 {
+			var length uint64
+			if length, n, err = quicvarint.Parse(body); err != nil || uint64(len(body)-n) < length {
+				return Object{}, time.Time{}, errInvalidFileRecord
+			}
+			h.ValueBytes = body[n : n+int(length)]
+			body = body[n+int(length):]
+		} else {
+			if h.ValueVarInt, n, err = quicvarint.Parse(body); err != nil {
+				return Object{}, time.Time{}, errInvalidFileRecord
+			}
+			body = body[n:]
+		}
+		o.ExtensionHeaders = append(o.ExtensionHeaders, h)
+	}
+	if o.Status == ObjectStatusNormal {
+		o.Payload = body
+	}
+	return o, stored, nil
+}
+
+// fileStoreTrackName returns the name of the directory of a track. Names are
+// derived from a hash, so that they are valid file names for any namespace
+// and track name.
+func fileStoreTrackName(namespace []string, track string) string {
+	sum := sha256.Sum256([]byte(memoryStoreKey(namespace, track)))
+	return hex.EncodeToString(sum[:])
+}
+
+func segmentPath(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", id, segmentFileExt))
+}
@@ -0,0 +1,249 @@
+package moqtransport
+
+import (
+	"encoding/binary"
+	"iter"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileObjectStore(t *testing.T) {
+	testObjectStore(t, func(t *testing.T) ObjectStore {
+		s, err := OpenFileObjectStore(t.TempDir())
+		assert.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+
+	ns := []string{"namespace"}
+	obj := func(groupID, objectID uint64, payload string) Object {
+		return Object{
+			GroupID:              groupID,
+			ObjectID:             objectID,
+			ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+			Payload:              []byte(payload),
+		}
+	}
+	collect := func(t *testing.T, seq iter.Seq2[Object, error]) []Object {
+		objects := []Object{}
+		for o, err := range seq {
+			assert.NoError(t, err)
+			objects = append(objects, o)
+		}
+		return objects
+	}
+	ids := func(objects []Object) []uint64 {
+		res := []uint64{}
+		for _, o := range objects {
+			res = append(res, o.GroupID<<8|o.ObjectID)
+		}
+		return res
+	}
+	all := Location{Group: math.MaxUint64, Object: math.MaxUint64}
+	segments := func(t *testing.T, dir string) []string {
+		matches, err := filepath.Glob(filepath.Join(dir, "*", "*"+segmentFileExt))
+		assert.NoError(t, err)
+		return matches
+	}
+
+	t.Run("reopen", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir, WithSegmentSize(64))
+		assert.NoError(t, err)
+		for g := range uint64(3) {
+			for o := range uint64(3) {
+				assert.NoError(t, s.Put(ns, "track", obj(g, o, "payload")))
+			}
+		}
+		assert.NoError(t, s.Put(ns, "track", Object{
+			GroupID:              2,
+			ObjectID:             3,
+			ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+			Status:               ObjectStatusEndOfGroup,
+		}))
+		ext := obj(3, 0, "ext")
+		ext.SubGroupID = 1
+		ext.PublisherPriority = 7
+		ext.ExtensionHeaders = KVPList{
+			{Type: 2, ValueVarInt: 42},
+			{Type: 3, ValueBytes: []byte("value")},
+		}
+		assert.NoError(t, s.Put(ns, "track", ext))
+		assert.NoError(t, s.Put(ns, "other", obj(0, 0, "other")))
+		assert.Greater(t, len(segments(t, dir)), 2)
+		assert.NoError(t, s.Close())
+
+		s, err = OpenFileObjectStore(dir, WithSegmentSize(64))
+		assert.NoError(t, err)
+		defer s.Close()
+		objects := collect(t, s.Objects(ns, "track", Location{Group: 1, Object: 2}, all, GroupOrderDescending))
+		assert.Equal(t, []uint64{0x300, 0x200, 0x201, 0x202, 0x203, 0x102}, ids(objects))
+		assert.Equal(t, ext, objects[0])
+		assert.Equal(t, ObjectStatusEndOfGroup, objects[4].Status)
+		assert.Empty(t, objects[4].Payload)
+		assert.Equal(t, []byte("payload"), objects[5].Payload)
+
+		largest, ok := s.Largest(ns, "track")
+		assert.True(t, ok)
+		assert.Equal(t, Location{Group: 3, Object: 0}, largest)
+		assert.Empty(t, collect(t, s.Objects(ns, "unknown", Location{}, all, GroupOrderAscending)))
+		_, ok = s.Largest(ns, "unknown")
+		assert.False(t, ok)
+	})
+
+	t.Run("truncate_partial_record", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "b")))
+		assert.NoError(t, s.Close())
+
+		files := segments(t, dir)
+		assert.Len(t, files, 1)
+		info, err := os.Stat(files[0])
+		assert.NoError(t, err)
+		assert.NoError(t, os.Truncate(files[0], info.Size()-1))
+
+		s, err = OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		objects := collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{0}, ids(objects))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "c")))
+		assert.NoError(t, s.Close())
+
+		s, err = OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		defer s.Close()
+		objects = collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{0, 1}, ids(objects))
+		assert.Equal(t, []byte("c"), objects[1].Payload)
+	})
+
+	t.Run("retention_age", func(t *testing.T) {
+		now := time.Now()
+		s, err := OpenFileObjectStore(t.TempDir(), WithSegmentSize(32), WithRetentionAge(time.Second))
+		assert.NoError(t, err)
+		defer s.Close()
+		s.now = func() time.Time { return now }
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		now = now.Add(600 * time.Millisecond)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "b")))
+		now = now.Add(600 * time.Millisecond)
+
+		objects := collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{1}, ids(objects))
+
+		now = now.Add(time.Second)
+		objects = collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Empty(t, objects)
+		assert.Empty(t, s.tracks[fileStoreTrackName(ns, "track")].segments)
+	})
+
+	t.Run("retention_size", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir, WithSegmentSize(64), WithRetentionSize(150))
+		assert.NoError(t, err)
+		defer s.Close()
+		for o := range uint64(10) {
+			assert.NoError(t, s.Put(ns, "track", obj(0, o, "0123456789")))
+		}
+		files := segments(t, dir)
+		assert.Len(t, files, 2)
+		objects := collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{6, 7, 8, 9}, ids(objects))
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir, WithSegmentSize(64))
+		assert.NoError(t, err)
+		for range 10 {
+			assert.NoError(t, s.Put(ns, "track", obj(0, 0, "0123456789")))
+		}
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "0123456789")))
+		assert.Len(t, segments(t, dir), 6)
+
+		assert.NoError(t, s.Compact())
+		assert.Len(t, segments(t, dir), 1)
+		objects := collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{0, 1}, ids(objects))
+		assert.NoError(t, s.Close())
+
+		s, err = OpenFileObjectStore(dir, WithSegmentSize(64))
+		assert.NoError(t, err)
+		defer s.Close()
+		objects = collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))
+		assert.Equal(t, []uint64{0, 1}, ids(objects))
+	})
+
+	t.Run("truncate_invalid_length", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "b")))
+		assert.NoError(t, s.Close())
+
+		// Overwrite the length of the second record with a length exceeding
+		// the segment.
+		files := segments(t, dir)
+		assert.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		first := fileRecordHeaderLen + binary.BigEndian.Uint32(data)
+		binary.BigEndian.PutUint32(data[first:], math.MaxUint32)
+		assert.NoError(t, os.WriteFile(files[0], data, 0o644))
+
+		s, err = OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		defer s.Close()
+		assert.Equal(t, []uint64{0}, ids(collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))))
+		info, err := os.Stat(files[0])
+		assert.NoError(t, err)
+		assert.Equal(t, int64(first), info.Size())
+	})
+
+	t.Run("compact_rollback", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileObjectStore(dir, WithSegmentSize(64))
+		assert.NoError(t, err)
+		defer s.Close()
+		for range 2 {
+			for o := range uint64(4) {
+				assert.NoError(t, s.Put(ns, "track", obj(0, o, "0123456789")))
+			}
+		}
+		old := segments(t, dir)
+
+		// Block the second segment written by compaction, so that
+		// compaction fails after writing the first one.
+		trackDir := filepath.Join(dir, fileStoreTrackName(ns, "track"))
+		blocker := segmentPath(trackDir, uint64(len(old)+1))
+		assert.NoError(t, os.WriteFile(blocker, nil, 0o644))
+		assert.Error(t, s.Compact())
+		assert.ElementsMatch(t, append(old, blocker), segments(t, dir))
+		assert.Equal(t, []uint64{0, 1, 2, 3}, ids(collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))))
+
+		assert.NoError(t, os.Remove(blocker))
+		assert.NoError(t, s.Compact())
+		assert.Len(t, segments(t, dir), 2)
+		assert.Equal(t, []uint64{0, 1, 2, 3}, ids(collect(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending))))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		s, err := OpenFileObjectStore(t.TempDir())
+		assert.NoError(t, err)
+		assert.NoError(t, s.Close())
+		assert.ErrorIs(t, s.Put(ns, "track", obj(0, 0, "a")), errFileObjectStoreClosed)
+		assert.ErrorIs(t, s.Compact(), errFileObjectStoreClosed)
+		for _, err := range s.Objects(ns, "track", Location{}, all, GroupOrderAscending) {
+			assert.ErrorIs(t, err, errFileObjectStoreClosed)
+		}
+	})
+}
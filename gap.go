@@ -0,0 +1,445 @@
+package moqtransport
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// GapReason describes why objects of a subscription were not received.
+type GapReason int
+
+const (
+	// GapReasonDoesNotExist indicates that the publisher sent an object with
+	// ObjectStatusObjectDoesNotExist.
+	GapReasonDoesNotExist GapReason = iota
+
+	// GapReasonStreamReset indicates that the publisher reset a subgroup stream
+	// before all objects of the subgroup were delivered.
+	GapReasonStreamReset
+
+	// GapReasonDatagramLoss indicates that objects sent in datagrams were
+	// likely lost, because a datagram with a larger object ID of the same
+	// group was received.
+	GapReasonDatagramLoss
+
+	// GapReasonObjectsSkipped indicates that object IDs were skipped on a
+	// subgroup stream.
+	GapReasonObjectsSkipped
+
+	// GapReasonSubgroupsMissing indicates that no stream was received for
+	// subgroups with IDs smaller than the largest subgroup ID received for
+	// the group.
+	GapReasonSubgroupsMissing
+
+	// GapReasonGroupsSkipped indicates that no objects were received for
+	// groups between received groups.
+	GapReasonGroupsSkipped
+)
+
+func (r GapReason) String() string {
+	switch r {
+	case GapReasonDoesNotExist:
+		return "does_not_exist"
+	case GapReasonStreamReset:
+		return "stream_reset"
+	case GapReasonDatagramLoss:
+		return "datagram_loss"
+	case GapReasonObjectsSkipped:
+		return "objects_skipped"
+	case GapReasonSubgroupsMissing:
+		return "subgroups_missing"
+	case GapReasonGroupsSkipped:
+		return "groups_skipped"
+	}
+	return "unknown"
+}
+
+// A Gap describes objects of a subscription that were not received.
+//
+// Gaps on subgroup streams are detected assuming that the objects of a
+// subgroup have consecutive object IDs and that the subgroups of a group have
+// consecutive subgroup IDs starting at zero. Missing subgroups and skipped
+// groups are reported once objects of a group at least two groups later were
+// received, because the streams of a group may arrive after the
+// streams of the next group. Groups are only checked if they are received in
+// ascending order.
+type Gap struct {
+	Reason  GapReason
+	GroupID uint64
+
+	// SubgroupID is the subgroup of the missing objects. Objects sent in
+	// datagrams form their own subgroups identified by their object IDs. For
+	// GapReasonSubgroupsMissing, it is the first missing subgroup. It is zero
+	// for GapReasonGroupsSkipped.
+	SubgroupID uint64
+
+	// ObjectID is the ID of the first missing object. For
+	// GapReasonStreamReset, it is the ID following the last object received
+	// on the stream. It is zero for GapReasonSubgroupsMissing and
+	// GapReasonGroupsSkipped.
+	ObjectID uint64
+
+	// Count is the number of missing objects, or the number of missing
+	// subgroups or skipped groups for GapReasonSubgroupsMissing and
+	// GapReasonGroupsSkipped, starting at SubgroupID and GroupID
+	// respectively. It is zero for GapReasonStreamReset, because the number
+	// of objects lost with the stream is unknown.
+	Count uint64
+}
+
+// GapStats counts the gaps detected on a subscription.
+type GapStats struct {
+	// DoesNotExist is the number of objects the publisher reported as not
+	// existing.
+	DoesNotExist uint64
+
+	// StreamResets is the number of subgroup streams reset by the publisher
+	// before the end of the subgroup. Streams reset before their header was
+	// received cannot be attributed to a subscription and are not counted.
+	StreamResets uint64
+
+	// DatagramsLost is the number of objects sent in datagrams that were
+	// likely lost.
+	DatagramsLost uint64
+
+	// ObjectsSkipped is the number of object IDs skipped on subgroup streams.
+	ObjectsSkipped uint64
+
+	// SubgroupsMissing is the number of subgroups for which no stream was
+	// received.
+	SubgroupsMissing uint64
+
+	// GroupsSkipped is the number of groups for which no objects were
+	// received.
+	GroupsSkipped uint64
+}
+
+// gapGroupHorizon is the number of groups after which a group is checked for
+// missing subgroups. Groups older than that are no longer tracked.
+const gapGroupHorizon = 2
+
+type subgroupKey struct {
+	groupID    uint64
+	subgroupID uint64
+}
+
+// subgroupGaps is the state of a subgroup stream.
+type subgroupGaps struct {
+	// next is the expected next object ID.
+	next uint64
+
+	// doesNotExist is the number of consecutive objects with
+	// ObjectStatusObjectDoesNotExist ending before next, which are reported
+	// as a single gap when the range ends.
+	doesNotExist uint64
+}
+
+// groupGaps is the state of a group that was not checked yet.
+type groupGaps struct {
+	subgroups map[uint64]struct{}
+
+	hasDatagram    bool
+	datagramObject uint64
+}
+
+// gapDetector tracks received objects of a track and reports gaps.
+type gapDetector struct {
+	handler func(Gap)
+
+	doesNotExist     atomic.Uint64
+	streamResets     atomic.Uint64
+	datagramsLost    atomic.Uint64
+	objectsSkipped   atomic.Uint64
+	subgroupsMissing atomic.Uint64
+	groupsSkipped    atomic.Uint64
+
+	// lock protects the state of open subgroup streams and of the groups
+	// that were not checked yet.
+	lock    sync.Mutex
+	streams map[subgroupKey]*subgroupGaps
+	groups  map[uint64]*groupGaps
+	started bool
+	next    uint64
+	largest uint64
+}
+
+func newGapDetector(handler func(Gap)) *gapDetector {
+	return &gapDetector{
+		handler:          handler,
+		doesNotExist:     atomic.Uint64{},
+		streamResets:     atomic.Uint64{},
+		datagramsLost:    atomic.Uint64{},
+		objectsSkipped:   atomic.Uint64{},
+		subgroupsMissing: atomic.Uint64{},
+		groupsSkipped:    atomic.Uint64{},
+		lock:             sync.Mutex{},
+		streams:          map[subgroupKey]*subgroupGaps{},
+		groups:           map[uint64]*groupGaps{},
+		started:          false,
+		next:             0,
+		largest:          0,
+	}
+}
+
+func (d *gapDetector) stats() GapStats {
+	return GapStats{
+		DoesNotExist:     d.doesNotExist.Load(),
+		StreamResets:     d.streamResets.Load(),
+		DatagramsLost:    d.datagramsLost.Load(),
+		ObjectsSkipped:   d.objectsSkipped.Load(),
+		SubgroupsMissing: d.subgroupsMissing.Load(),
+		GroupsSkipped:    d.groupsSkipped.Load(),
+	}
+}
+
+// add counts g and appends it to gaps. Stream resets are counted by
+// streamReset.
+func (d *gapDetector) add(gaps []Gap, g Gap) []Gap {
+	switch g.Reason {
+	case GapReasonDoesNotExist:
+		d.doesNotExist.Add(g.Count)
+	case GapReasonDatagramLoss:
+		d.datagramsLost.Add(g.Count)
+	case GapReasonObjectsSkipped:
+		d.objectsSkipped.Add(g.Count)
+	case GapReasonSubgroupsMissing:
+		d.subgroupsMissing.Add(g.Count)
+	case GapReasonGroupsSkipped:
+		d.groupsSkipped.Add(g.Count)
+	}
+	return append(gaps, g)
+}
+
+// report calls the handler for gaps. It must not be called while holding
+// the lock.
+func (d *gapDetector) report(gaps []Gap) {
+	if d.handler == nil {
+		return
+	}
+	for _, g := range gaps {
+		d.handler(g)
+	}
+}
+
+// object checks a received object for a status indicating a gap. It is used
+// for objects that are not received on subgroup streams.
+func (d *gapDetector) object(o *Object) {
+	if o.Status != ObjectStatusObjectDoesNotExist {
+		return
+	}
+	d.report(d.add(nil, Gap{
+		Reason:     GapReasonDoesNotExist,
+		GroupID:    o.GroupID,
+		SubgroupID: o.SubGroupID,
+		ObjectID:   o.ObjectID,
+		Count:      1,
+	}))
+}
+
+// group returns the state of groupID and checks the groups that are at least
+// gapGroupHorizon groups older than the largest group. It returns nil if
+// groupID was already checked. The caller must hold the lock.
+func (d *gapDetector) group(groupID uint64, gaps []Gap) (*groupGaps, []Gap) {
+	if !d.started {
+		d.started = true
+		d.next = groupID
+		d.largest = groupID
+	}
+	if groupID < d.next {
+		return nil, gaps
+	}
+	g, ok := d.groups[groupID]
+	if !ok {
+		g = &groupGaps{
+			subgroups:      map[uint64]struct{}{},
+			hasDatagram:    false,
+			datagramObject: 0,
+		}
+		d.groups[groupID] = g
+	}
+	if groupID > d.largest {
+		d.largest = groupID
+		gaps = d.check(gaps)
+	}
+	return g, gaps
+}
+
+// check reports missing subgroups and skipped groups for groups that are at
+// least gapGroupHorizon groups older than the largest group and stops
+// tracking them. The caller must hold the lock.
+func (d *gapDetector) check(gaps []Gap) []Gap {
+	if d.largest < gapGroupHorizon {
+		return gaps
+	}
+	end := d.largest - gapGroupHorizon + 1
+	for d.next < end {
+		g, ok := d.groups[d.next]
+		if !ok {
+			skipTo := end
+			for groupID := range d.groups {
+				if groupID > d.next && groupID < skipTo {
+					skipTo = groupID
+				}
+			}
+			gaps = d.add(gaps, Gap{
+				Reason:     GapReasonGroupsSkipped,
+				GroupID:    d.next,
+				SubgroupID: 0,
+				ObjectID:   0,
+				Count:      skipTo - d.next,
+			})
+			d.next = skipTo
+			continue
+		}
+		subgroups := make([]uint64, 0, len(g.subgroups))
+		for subgroupID := range g.subgroups {
+			subgroups = append(subgroups, subgroupID)
+		}
+		slices.Sort(subgroups)
+		expected := uint64(0)
+		for _, subgroupID := range subgroups {
+			if subgroupID > expected {
+				gaps = d.add(gaps, Gap{
+					Reason:     GapReasonSubgroupsMissing,
+					GroupID:    d.next,
+					SubgroupID: expected,
+					ObjectID:   0,
+					Count:      subgroupID - expected,
+				})
+			}
+			expected = subgroupID + 1
+		}
+		delete(d.groups, d.next)
+		d.next++
+	}
+	return gaps
+}
+
+// flushDoesNotExist appends the range of objects with
+// ObjectStatusObjectDoesNotExist ending before s.next, if any.
+func (d *gapDetector) flushDoesNotExist(gaps []Gap, key subgroupKey, s *subgroupGaps) []Gap {
+	if s.doesNotExist == 0 {
+		return gaps
+	}
+	gaps = d.add(gaps, Gap{
+		Reason:     GapReasonDoesNotExist,
+		GroupID:    key.groupID,
+		SubgroupID: key.subgroupID,
+		ObjectID:   s.next - s.doesNotExist,
+		Count:      s.doesNotExist,
+	})
+	s.doesNotExist = 0
+	return gaps
+}
+
+// subgroupObject checks an object received on a subgroup stream for objects
+// that don't exist or were skipped since the previous object of the same
+// subgroup.
+func (d *gapDetector) subgroupObject(o *Object) {
+	key := subgroupKey{groupID: o.GroupID, subgroupID: o.SubGroupID}
+	var gaps []Gap
+
+	d.lock.Lock()
+	s, ok := d.streams[key]
+	if !ok {
+		s = &subgroupGaps{
+			next:         o.ObjectID,
+			doesNotExist: 0,
+		}
+		d.streams[key] = s
+		var g *groupGaps
+		g, gaps = d.group(o.GroupID, gaps)
+		if g != nil {
+			g.subgroups[o.SubGroupID] = struct{}{}
+		}
+	}
+	if o.ObjectID < s.next {
+		// Objects of a subgroup must have increasing object IDs.
+		d.lock.Unlock()
+		d.report(gaps)
+		return
+	}
+	if o.ObjectID > s.next || o.Status != ObjectStatusObjectDoesNotExist {
+		gaps = d.flushDoesNotExist(gaps, key, s)
+	}
+	if o.ObjectID > s.next {
+		gaps = d.add(gaps, Gap{
+			Reason:     GapReasonObjectsSkipped,
+			GroupID:    o.GroupID,
+			SubgroupID: o.SubGroupID,
+			ObjectID:   s.next,
+			Count:      o.ObjectID - s.next,
+		})
+	}
+	if o.Status == ObjectStatusObjectDoesNotExist {
+		s.doesNotExist++
+	}
+	s.next = o.ObjectID + 1
+	d.lock.Unlock()
+
+	d.report(gaps)
+}
+
+// subgroupEnd reports the end of a subgroup stream after last was received.
+// If reset is true, the stream was reset before the end of the subgroup. If
+// last is nil, the stream ended before the first object and a reset is only
+// counted.
+func (d *gapDetector) subgroupEnd(last *Location, subgroupID uint64, reset bool) {
+	if reset {
+		d.streamResets.Add(1)
+	}
+	if last == nil {
+		return
+	}
+	key := subgroupKey{groupID: last.Group, subgroupID: subgroupID}
+	var gaps []Gap
+
+	d.lock.Lock()
+	if s, ok := d.streams[key]; ok {
+		gaps = d.flushDoesNotExist(gaps, key, s)
+		delete(d.streams, key)
+	}
+	d.lock.Unlock()
+
+	if reset {
+		gaps = append(gaps, Gap{
+			Reason:     GapReasonStreamReset,
+			GroupID:    last.Group,
+			SubgroupID: subgroupID,
+			ObjectID:   last.Object + 1,
+			Count:      0,
+		})
+	}
+	d.report(gaps)
+}
+
+// datagram checks an object received in a datagram for missing datagram
+// objects. Only gaps after the first datagram of a group are detected.
+// Datagrams of groups that were already checked are not tracked.
+func (d *gapDetector) datagram(o *Object) {
+	d.object(o)
+
+	d.lock.Lock()
+	g, gaps := d.group(o.GroupID, nil)
+	if g != nil {
+		if g.hasDatagram && o.ObjectID > g.datagramObject+1 {
+			first := g.datagramObject + 1
+			gaps = d.add(gaps, Gap{
+				Reason:     GapReasonDatagramLoss,
+				GroupID:    o.GroupID,
+				SubgroupID: first,
+				ObjectID:   first,
+				Count:      o.ObjectID - first,
+			})
+		}
+		// Reordered datagrams may have been reported as lost.
+		if !g.hasDatagram || o.ObjectID > g.datagramObject {
+			g.hasDatagram = true
+			g.datagramObject = o.ObjectID
+		}
+	}
+	d.lock.Unlock()
+
+	d.report(gaps)
+}
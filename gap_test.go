@@ -0,0 +1,201 @@
+package moqtransport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGapDetector(t *testing.T) {
+	newDetector := func() (*gapDetector, *[]Gap) {
+		gaps := []Gap{}
+		return newGapDetector(func(g Gap) {
+			gaps = append(gaps, g)
+		}), &gaps
+	}
+	datagram := func(groupID, objectID uint64) *Object {
+		return &Object{
+			GroupID:              groupID,
+			SubGroupID:           objectID,
+			ObjectID:             objectID,
+			ForwardingPreference: ObjectForwardingPreferenceDatagram,
+		}
+	}
+	object := func(groupID, subgroupID, objectID uint64, status ObjectStatus) *Object {
+		return &Object{
+			GroupID:    groupID,
+			SubGroupID: subgroupID,
+			ObjectID:   objectID,
+			Status:     status,
+		}
+	}
+
+	t.Run("does_not_exist", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.object(object(1, 2, 3, ObjectStatusNormal))
+		d.object(object(1, 2, 4, ObjectStatusObjectDoesNotExist))
+		assert.Equal(t, []Gap{{
+			Reason:     GapReasonDoesNotExist,
+			GroupID:    1,
+			SubgroupID: 2,
+			ObjectID:   4,
+			Count:      1,
+		}}, *gaps)
+		assert.Equal(t, GapStats{DoesNotExist: 1}, d.stats())
+	})
+
+	t.Run("does_not_exist_range", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.subgroupObject(object(0, 0, 0, ObjectStatusNormal))
+		d.subgroupObject(object(0, 0, 1, ObjectStatusObjectDoesNotExist))
+		d.subgroupObject(object(0, 0, 2, ObjectStatusObjectDoesNotExist))
+		assert.Empty(t, *gaps)
+		d.subgroupObject(object(0, 0, 3, ObjectStatusNormal))
+		d.subgroupObject(object(0, 0, 4, ObjectStatusObjectDoesNotExist))
+		d.subgroupEnd(&Location{Group: 0, Object: 4}, 0, false)
+		assert.Equal(t, []Gap{
+			{
+				Reason:     GapReasonDoesNotExist,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   1,
+				Count:      2,
+			},
+			{
+				Reason:     GapReasonDoesNotExist,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   4,
+				Count:      1,
+			},
+		}, *gaps)
+		assert.Equal(t, GapStats{DoesNotExist: 3}, d.stats())
+	})
+
+	t.Run("objects_skipped", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.subgroupObject(object(0, 0, 0, ObjectStatusNormal))
+		d.subgroupObject(object(0, 1, 5, ObjectStatusNormal))
+		d.subgroupObject(object(0, 0, 1, ObjectStatusObjectDoesNotExist))
+		d.subgroupObject(object(0, 0, 4, ObjectStatusNormal))
+		d.subgroupObject(object(0, 1, 6, ObjectStatusNormal))
+		assert.Equal(t, []Gap{
+			{
+				Reason:     GapReasonDoesNotExist,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   1,
+				Count:      1,
+			},
+			{
+				Reason:     GapReasonObjectsSkipped,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   2,
+				Count:      2,
+			},
+		}, *gaps)
+		assert.Equal(t, GapStats{DoesNotExist: 1, ObjectsSkipped: 2}, d.stats())
+	})
+
+	t.Run("subgroups_missing", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.subgroupObject(object(0, 1, 0, ObjectStatusNormal))
+		d.subgroupObject(object(0, 4, 0, ObjectStatusNormal))
+		d.subgroupObject(object(1, 0, 0, ObjectStatusNormal))
+		// Subgroups of group 0 may still arrive.
+		d.subgroupObject(object(0, 3, 0, ObjectStatusNormal))
+		assert.Empty(t, *gaps)
+		d.subgroupObject(object(2, 0, 0, ObjectStatusNormal))
+		assert.Equal(t, []Gap{
+			{
+				Reason:     GapReasonSubgroupsMissing,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   0,
+				Count:      1,
+			},
+			{
+				Reason:     GapReasonSubgroupsMissing,
+				GroupID:    0,
+				SubgroupID: 2,
+				ObjectID:   0,
+				Count:      1,
+			},
+		}, *gaps)
+		assert.Equal(t, GapStats{SubgroupsMissing: 2}, d.stats())
+
+		// Group 0 is no longer tracked.
+		d.subgroupObject(object(0, 6, 0, ObjectStatusNormal))
+		d.subgroupObject(object(3, 0, 0, ObjectStatusNormal))
+		assert.Len(t, *gaps, 2)
+	})
+
+	t.Run("groups_skipped", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.subgroupObject(object(1, 0, 0, ObjectStatusNormal))
+		d.datagram(datagram(4, 0))
+		d.subgroupObject(object(3, 0, 0, ObjectStatusNormal))
+		d.subgroupObject(object(9, 0, 0, ObjectStatusNormal))
+		assert.Equal(t, []Gap{
+			{
+				Reason:     GapReasonGroupsSkipped,
+				GroupID:    2,
+				SubgroupID: 0,
+				ObjectID:   0,
+				Count:      1,
+			},
+			{
+				Reason:     GapReasonGroupsSkipped,
+				GroupID:    5,
+				SubgroupID: 0,
+				ObjectID:   0,
+				Count:      3,
+			},
+		}, *gaps)
+		assert.Equal(t, GapStats{GroupsSkipped: 4}, d.stats())
+	})
+
+	t.Run("datagram_loss", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.datagram(datagram(0, 2))
+		d.datagram(datagram(0, 5))
+		// Reordered datagrams are ignored.
+		d.datagram(datagram(0, 4))
+		d.datagram(datagram(1, 0))
+		d.datagram(datagram(0, 6))
+		d.datagram(datagram(1, 1))
+		assert.Equal(t, []Gap{{
+			Reason:     GapReasonDatagramLoss,
+			GroupID:    0,
+			SubgroupID: 3,
+			ObjectID:   3,
+			Count:      2,
+		}}, *gaps)
+		assert.Equal(t, GapStats{DatagramsLost: 2}, d.stats())
+	})
+
+	t.Run("stream_reset", func(t *testing.T) {
+		d, gaps := newDetector()
+		d.subgroupEnd(nil, 0, true)
+		d.subgroupObject(object(1, 2, 3, ObjectStatusObjectDoesNotExist))
+		d.subgroupEnd(&Location{Group: 1, Object: 3}, 2, true)
+		assert.Equal(t, []Gap{
+			{
+				Reason:     GapReasonDoesNotExist,
+				GroupID:    1,
+				SubgroupID: 2,
+				ObjectID:   3,
+				Count:      1,
+			},
+			{
+				Reason:     GapReasonStreamReset,
+				GroupID:    1,
+				SubgroupID: 2,
+				ObjectID:   4,
+				Count:      0,
+			},
+		}, *gaps)
+		assert.Equal(t, GapStats{DoesNotExist: 1, StreamResets: 2}, d.stats())
+	})
+}
@@ -41,6 +41,9 @@ type Message struct {
 	ErrorCode uint64
 	// ReasonPhrase is set if the message is an error message.
 	ReasonPhrase string
+
+	// Parameters is set if the message carries parameters.
+	Parameters KVPList
 }
 
 // ResponseWriter can be used to respond to messages that expect a response.
@@ -54,11 +57,16 @@ type ResponseWriter interface {
 
 // Publisher is the interface implemented by SubscribeResponseWriters
 type Publisher interface {
-	// SendDatagram sends an object in a datagram.
-	SendDatagram(Object) error
+	// SendDatagram sends an object in a datagram. Objects with a Status other
+	// than ObjectStatusNormal are sent as status datagrams and must not carry
+	// a payload. If the object is too large for a datagram, SendDatagram
+	// returns a *DatagramTooLargeError unless the WithDatagramStreamFallback
+	// option is set, in which case the object is sent on a new subgroup stream
+	// containing only this object.
+	SendDatagram(Object, ...DatagramOption) error
 
 	// OpenSubgroup opens and returns a new subgroup.
-	OpenSubgroup(groupID, subgroupID uint64, priority uint8) (*Subgroup, error)
+	OpenSubgroup(groupID, subgroupID uint64, priority uint8, options ...SubgroupOption) (*Subgroup, error)
 
 	// CloseWithError closes the track and sends SUBSCRIBE_DONE with code and
 	// reason.
@@ -70,6 +78,31 @@ type Publisher interface {
 type FetchPublisher interface {
 	// OpenFetchStream opens and returns a new fetch stream.
 	FetchStream() (*FetchStream, error)
+
+	// AcceptFromStore answers the FETCH with the objects of the requested
+	// range held by the ObjectStore of the Session. It sends FETCH_OK and
+	// writes the objects to a new fetch stream, or sends FETCH_ERROR if the
+	// store holds no objects in the range. If the store does not hold any
+	// objects of the track, AcceptFromStore returns ErrTrackNotStored
+	// without answering the FETCH, so that the handler can answer it
+	// otherwise.
+	AcceptFromStore() error
+}
+
+// JoiningFetchPublisher is the interface implemented by ResponseWriters of
+// joining Fetch messages. The Namespace and Track of the Message are those
+// of the joined subscription.
+type JoiningFetchPublisher interface {
+	FetchPublisher
+
+	// JoiningRequestID returns the request ID of the subscription joined by
+	// the fetch.
+	JoiningRequestID() uint64
+
+	// Range returns the locations of the first and the last object of the
+	// fetch. The range ends at the largest location sent in the SUBSCRIBE_OK
+	// of the joined subscription.
+	Range() (start, end Location)
 }
 
 // StatusRequestHandler is the interface implemented by ResponseWriters of
@@ -109,6 +142,19 @@ func (f SubscribeHandlerFunc) HandleSubscribe(rw *SubscribeResponseWriter, m *Su
 	f(rw, m)
 }
 
+// FetchHandler is the handler interface for handling FETCH messages.
+type FetchHandler interface {
+	HandleFetch(*FetchResponseWriter, *FetchMessage)
+}
+
+// FetchHandlerFunc is a type that implements FetchHandler.
+type FetchHandlerFunc func(*FetchResponseWriter, *FetchMessage)
+
+// HandleFetch implements FetchHandler.
+func (f FetchHandlerFunc) HandleFetch(rw *FetchResponseWriter, m *FetchMessage) {
+	f(rw, m)
+}
+
 // SubscribeUpdateHandler is the handler interface for handling SUBSCRIBE_UPDATE messages.
 type SubscribeUpdateHandler interface {
 	HandleSubscribeUpdate(*SubscribeUpdateMessage)
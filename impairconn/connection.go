@@ -0,0 +1,380 @@
+// Package impairconn wraps a moqtransport.Connection to simulate an impaired
+// network.
+//
+// The wrapper impairs what is sent on the connection: datagrams can be lost
+// or reordered, data written on streams can be delayed and limited in
+// bandwidth, streams can be reset and the connection can be dropped. Wrap
+// both ends of a connection to impair both directions. All random decisions
+// are drawn from a seeded source, so that a sequence of operations is
+// impaired the same way in every run. The decisions on a stream are drawn
+// from a source derived from the seed and the stream ID, so that they do not
+// depend on the order in which concurrent streams are written.
+package impairconn
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// reorderTimeout is the time after which a datagram held back for
+// reordering is sent if no other datagram is sent.
+const reorderTimeout = 10 * time.Millisecond
+
+// defaultStreamQueue is the default number of bytes queued per stream.
+const defaultStreamQueue = 64 * 1024
+
+// ErrStreamReset is returned by Write on a stream that was reset by the
+// wrapper or with Reset.
+var ErrStreamReset = errors.New("stream reset")
+
+var errStreamClosed = errors.New("write on closed stream")
+
+// Option is a functional option for configuring a Conn.
+type Option func(*Options)
+
+// Options contains the impairments of a Conn.
+type Options struct {
+	// Seed seeds the source of the random decisions
+	Seed uint64
+
+	// DatagramLoss is the probability that a datagram is dropped
+	DatagramLoss float64
+
+	// DatagramReorder is the probability that a datagram is held back and
+	// sent after the next datagram
+	DatagramReorder float64
+
+	// StreamDelay is the time data written on a stream is delayed
+	StreamDelay time.Duration
+
+	// StreamBandwidth limits the number of bytes per second sent on each
+	// stream. Zero means unlimited.
+	StreamBandwidth int64
+
+	// StreamQueue is the maximum number of bytes queued on each delayed or
+	// paced stream
+	StreamQueue int
+
+	// StreamReset is the probability that a write resets a unidirectional
+	// stream
+	StreamReset float64
+
+	// StreamResetCode is the error code of streams reset by the wrapper
+	StreamResetCode uint32
+
+	// ConnectionDrop is the probability that opening a stream or sending a
+	// datagram drops the connection
+	ConnectionDrop float64
+
+	// ConnectionDropCode is the error code of the connection when it is
+	// dropped
+	ConnectionDropCode uint64
+}
+
+// WithSeed sets the seed of the random decisions. Default is 0.
+func WithSeed(seed uint64) Option {
+	return func(opts *Options) {
+		opts.Seed = seed
+	}
+}
+
+// WithDatagramLoss drops each datagram with probability p. Default is 0.
+func WithDatagramLoss(p float64) Option {
+	return func(opts *Options) {
+		opts.DatagramLoss = p
+	}
+}
+
+// WithDatagramReordering holds back each datagram with probability p and
+// sends it after the next datagram, or after 10ms if no other datagram is
+// sent. Default is 0.
+func WithDatagramReordering(p float64) Option {
+	return func(opts *Options) {
+		opts.DatagramReorder = p
+	}
+}
+
+// WithStreamDelay delays the data and the end of streams by d. Default is 0.
+func WithStreamDelay(d time.Duration) Option {
+	return func(opts *Options) {
+		opts.StreamDelay = d
+	}
+}
+
+// WithStreamBandwidth limits the data sent on each stream to bytesPerSecond.
+// Default is 0 (unlimited).
+func WithStreamBandwidth(bytesPerSecond int64) Option {
+	return func(opts *Options) {
+		opts.StreamBandwidth = bytesPerSecond
+	}
+}
+
+// WithStreamQueue sets the maximum number of bytes queued on each stream
+// with a delay or bandwidth limit. Write blocks while the queue is full, like
+// a stream without flow control credit. A write larger than n is queued once
+// the queue is empty. Default is 64KiB.
+func WithStreamQueue(n int) Option {
+	return func(opts *Options) {
+		opts.StreamQueue = n
+	}
+}
+
+// WithStreamResets resets a unidirectional stream with code instead of
+// writing to it with probability p per write. Bidirectional streams, such as
+// the control stream, are not reset. Default is 0.
+func WithStreamResets(p float64, code uint32) Option {
+	return func(opts *Options) {
+		opts.StreamReset = p
+		opts.StreamResetCode = code
+	}
+}
+
+// WithConnectionDrops closes the connection with code with probability p
+// per opened stream and sent datagram. Default is 0.
+func WithConnectionDrops(p float64, code uint64) Option {
+	return func(opts *Options) {
+		opts.ConnectionDrop = p
+		opts.ConnectionDropCode = code
+	}
+}
+
+// Stats counts the impairments applied by a Conn.
+type Stats struct {
+	DatagramsDropped   uint64
+	DatagramsReordered uint64
+	StreamsReset       uint64
+	ConnectionDropped  bool
+}
+
+// Conn is a moqtransport.Connection that impairs the data sent on the
+// wrapped connection.
+type Conn struct {
+	conn moqtransport.Connection
+	opts Options
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	stats Stats
+
+	// held is the datagram held back for reordering.
+	held      []byte
+	heldTimer *time.Timer
+}
+
+var _ moqtransport.Connection = (*Conn)(nil)
+
+// New wraps conn.
+//
+// Default behavior when no options are provided:
+//   - Seed: 0
+//   - DatagramLoss: 0
+//   - DatagramReorder: 0
+//   - StreamDelay: 0
+//   - StreamBandwidth: 0 (unlimited)
+//   - StreamQueue: 64KiB
+//   - StreamReset: 0
+//   - ConnectionDrop: 0
+func New(conn moqtransport.Connection, options ...Option) *Conn {
+	opts := Options{
+		Seed:               0,
+		DatagramLoss:       0,
+		DatagramReorder:    0,
+		StreamDelay:        0,
+		StreamBandwidth:    0,
+		StreamQueue:        defaultStreamQueue,
+		StreamReset:        0,
+		StreamResetCode:    0,
+		ConnectionDrop:     0,
+		ConnectionDropCode: 0,
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+	return &Conn{
+		conn:      conn,
+		opts:      opts,
+		lock:      sync.Mutex{},
+		rand:      rand.New(rand.NewPCG(opts.Seed, opts.Seed)),
+		stats:     Stats{},
+		held:      nil,
+		heldTimer: nil,
+	}
+}
+
+// Stats returns the impairments applied so far.
+func (c *Conn) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
+
+// Drop closes the wrapped connection with the ConnectionDropCode.
+func (c *Conn) Drop() {
+	c.lock.Lock()
+	c.stats.ConnectionDropped = true
+	c.lock.Unlock()
+	_ = c.conn.CloseWithError(c.opts.ConnectionDropCode, "connection dropped")
+}
+
+// chance returns true with probability p. c.lock must be held.
+func (c *Conn) chance(p float64) bool {
+	return chance(c.rand, p)
+}
+
+// chance returns true with probability p drawn from r.
+func chance(r *rand.Rand, p float64) bool {
+	return p > 0 && r.Float64() < p
+}
+
+// maybeDrop drops the connection with the configured probability.
+func (c *Conn) maybeDrop() {
+	c.lock.Lock()
+	drop := c.chance(c.opts.ConnectionDrop)
+	c.lock.Unlock()
+	if drop {
+		c.Drop()
+	}
+}
+
+// AcceptStream implements moqtransport.Connection.
+func (c *Conn) AcceptStream(ctx context.Context) (moqtransport.Stream, error) {
+	s, err := c.conn.AcceptStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrapStream(s), nil
+}
+
+// AcceptUniStream implements moqtransport.Connection. Received streams are
+// not impaired.
+func (c *Conn) AcceptUniStream(ctx context.Context) (moqtransport.ReceiveStream, error) {
+	return c.conn.AcceptUniStream(ctx)
+}
+
+// OpenStream implements moqtransport.Connection.
+func (c *Conn) OpenStream() (moqtransport.Stream, error) {
+	c.maybeDrop()
+	s, err := c.conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return c.wrapStream(s), nil
+}
+
+// OpenStreamSync implements moqtransport.Connection.
+func (c *Conn) OpenStreamSync(ctx context.Context) (moqtransport.Stream, error) {
+	c.maybeDrop()
+	s, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrapStream(s), nil
+}
+
+// OpenUniStream implements moqtransport.Connection.
+func (c *Conn) OpenUniStream() (moqtransport.SendStream, error) {
+	c.maybeDrop()
+	s, err := c.conn.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	return newSendStream(c, s, true), nil
+}
+
+// OpenUniStreamSync implements moqtransport.Connection.
+func (c *Conn) OpenUniStreamSync(ctx context.Context) (moqtransport.SendStream, error) {
+	c.maybeDrop()
+	s, err := c.conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newSendStream(c, s, true), nil
+}
+
+func (c *Conn) wrapStream(s moqtransport.Stream) moqtransport.Stream {
+	return &stream{
+		ReceiveStream: s,
+		sendStream:    newSendStream(c, s, false),
+	}
+}
+
+// SendDatagram implements moqtransport.Connection. Lost datagrams are
+// dropped silently.
+func (c *Conn) SendDatagram(b []byte) error {
+	c.maybeDrop()
+	c.lock.Lock()
+	if c.chance(c.opts.DatagramLoss) {
+		c.stats.DatagramsDropped++
+		c.lock.Unlock()
+		return nil
+	}
+	if c.held == nil && c.chance(c.opts.DatagramReorder) {
+		c.stats.DatagramsReordered++
+		c.held = append([]byte(nil), b...)
+		c.heldTimer = time.AfterFunc(reorderTimeout, c.flushHeld)
+		c.lock.Unlock()
+		return nil
+	}
+	held := c.takeHeld()
+	c.lock.Unlock()
+
+	if err := c.conn.SendDatagram(b); err != nil {
+		return err
+	}
+	if held != nil {
+		return c.conn.SendDatagram(held)
+	}
+	return nil
+}
+
+// takeHeld returns and clears the datagram held back for reordering. c.lock
+// must be held.
+func (c *Conn) takeHeld() []byte {
+	held := c.held
+	c.held = nil
+	if c.heldTimer != nil {
+		c.heldTimer.Stop()
+		c.heldTimer = nil
+	}
+	return held
+}
+
+// flushHeld sends the datagram held back for reordering.
+func (c *Conn) flushHeld() {
+	c.lock.Lock()
+	held := c.takeHeld()
+	c.lock.Unlock()
+	if held != nil {
+		_ = c.conn.SendDatagram(held)
+	}
+}
+
+// ReceiveDatagram implements moqtransport.Connection.
+func (c *Conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return c.conn.ReceiveDatagram(ctx)
+}
+
+// CloseWithError implements moqtransport.Connection.
+func (c *Conn) CloseWithError(code uint64, reason string) error {
+	return c.conn.CloseWithError(code, reason)
+}
+
+// Context implements moqtransport.Connection.
+func (c *Conn) Context() context.Context {
+	return c.conn.Context()
+}
+
+// Protocol implements moqtransport.Connection.
+func (c *Conn) Protocol() moqtransport.Protocol {
+	return c.conn.Protocol()
+}
+
+// Perspective implements moqtransport.Connection.
+func (c *Conn) Perspective() moqtransport.Perspective {
+	return c.conn.Perspective()
+}
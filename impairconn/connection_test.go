@@ -0,0 +1,220 @@
+package impairconn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/mengelbart/moqtransport/memconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiveDatagrams returns the datagrams received on conn until no datagram
+// arrives for 50ms.
+func receiveDatagrams(t *testing.T, conn moqtransport.Connection) []string {
+	received := []string{}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		d, err := conn.ReceiveDatagram(ctx)
+		cancel()
+		if err != nil {
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			return received
+		}
+		received = append(received, string(d))
+	}
+}
+
+func TestConn(t *testing.T) {
+	t.Run("datagram_loss", func(t *testing.T) {
+		run := func() ([]string, Stats) {
+			client, server := memconn.Pipe()
+			conn := New(client, WithSeed(7), WithDatagramLoss(0.3))
+			for i := range 100 {
+				assert.NoError(t, conn.SendDatagram(fmt.Appendf(nil, "%d", i)))
+			}
+			return receiveDatagrams(t, server), conn.Stats()
+		}
+		received, stats := run()
+		assert.NotZero(t, stats.DatagramsDropped)
+		assert.Len(t, received, 100-int(stats.DatagramsDropped))
+
+		// The same seed drops the same datagrams.
+		again, _ := run()
+		assert.Equal(t, received, again)
+	})
+
+	t.Run("datagram_reordering", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithDatagramReordering(1))
+		for i := range 5 {
+			assert.NoError(t, conn.SendDatagram(fmt.Appendf(nil, "%d", i)))
+		}
+		// The last datagram is sent after the reorder timeout.
+		assert.Equal(t, []string{"1", "0", "3", "2", "4"}, receiveDatagrams(t, server))
+		assert.Equal(t, uint64(3), conn.Stats().DatagramsReordered)
+	})
+
+	t.Run("stream_delay", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithStreamDelay(50*time.Millisecond))
+		start := time.Now()
+		s, err := conn.OpenUniStream()
+		assert.NoError(t, err)
+		_, err = s.Write([]byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, s.Close())
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("stream_bandwidth", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithStreamBandwidth(2000))
+		start := time.Now()
+		s, err := conn.OpenUniStream()
+		assert.NoError(t, err)
+		_, err = s.Write(make([]byte, 200))
+		assert.NoError(t, err)
+		assert.NoError(t, s.Close())
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Len(t, data, 200)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("stream_queue", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithStreamBandwidth(1000), WithStreamQueue(100))
+		s, err := conn.OpenUniStream()
+		assert.NoError(t, err)
+		_, err = s.Write(make([]byte, 100))
+		assert.NoError(t, err)
+
+		// The queue is full until the first write was sent, which takes
+		// 100ms.
+		start := time.Now()
+		_, err = s.Write(make([]byte, 10))
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		buf := make([]byte, 110)
+		_, err = io.ReadFull(r, buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("stream_queue_reset", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithStreamBandwidth(1), WithStreamQueue(100))
+		s, err := conn.OpenUniStream()
+		assert.NoError(t, err)
+
+		// The queue is full for 100s at 1 byte per second, so the second
+		// write blocks until the stream is reset. A write that starts after
+		// the reset fails the same way.
+		_, err = s.Write(make([]byte, 100))
+		assert.NoError(t, err)
+		written := make(chan error)
+		go func() {
+			_, err := s.Write(make([]byte, 10))
+			written <- err
+		}()
+		select {
+		case err := <-written:
+			assert.Fail(t, "write did not block", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		s.Reset(1)
+		assert.ErrorIs(t, <-written, ErrStreamReset)
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.Equal(t, &moqtransport.StreamResetError{ErrorCode: 1}, err)
+	})
+
+	t.Run("stream_reset_per_stream", func(t *testing.T) {
+		// run writes once to each of 20 streams in order and returns the
+		// IDs of the reset streams.
+		run := func(order []int) []uint64 {
+			client, _ := memconn.Pipe()
+			conn := New(client, WithSeed(3), WithStreamResets(0.5, 5))
+			streams := []moqtransport.SendStream{}
+			for range 20 {
+				s, err := conn.OpenUniStream()
+				assert.NoError(t, err)
+				streams = append(streams, s)
+			}
+			reset := []uint64{}
+			for _, i := range order {
+				if _, err := streams[i].Write([]byte("hello")); err != nil {
+					assert.ErrorIs(t, err, ErrStreamReset)
+					reset = append(reset, streams[i].StreamID())
+				}
+			}
+			slices.Sort(reset)
+			return reset
+		}
+		order := []int{}
+		for i := range 20 {
+			order = append(order, i)
+		}
+		reset := run(order)
+		assert.NotEmpty(t, reset)
+		assert.Less(t, len(reset), 20)
+
+		// The same streams are reset if they are written in another order.
+		slices.Reverse(order)
+		assert.Equal(t, reset, run(order))
+	})
+
+	t.Run("stream_reset", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithStreamResets(1, 5))
+
+		// Bidirectional streams are not reset.
+		bidi, err := conn.OpenStream()
+		assert.NoError(t, err)
+		_, err = bidi.Write([]byte("control"))
+		assert.NoError(t, err)
+
+		s, err := conn.OpenUniStream()
+		assert.NoError(t, err)
+		_, err = s.Write([]byte("hello"))
+		assert.ErrorIs(t, err, ErrStreamReset)
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		_, err = r.Read(make([]byte, 5))
+		assert.Equal(t, &moqtransport.StreamResetError{ErrorCode: 5}, err)
+		assert.Equal(t, uint64(1), conn.Stats().StreamsReset)
+	})
+
+	t.Run("connection_drop", func(t *testing.T) {
+		client, server := memconn.Pipe()
+		conn := New(client, WithConnectionDrops(1, 9))
+		_, err := conn.OpenUniStream()
+		assert.Error(t, err)
+		assert.True(t, conn.Stats().ConnectionDropped)
+
+		<-server.Context().Done()
+		assert.Equal(t, &memconn.ApplicationError{
+			Remote:       true,
+			ErrorCode:    9,
+			ErrorMessage: "connection dropped",
+		}, context.Cause(server.Context()))
+	})
+}
@@ -0,0 +1,240 @@
+package impairconn
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// pacingInterval is the interval in which data is sent on streams with a
+// bandwidth limit.
+const pacingInterval = 10 * time.Millisecond
+
+// stream is a bidirectional stream whose sending side is impaired.
+type stream struct {
+	moqtransport.ReceiveStream
+	*sendStream
+}
+
+// StreamID implements moqtransport.Stream.
+func (s *stream) StreamID() uint64 {
+	return s.sendStream.StreamID()
+}
+
+// write is data or the end of a stream queued for sending.
+type write struct {
+	data  []byte
+	close bool
+	at    time.Time
+}
+
+// sendStream delays, paces and resets the data written to a stream. Without
+// delay and bandwidth limit, writes are passed through.
+type sendStream struct {
+	conn   *Conn
+	stream moqtransport.SendStream
+
+	// resettable is set for unidirectional streams, which are reset
+	// randomly. Bidirectional streams are not reset, because resetting the
+	// control stream would end the session.
+	resettable bool
+
+	lock sync.Mutex
+
+	// rand is the source of the random decisions on the stream, derived
+	// from the seed and the stream ID.
+	rand *rand.Rand
+
+	// queue holds the writes that were not sent yet. pending is the number
+	// of bytes in queue and in the write being sent. space is signaled when
+	// pending shrinks or the stream fails.
+	queue   []write
+	pending int
+	space   *sync.Cond
+
+	running bool
+	closed  bool
+	err     error
+}
+
+func newSendStream(c *Conn, s moqtransport.SendStream, resettable bool) *sendStream {
+	ss := &sendStream{
+		conn:       c,
+		stream:     s,
+		resettable: resettable,
+		lock:       sync.Mutex{},
+		rand:       rand.New(rand.NewPCG(c.opts.Seed, s.StreamID())),
+		queue:      []write{},
+		pending:    0,
+		space:      nil,
+		running:    false,
+		closed:     false,
+		err:        nil,
+	}
+	ss.space = sync.NewCond(&ss.lock)
+	return ss
+}
+
+// queued reports whether writes are queued instead of passed through.
+func (s *sendStream) queued() bool {
+	return s.conn.opts.StreamDelay > 0 || s.conn.opts.StreamBandwidth > 0
+}
+
+// Write implements moqtransport.SendStream. Queued writes block while the
+// queue is full and errors of the wrapped stream are returned by later
+// writes.
+func (s *sendStream) Write(b []byte) (int, error) {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return 0, s.err
+	}
+	if s.closed {
+		s.lock.Unlock()
+		return 0, errStreamClosed
+	}
+	if s.resettable && chance(s.rand, s.conn.opts.StreamReset) {
+		s.conn.lock.Lock()
+		s.conn.stats.StreamsReset++
+		s.conn.lock.Unlock()
+		s.resetLocked()
+		s.lock.Unlock()
+		s.stream.Reset(s.conn.opts.StreamResetCode)
+		return 0, ErrStreamReset
+	}
+	if !s.queued() {
+		s.lock.Unlock()
+		return s.stream.Write(b)
+	}
+	for s.err == nil && s.pending > 0 && s.pending+len(b) > s.conn.opts.StreamQueue {
+		s.space.Wait()
+	}
+	if s.err != nil {
+		s.lock.Unlock()
+		return 0, s.err
+	}
+	s.enqueue(write{
+		data:  append([]byte(nil), b...),
+		close: false,
+		at:    time.Now().Add(s.conn.opts.StreamDelay),
+	})
+	s.lock.Unlock()
+	return len(b), nil
+}
+
+// Close implements moqtransport.SendStream.
+func (s *sendStream) Close() error {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return s.err
+	}
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	if !s.queued() {
+		s.lock.Unlock()
+		return s.stream.Close()
+	}
+	s.enqueue(write{
+		data:  nil,
+		close: true,
+		at:    time.Now().Add(s.conn.opts.StreamDelay),
+	})
+	s.lock.Unlock()
+	return nil
+}
+
+// Reset implements moqtransport.SendStream. Queued data is discarded.
+func (s *sendStream) Reset(code uint32) {
+	s.lock.Lock()
+	s.resetLocked()
+	s.lock.Unlock()
+	s.stream.Reset(code)
+}
+
+// resetLocked discards the queue and wakes blocked writes. s.lock must be
+// held.
+func (s *sendStream) resetLocked() {
+	s.queue = nil
+	s.pending = 0
+	if s.err == nil {
+		s.err = ErrStreamReset
+	}
+	s.space.Broadcast()
+}
+
+// StreamID implements moqtransport.SendStream.
+func (s *sendStream) StreamID() uint64 {
+	return s.stream.StreamID()
+}
+
+// enqueue queues w and starts the goroutine sending the queue. s.lock must be
+// held.
+func (s *sendStream) enqueue(w write) {
+	s.queue = append(s.queue, w)
+	s.pending += len(w.data)
+	if s.running {
+		return
+	}
+	s.running = true
+	go s.run()
+}
+
+func (s *sendStream) run() {
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 || s.err != nil {
+			s.running = false
+			s.lock.Unlock()
+			return
+		}
+		w := s.queue[0]
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+
+		time.Sleep(time.Until(w.at))
+		var err error
+		if w.close {
+			err = s.stream.Close()
+		} else {
+			err = s.send(w.data)
+		}
+		s.lock.Lock()
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.pending = max(s.pending-len(w.data), 0)
+		s.space.Broadcast()
+		s.lock.Unlock()
+	}
+}
+
+// send writes data to the wrapped stream, paced to the bandwidth limit.
+func (s *sendStream) send(data []byte) error {
+	bandwidth := s.conn.opts.StreamBandwidth
+	if bandwidth <= 0 {
+		_, err := s.stream.Write(data)
+		return err
+	}
+	chunk := max(int(bandwidth*int64(pacingInterval)/int64(time.Second)), 1)
+	for len(data) > 0 {
+		s.lock.Lock()
+		err := s.err
+		s.lock.Unlock()
+		if err != nil {
+			return err
+		}
+		n := min(chunk, len(data))
+		if _, err := s.stream.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		time.Sleep(time.Duration(int64(n) * int64(time.Second) / bandwidth))
+	}
+	return nil
+}
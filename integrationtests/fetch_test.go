@@ -84,11 +84,371 @@ func TestFetch(t *testing.T) {
 
 		o, err := rt.ReadObject(ctx2)
 		assert.NoError(t, err)
-		assert.Equal(t, &moqtransport.Object{
+		assert.EqualExportedValues(t, &moqtransport.Object{
 			GroupID:    1,
 			SubGroupID: 2,
 			ObjectID:   3,
 			Payload:    []byte("hello fetch"),
 		}, o)
 	})
+
+	t.Run("objects_iterator", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.FetchPublisher, 1)
+
+		handler := moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+			assert.NoError(t, w.Accept())
+			publisher, ok := w.(moqtransport.FetchPublisher)
+			assert.True(t, ok)
+			publisherCh <- publisher
+		})
+		_, ct, cancel := setup(t, sConn, cConn, handler)
+		defer cancel()
+
+		rt, err := ct.Fetch(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.FetchPublisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		fs, err := publisher.FetchStream()
+		assert.NoError(t, err)
+		for i := range 3 {
+			_, err = fs.WriteObject(0, 0, uint64(i), 0, []byte("hello fetch"))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, fs.Close())
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := 0
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(received), o.ObjectID)
+			received++
+		}
+		assert.Equal(t, 3, received)
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("object_store", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		store := moqtransport.NewMemoryObjectStore(1024, time.Minute)
+		handler := moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+			if m.Method != moqtransport.MessageFetch {
+				assert.Fail(t, "unexpected message passed to handler", m.Method)
+				return
+			}
+			assert.NoError(t, w.(moqtransport.FetchPublisher).AcceptFromStore())
+		})
+		serverSession := &moqtransport.Session{
+			Handler:             handler,
+			InitialMaxRequestID: 100,
+			ObjectStore:         store,
+		}
+		ct := &moqtransport.Session{
+			Handler:             handler,
+			InitialMaxRequestID: 100,
+		}
+		cancel = runSessions(t, sConn, cConn, serverSession, ct)
+		defer cancel()
+
+		tw := moqtransport.NewTrackWriter(moqtransport.WithObjectStore(store, []string{"namespace"}, "track"))
+		for range 3 {
+			for range 2 {
+				_, err := tw.WriteObject([]byte("hello fetch"))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, tw.NewGroup())
+		}
+
+		rt, err := ct.Fetch(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := []moqtransport.Location{}
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		assert.Equal(t, []moqtransport.Location{
+			{Group: 0, Object: 0},
+			{Group: 0, Object: 1},
+			{Group: 0, Object: 2}, // end of group
+		}, received)
+	})
+
+	t.Run("object_store_range", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		store := moqtransport.NewMemoryObjectStore(1024, time.Minute)
+		handler := moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+			if m.Method != moqtransport.MessageFetch {
+				assert.Fail(t, "unexpected message passed to handler", m.Method)
+				return
+			}
+			assert.NoError(t, w.(moqtransport.FetchPublisher).AcceptFromStore())
+		})
+		serverSession := &moqtransport.Session{
+			Handler:             handler,
+			InitialMaxRequestID: 100,
+			ObjectStore:         store,
+		}
+		ct := &moqtransport.Session{
+			Handler:             handler,
+			InitialMaxRequestID: 100,
+		}
+		cancel = runSessions(t, sConn, cConn, serverSession, ct)
+		defer cancel()
+
+		tw := moqtransport.NewTrackWriter(moqtransport.WithObjectStore(store, []string{"namespace"}, "track"))
+		for range 3 {
+			for range 2 {
+				_, err := tw.WriteObject([]byte("hello fetch"))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, tw.NewGroup())
+		}
+
+		rt, err := ct.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 0, Object: 1}, 1, 2),
+			moqtransport.WithFetchGroupOrder(moqtransport.GroupOrderDescending),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, moqtransport.GroupOrderDescending, rt.GroupOrder())
+		assert.False(t, rt.EndOfTrack())
+		end, ok := rt.EndLocation()
+		assert.True(t, ok)
+		assert.Equal(t, moqtransport.Location{Group: 1, Object: 1}, end)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := []moqtransport.Location{}
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		assert.Equal(t, []moqtransport.Location{
+			{Group: 1, Object: 0},
+			{Group: 1, Object: 1},
+			{Group: 0, Object: 1},
+			{Group: 0, Object: 2}, // end of group
+		}, received)
+
+		_, err = ct.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 5, Object: 0}, 6, 0),
+		)
+		assert.ErrorContains(t, err, "no objects in range")
+	})
+
+	t.Run("joining_fetch", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		store := moqtransport.NewMemoryObjectStore(1024, 0)
+		tw := moqtransport.NewTrackWriter(moqtransport.WithObjectStore(store, []string{"namespace"}, "track"))
+		subscriptionIDs := make(chan uint64, 1)
+		handler := moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+			if m.Method != moqtransport.MessageFetch {
+				assert.Fail(t, "unexpected message passed to handler", m.Method)
+				return
+			}
+			jw, ok := w.(moqtransport.JoiningFetchPublisher)
+			assert.True(t, ok)
+			assert.Equal(t, <-subscriptionIDs, jw.JoiningRequestID())
+			start, end := jw.Range()
+			assert.Equal(t, moqtransport.Location{Group: 1, Object: 0}, start)
+			assert.Equal(t, moqtransport.Location{Group: 2, Object: 1}, end)
+			assert.NoError(t, jw.AcceptFromStore())
+		})
+		serverSession := &moqtransport.Session{
+			Handler:             handler,
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, tw.Accept(w))
+				subscriptionIDs <- m.RequestID
+			}),
+			ObjectStore: store,
+		}
+		ct := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		cancel = runSessions(t, sConn, cConn, serverSession, ct)
+		defer cancel()
+
+		for range 3 {
+			assert.NoError(t, tw.NewGroup())
+			for range 2 {
+				_, err := tw.WriteObject([]byte("hello fetch"))
+				assert.NoError(t, err)
+			}
+		}
+
+		sub, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		rt, err := ct.JoiningFetch(context.Background(), sub, moqtransport.JoiningStartRelative(1))
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := []moqtransport.Location{}
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		assert.Equal(t, []moqtransport.Location{
+			{Group: 1, Object: 0},
+			{Group: 1, Object: 1},
+			{Group: 1, Object: 2}, // end of group
+			{Group: 2, Object: 0},
+			{Group: 2, Object: 1},
+		}, received)
+
+		_, err = ct.JoiningFetch(context.Background(), rt, moqtransport.JoiningStartRelative(1))
+		assert.Error(t, err)
+	})
+
+	t.Run("file_object_store", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		// Write two groups and reopen the store as after a restart.
+		dir := t.TempDir()
+		store, err := moqtransport.OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		tw := moqtransport.NewTrackWriter(moqtransport.WithObjectStore(store, []string{"namespace"}, "track"))
+		for range 2 {
+			for range 2 {
+				_, err = tw.WriteObject([]byte("hello fetch"))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, tw.NewGroup())
+		}
+		assert.NoError(t, store.Close())
+
+		store, err = moqtransport.OpenFileObjectStore(dir)
+		assert.NoError(t, err)
+		defer store.Close()
+		// A queue of one object shows that stored objects are not limited
+		// by the subscriber queue.
+		tw = moqtransport.NewTrackWriter(
+			moqtransport.WithObjectStore(store, []string{"namespace"}, "track"),
+			moqtransport.WithSubscriberQueue(1),
+		)
+		assert.Equal(t, &moqtransport.Location{Group: 1, Object: 2}, tw.LargestLocation())
+
+		serverSession := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, tw.Accept(w))
+			}),
+			FetchHandler: moqtransport.FetchHandlerFunc(func(w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+				assert.NoError(t, w.AcceptFromStore())
+			}),
+			ObjectStore: store,
+		}
+		ct := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		cancel = runSessions(t, sConn, cConn, serverSession, ct)
+		defer cancel()
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		rt, err := ct.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 0, Object: 1}, 1, 0),
+		)
+		assert.NoError(t, err)
+		received := []moqtransport.Location{}
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		assert.Equal(t, []moqtransport.Location{
+			{Group: 0, Object: 1},
+			{Group: 0, Object: 2}, // end of group
+			{Group: 1, Object: 0},
+			{Group: 1, Object: 1},
+			{Group: 1, Object: 2}, // end of group
+		}, received)
+
+		sub, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFilterType(moqtransport.FilterTypeAbsoluteStart),
+			moqtransport.WithStartLocation(moqtransport.Location{Group: 0, Object: 1}),
+		)
+		assert.NoError(t, err)
+		l, err := tw.WriteObject([]byte("hello subscribe"))
+		assert.NoError(t, err)
+		assert.Equal(t, moqtransport.Location{Group: 2, Object: 0}, l)
+
+		received = []moqtransport.Location{}
+		for range 6 {
+			o, err := sub.ReadObject(ctx)
+			assert.NoError(t, err)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		// Groups are sent on separate streams and may arrive in any order.
+		assert.ElementsMatch(t, []moqtransport.Location{
+			{Group: 0, Object: 1},
+			{Group: 0, Object: 2}, // end of group
+			{Group: 1, Object: 0},
+			{Group: 1, Object: 1},
+			{Group: 1, Object: 2}, // end of group
+			{Group: 2, Object: 0},
+		}, received)
+	})
+
+	t.Run("fetch_handler", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		serverSession := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			FetchHandler: moqtransport.FetchHandlerFunc(func(w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+				assert.Equal(t, moqtransport.Location{Group: 2, Object: 0}, m.StartLocation)
+				assert.Equal(t, uint64(2), m.EndGroup)
+				assert.Equal(t, uint64(1), m.EndObject)
+				assert.NoError(t, w.Accept(
+					moqtransport.WithEndLocation(moqtransport.Location{Group: 2, Object: 0}),
+					moqtransport.WithEndOfTrack(true),
+				))
+				fs, err := w.FetchStream()
+				assert.NoError(t, err)
+				_, err = fs.WriteObject(2, 0, 0, 0, []byte("hello fetch"))
+				assert.NoError(t, err)
+				assert.NoError(t, fs.Close())
+			}),
+		}
+		ct := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		cancel = runSessions(t, sConn, cConn, serverSession, ct)
+		defer cancel()
+
+		rt, err := ct.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 2, Object: 0}, 2, 1),
+		)
+		assert.NoError(t, err)
+		assert.True(t, rt.EndOfTrack())
+		end, ok := rt.EndLocation()
+		assert.True(t, ok)
+		assert.Equal(t, moqtransport.Location{Group: 2, Object: 0}, end)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello fetch"), o.Payload)
+	})
 }
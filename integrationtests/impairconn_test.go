@@ -0,0 +1,177 @@
+package integrationtests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/mengelbart/moqtransport/impairconn"
+	"github.com/mengelbart/moqtransport/memconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// runImpaired runs a subscriber session and a publisher session, whose sent
+// data is impaired with options, over an in-process connection. The
+// publisher accepts all subscriptions and sends their writers to the
+// returned channel.
+func runImpaired(t *testing.T, options ...impairconn.Option) (*moqtransport.Session, <-chan *moqtransport.SubscribeResponseWriter, *impairconn.Conn, func()) {
+	writerCh := make(chan *moqtransport.SubscribeResponseWriter, 1)
+	publisher := &moqtransport.Session{
+		InitialMaxRequestID: 100,
+		SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			writerCh <- w
+		}),
+	}
+	subscriber := &moqtransport.Session{
+		InitialMaxRequestID: 100,
+	}
+	client, server := memconn.Pipe()
+	impaired := impairconn.New(server, options...)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, publisher.Run(impaired))
+	}()
+	assert.NoError(t, subscriber.Run(client))
+	wg.Wait()
+	return subscriber, writerCh, impaired, func() {
+		subscriber.Close()
+		publisher.Close()
+	}
+}
+
+func TestImpairconn(t *testing.T) {
+	t.Run("datagram_loss", func(t *testing.T) {
+		subscriber, writerCh, impaired, closeSessions := runImpaired(t, impairconn.WithSeed(1), impairconn.WithDatagramLoss(0.2))
+		defer closeSessions()
+
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		w := <-writerCh
+		for i := range 100 {
+			assert.NoError(t, w.SendDatagram(moqtransport.Object{
+				GroupID:  0,
+				ObjectID: uint64(i),
+				Payload:  []byte("datagram"),
+			}))
+		}
+		dropped := int(impaired.Stats().DatagramsDropped)
+		assert.NotZero(t, dropped)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		first, last := uint64(100), uint64(0)
+		for range 100 - dropped {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			first = min(first, o.ObjectID)
+			last = max(last, o.ObjectID)
+		}
+
+		// Losses before the first and after the last received datagram are
+		// not detected.
+		lost := last - first + 1 - uint64(100-dropped)
+		assert.Equal(t, lost, rt.GapStats().DatagramsLost)
+	})
+
+	t.Run("stream_resets", func(t *testing.T) {
+		subscriber, writerCh, impaired, closeSessions := runImpaired(t, impairconn.WithSeed(1), impairconn.WithStreamResets(0.3, 7))
+		defer closeSessions()
+
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		w := <-writerCh
+		for i := range 20 {
+			sg, err := w.OpenSubgroup(uint64(i), 0, 0)
+			if err != nil {
+				continue
+			}
+			if _, err := sg.WriteObject(0, []byte("object")); err != nil {
+				continue
+			}
+			assert.NoError(t, sg.Close())
+		}
+		resets := int(impaired.Stats().StreamsReset)
+		assert.NotZero(t, resets)
+
+		// The objects of the streams that were not reset are received and the
+		// session survives the resets.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		for range 20 - resets {
+			_, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+		}
+		shortCtx, cancelShortCtx := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancelShortCtx()
+		_, err = rt.ReadObject(shortCtx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.LessOrEqual(t, rt.GapStats().StreamResets, uint64(resets))
+
+		_, err = subscriber.Subscribe(context.Background(), []string{"namespace"}, "other")
+		assert.NoError(t, err)
+	})
+
+	t.Run("delivery_timeout", func(t *testing.T) {
+		subscriber, writerCh, _, closeSessions := runImpaired(t, impairconn.WithStreamBandwidth(10_000), impairconn.WithStreamQueue(1024))
+		defer closeSessions()
+
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithDeliveryTimeout(50*time.Millisecond))
+		assert.NoError(t, err)
+		w := <-writerCh
+
+		// Sending the object takes longer than the delivery timeout, so the
+		// blocked write is aborted by resetting the stream.
+		start := time.Now()
+		sg0, err := w.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg0.WriteObject(0, make([]byte, 64*1024))
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+
+		sg1, err := w.OpenSubgroup(1, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg1.WriteObject(0, []byte("live"))
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), o.GroupID)
+		assert.Equal(t, []byte("live"), o.Payload)
+	})
+
+	t.Run("reconnection", func(t *testing.T) {
+		for attempt := range 2 {
+			subscriber, writerCh, impaired, closeSessions := runImpaired(t)
+			rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+			assert.NoError(t, err)
+			w := <-writerCh
+			sg, err := w.OpenSubgroup(0, 0, 0)
+			assert.NoError(t, err)
+			_, err = sg.WriteObject(0, []byte("object"))
+			assert.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("object"), o.Payload)
+
+			if attempt == 0 {
+				// The subscription ends when the connection is dropped, and
+				// the subscriber reconnects with a new session.
+				impaired.Drop()
+				_, err = rt.ReadObject(ctx)
+				assert.ErrorIs(t, err, moqtransport.ErrSessionClosed)
+				assert.True(t, impaired.Stats().ConnectionDropped)
+			}
+			cancel()
+			closeSessions()
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package integrationtests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/mengelbart/moqtransport/memconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemconn(t *testing.T) {
+	t.Run("subscribe", func(t *testing.T) {
+		writerCh := make(chan *moqtransport.SubscribeResponseWriter, 1)
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, w.Accept())
+				writerCh <- w
+			}),
+		}
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		client, server := memconn.Pipe()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, publisher.Run(server))
+		}()
+		assert.NoError(t, subscriber.Run(client))
+		wg.Wait()
+		defer publisher.Close()
+		defer subscriber.Close()
+
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		w := <-writerCh
+		sg, err := w.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("stream"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.Close())
+		assert.NoError(t, w.SendDatagram(moqtransport.Object{
+			GroupID:  1,
+			ObjectID: 0,
+			Payload:  []byte("datagram"),
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		received := []string{}
+		for range 2 {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			received = append(received, string(o.Payload))
+		}
+		assert.ElementsMatch(t, []string{"stream", "datagram"}, received)
+
+		// Closing the connection ends the subscription.
+		assert.NoError(t, server.CloseWithError(0, ""))
+		_, err = rt.ReadObject(ctx)
+		assert.ErrorIs(t, err, moqtransport.ErrSessionClosed)
+	})
+}
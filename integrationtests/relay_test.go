@@ -0,0 +1,679 @@
+package integrationtests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/mengelbart/moqtransport/moqrelay"
+	"github.com/mengelbart/moqtransport/quicmoq"
+	"github.com/stretchr/testify/assert"
+)
+
+// connectRelay connects session as a client to relay and returns after both
+// completed the handshake.
+func connectRelay(t *testing.T, relay *moqrelay.Relay, session *moqtransport.Session) (cancel func()) {
+	sConn, cConn, cancelConn := connect(t)
+	var wg sync.WaitGroup
+	var relaySession *moqtransport.Session
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		relaySession, err = relay.Run(quicmoq.NewServer(sConn))
+		assert.NoError(t, err)
+	}()
+	assert.NoError(t, session.Run(quicmoq.NewClient(cConn)))
+	wg.Wait()
+	return func() {
+		session.Close()
+		relay.RemoveSession(relaySession)
+		cancelConn()
+	}
+}
+
+// connectOrigin connects relay as a client to origin and returns the session
+// of the relay after both completed the handshake.
+func connectOrigin(t *testing.T, relay *moqrelay.Relay, origin *moqtransport.Session) (*moqtransport.Session, func()) {
+	sConn, cConn, cancelConn := connect(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, origin.Run(quicmoq.NewServer(sConn)))
+	}()
+	relaySession, err := relay.Run(quicmoq.NewClient(cConn))
+	assert.NoError(t, err)
+	wg.Wait()
+	return relaySession, func() {
+		origin.Close()
+		relay.RemoveSession(relaySession)
+		cancelConn()
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Run("subscribe", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		var upstreamSubscriptions atomic.Int32
+		writerCh := make(chan *moqtransport.SubscribeResponseWriter, 1)
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				upstreamSubscriptions.Add(1)
+				assert.Equal(t, []string{"namespace"}, m.Namespace)
+				assert.Equal(t, "track", m.Track)
+				assert.NoError(t, w.Accept(moqtransport.WithLargestLocation(&moqtransport.Location{Group: 3, Object: 1})))
+				writerCh <- w
+			}),
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		tracks := []*moqtransport.RemoteTrack{}
+		for range 2 {
+			s := &moqtransport.Session{
+				InitialMaxRequestID: 100,
+			}
+			defer connectRelay(t, relay, s)()
+			rt, err := s.Subscribe(context.Background(), []string{"namespace"}, "track")
+			assert.NoError(t, err)
+			largest, ok := rt.LargestLocation()
+			assert.True(t, ok)
+			assert.Equal(t, moqtransport.Location{Group: 3, Object: 1}, largest)
+			tracks = append(tracks, rt)
+		}
+		assert.Equal(t, int32(1), upstreamSubscriptions.Load())
+
+		w := <-writerCh
+		sg, err := w.OpenSubgroup(4, 7, 42)
+		assert.NoError(t, err)
+		_, err = sg.WriteObjectWithExtensions(0, moqtransport.KVPList{{Type: 2, ValueVarInt: 9}}, []byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.WriteStatus(1, moqtransport.ObjectStatusEndOfGroup))
+		assert.NoError(t, sg.Close())
+		assert.NoError(t, w.SendDatagram(moqtransport.Object{
+			GroupID:           5,
+			ObjectID:          0,
+			PublisherPriority: 17,
+			Payload:           []byte("datagram"),
+		}))
+
+		type received struct {
+			Location   moqtransport.Location
+			Subgroup   uint64
+			Priority   uint8
+			Status     moqtransport.ObjectStatus
+			Preference moqtransport.ObjectForwardingPreference
+			Extensions moqtransport.KVPList
+			Payload    string
+		}
+		ctx, cancelCtx := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancelCtx()
+		// Datagrams are not covered by the stream count of SUBSCRIBE_DONE, so
+		// all objects are read before the subscription is ended.
+		for _, rt := range tracks {
+			got := []received{}
+			for range 3 {
+				o, err := rt.ReadObject(ctx)
+				assert.NoError(t, err)
+				got = append(got, received{
+					Location:   moqtransport.Location{Group: o.GroupID, Object: o.ObjectID},
+					Subgroup:   o.SubGroupID,
+					Priority:   o.PublisherPriority,
+					Status:     o.Status,
+					Preference: o.ForwardingPreference,
+					Extensions: o.ExtensionHeaders,
+					Payload:    string(o.Payload),
+				})
+			}
+			assert.ElementsMatch(t, []received{
+				{
+					Location:   moqtransport.Location{Group: 4, Object: 0},
+					Subgroup:   7,
+					Priority:   42,
+					Status:     moqtransport.ObjectStatusNormal,
+					Preference: moqtransport.ObjectForwardingPreferenceSubgroup,
+					Extensions: moqtransport.KVPList{{Type: 2, ValueVarInt: 9}},
+					Payload:    "hello",
+				},
+				{
+					Location:   moqtransport.Location{Group: 4, Object: 1},
+					Subgroup:   7,
+					Priority:   42,
+					Status:     moqtransport.ObjectStatusEndOfGroup,
+					Preference: moqtransport.ObjectForwardingPreferenceSubgroup,
+					Payload:    "",
+				},
+				{
+					Location:   moqtransport.Location{Group: 5, Object: 0},
+					Priority:   17,
+					Status:     moqtransport.ObjectStatusNormal,
+					Preference: moqtransport.ObjectForwardingPreferenceDatagram,
+					Payload:    "datagram",
+				},
+			}, got)
+		}
+
+		assert.NoError(t, w.CloseWithError(moqtransport.SubscribeStatusTrackEnded, "track ended"))
+		for _, rt := range tracks {
+			for o, err := range rt.Objects(ctx) {
+				assert.NoError(t, err)
+				assert.Fail(t, "unexpected object", o)
+			}
+			done, ok := rt.SubscribeDone()
+			assert.True(t, ok)
+			assert.Equal(t, moqtransport.ErrSubscribeDone{
+				Status: moqtransport.SubscribeStatusTrackEnded,
+				Reason: "track ended",
+			}, done)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		writers := make(chan *moqtransport.SubscribeResponseWriter, 2)
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, w.Accept())
+				writers <- w
+			}),
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NoError(t, rt.Close())
+
+		// The relay closes the upstream subscription after the last
+		// subscriber unsubscribed, without any object being forwarded.
+		w := <-writers
+		select {
+		case <-w.Context().Done():
+			assert.ErrorIs(t, context.Cause(w.Context()), moqtransport.ErrUnsusbcribed)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for upstream unsubscribe")
+		}
+
+		// The next subscriber causes a new upstream subscription.
+		rt, err = subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		defer rt.Close()
+		select {
+		case <-writers:
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout while waiting for upstream subscription")
+		}
+	})
+
+	t.Run("unknown_track", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+		_, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.Error(t, err)
+		_, err = subscriber.Fetch(context.Background(), []string{"namespace"}, "track")
+		assert.Error(t, err)
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		store := moqtransport.NewMemoryObjectStore(1024, 0)
+		tw := moqtransport.NewTrackWriter(
+			moqtransport.WithTrackPriority(5),
+			moqtransport.WithObjectStore(store, []string{"namespace"}, "track"),
+		)
+		for range 2 {
+			_, err := tw.WriteObject([]byte("hello fetch"))
+			assert.NoError(t, err)
+			assert.NoError(t, tw.NewGroup())
+		}
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			FetchHandler: moqtransport.FetchHandlerFunc(func(w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+				assert.NoError(t, w.AcceptFromStore())
+			}),
+			ObjectStore: store,
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+		rt, err := subscriber.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 0, Object: 0}, 1, 0),
+		)
+		assert.NoError(t, err)
+		end, ok := rt.EndLocation()
+		assert.True(t, ok)
+		assert.Equal(t, moqtransport.Location{Group: 1, Object: 1}, end)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := []moqtransport.Location{}
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			assert.Equal(t, uint8(5), o.PublisherPriority)
+			received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		}
+		assert.Equal(t, []moqtransport.Location{
+			{Group: 0, Object: 0},
+			{Group: 0, Object: 1}, // end of group
+			{Group: 1, Object: 0},
+			{Group: 1, Object: 1}, // end of group
+		}, received)
+	})
+
+	t.Run("fetch_upstream_reset", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		received := make(chan struct{})
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			FetchHandler: moqtransport.FetchHandlerFunc(func(w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+				assert.NoError(t, w.Accept())
+				go func() {
+					fs, err := w.FetchStream()
+					assert.NoError(t, err)
+					_, err = fs.WriteObject(0, 0, 0, 0, []byte("first"))
+					assert.NoError(t, err)
+					// Reset the stream after the subscriber received the
+					// first object.
+					<-received
+					fs.Reset(moqtransport.ErrorCodeStreamInternal)
+				}()
+			}),
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+		rt, err := subscriber.Fetch(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithFetchRange(moqtransport.Location{Group: 0, Object: 0}, 1, 0),
+		)
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("first"), o.Payload)
+		close(received)
+
+		// The relay resets the downstream fetch stream instead of leaving
+		// the fetch open.
+		_, err = rt.ReadObject(ctx)
+		var resetErr *moqtransport.StreamResetError
+		assert.ErrorAs(t, err, &resetErr)
+		assert.Equal(t, moqtransport.ErrorCodeStreamInternal, resetErr.ErrorCode)
+	})
+
+	t.Run("announcements", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"sports", "football"}))
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"news"}))
+
+		messages := make(chan *moqtransport.Message, 10)
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			Handler: moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+				messages <- m
+				if w != nil {
+					assert.NoError(t, w.Accept())
+				}
+			}),
+		}
+		defer connectRelay(t, relay, subscriber)()
+		assert.NoError(t, subscriber.SubscribeAnnouncements(context.Background(), []string{"sports"}))
+
+		next := func() *moqtransport.Message {
+			select {
+			case m := <-messages:
+				return m
+			case <-time.After(time.Second):
+				assert.FailNow(t, "timeout while waiting for message")
+			}
+			return nil
+		}
+		m := next()
+		assert.Equal(t, moqtransport.MessageAnnounce, m.Method)
+		assert.Equal(t, []string{"sports", "football"}, m.Namespace)
+
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"sports", "tennis"}))
+		m = next()
+		assert.Equal(t, moqtransport.MessageAnnounce, m.Method)
+		assert.Equal(t, []string{"sports", "tennis"}, m.Namespace)
+
+		assert.NoError(t, publisher.Unannounce(context.Background(), []string{"sports", "tennis"}))
+		m = next()
+		assert.Equal(t, moqtransport.MessageUnannounce, m.Method)
+		assert.Equal(t, []string{"sports", "tennis"}, m.Namespace)
+
+		assert.NoError(t, publisher.Unannounce(context.Background(), []string{"news"}))
+		select {
+		case m := <-messages:
+			assert.Fail(t, "unexpected message", m)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+	t.Run("failover", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		type upstreamSubscription struct {
+			w *moqtransport.SubscribeResponseWriter
+			m *moqtransport.SubscribeMessage
+		}
+		newPublisher := func(ch chan upstreamSubscription) *moqtransport.Session {
+			return &moqtransport.Session{
+				InitialMaxRequestID: 100,
+				SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+					assert.NoError(t, w.Accept())
+					ch <- upstreamSubscription{w: w, m: m}
+				}),
+			}
+		}
+		first := make(chan upstreamSubscription, 1)
+		second := make(chan upstreamSubscription, 1)
+		publisher1 := newPublisher(first)
+		publisher2 := newPublisher(second)
+		cancelPublisher1 := connectRelay(t, relay, publisher1)
+		defer cancelPublisher1()
+		assert.NoError(t, publisher1.Announce(context.Background(), []string{"namespace"}))
+		cancelPublisher2 := connectRelay(t, relay, publisher2)
+		defer cancelPublisher2()
+		assert.NoError(t, publisher2.Announce(context.Background(), []string{"namespace"}))
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+		rt, err := subscriber.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancelCtx()
+		read := func() moqtransport.Location {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			if o == nil {
+				return moqtransport.Location{}
+			}
+			return moqtransport.Location{Group: o.GroupID, Object: o.ObjectID}
+		}
+
+		u := <-first
+		sg, err := u.w.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		for i := range 2 {
+			_, err = sg.WriteObject(uint64(i), []byte("first"))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, moqtransport.Location{Group: 0, Object: 0}, read())
+		assert.Equal(t, moqtransport.Location{Group: 0, Object: 1}, read())
+
+		// The relay subscribes at the second publisher after the first one
+		// failed, starting after the last forwarded object.
+		cancelPublisher1()
+		var u2 upstreamSubscription
+		select {
+		case u2 = <-second:
+		case <-ctx.Done():
+			assert.FailNow(t, "timeout while waiting for upstream subscription")
+		}
+		assert.Equal(t, moqtransport.FilterTypeAbsoluteStart, u2.m.FilterType)
+		assert.Equal(t, &moqtransport.Location{Group: 0, Object: 2}, u2.m.StartLocation)
+
+		sg, err = u2.w.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		for i := range 3 {
+			_, err = sg.WriteObject(uint64(i+1), []byte("second"))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, moqtransport.Location{Group: 0, Object: 2}, read())
+		assert.Equal(t, moqtransport.Location{Group: 0, Object: 3}, read())
+		_, ok := rt.SubscribeDone()
+		assert.False(t, ok)
+
+		// SUBSCRIBE_DONE is sent after no publisher is left.
+		cancelPublisher2()
+		_, err = rt.ReadObject(ctx)
+		var done *moqtransport.ErrSubscribeDone
+		assert.ErrorAs(t, err, &done)
+		assert.Equal(t, uint64(moqtransport.SubscribeStatusInternalError), done.Status)
+	})
+
+	t.Run("static_origin", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		newPublisher := func(subscriptions *atomic.Int32) *moqtransport.Session {
+			return &moqtransport.Session{
+				InitialMaxRequestID: 100,
+				SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+					subscriptions.Add(1)
+					assert.NoError(t, w.Accept())
+				}),
+			}
+		}
+		var originSubscriptions, publisherSubscriptions atomic.Int32
+		origin := newPublisher(&originSubscriptions)
+		originSession, cancelOrigin := connectOrigin(t, relay, origin)
+		defer cancelOrigin()
+		assert.NoError(t, relay.AddOrigin([]string{"live"}, originSession))
+
+		publisher := newPublisher(&publisherSubscriptions)
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"live", "event"}))
+
+		routes := relay.Routes()
+		assert.Len(t, routes, 2)
+		assert.Equal(t, []string{"live", "event"}, routes[0].Namespace)
+		assert.False(t, routes[0].Static)
+		assert.Equal(t, moqrelay.Route{
+			Namespace: []string{"live"},
+			Session:   originSession,
+			Static:    true,
+		}, routes[1])
+
+		subscriber := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, subscriber)()
+
+		// The announced namespace is the longest prefix.
+		rt, err := subscriber.Subscribe(context.Background(), []string{"live", "event"}, "track")
+		assert.NoError(t, err)
+		defer rt.Close()
+		assert.Equal(t, int32(1), publisherSubscriptions.Load())
+		assert.Equal(t, int32(0), originSubscriptions.Load())
+
+		rt, err = subscriber.Subscribe(context.Background(), []string{"live", "other"}, "track")
+		assert.NoError(t, err)
+		defer rt.Close()
+		assert.Equal(t, int32(1), publisherSubscriptions.Load())
+		assert.Equal(t, int32(1), originSubscriptions.Load())
+	})
+	t.Run("subscribe_update", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		subscriptions := make(chan *moqtransport.SubscribeMessage, 1)
+		updates := make(chan *moqtransport.SubscribeUpdateMessage, 10)
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, w.Accept())
+				subscriptions <- m
+			}),
+			SubscribeUpdateHandler: moqtransport.SubscribeUpdateHandlerFunc(func(m *moqtransport.SubscribeUpdateMessage) {
+				updates <- m
+			}),
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		type update struct {
+			Priority uint8
+			Forward  uint8
+			EndGroup uint64
+		}
+		nextUpdate := func() update {
+			select {
+			case m := <-updates:
+				return update{
+					Priority: m.SubscriberPriority,
+					Forward:  m.Forward,
+					EndGroup: m.EndGroup,
+				}
+			case <-time.After(time.Second):
+				assert.FailNow(t, "timeout while waiting for SUBSCRIBE_UPDATE")
+			}
+			return update{}
+		}
+
+		tracks := []*moqtransport.RemoteTrack{}
+		for range 2 {
+			s := &moqtransport.Session{
+				InitialMaxRequestID: 100,
+			}
+			defer connectRelay(t, relay, s)()
+			var rt *moqtransport.RemoteTrack
+			var err error
+			if len(tracks) == 0 {
+				rt, err = s.Subscribe(context.Background(), []string{"namespace"}, "track",
+					moqtransport.WithSubscriberPriority(200),
+					moqtransport.WithForward(false),
+				)
+			} else {
+				rt, err = s.Subscribe(context.Background(), []string{"namespace"}, "track",
+					moqtransport.WithSubscriberPriority(10),
+				)
+			}
+			assert.NoError(t, err)
+			defer rt.Close()
+			tracks = append(tracks, rt)
+		}
+
+		// The upstream subscription is created for the first subscriber and
+		// updated for the second one, which is more important and forwards.
+		m := <-subscriptions
+		assert.Equal(t, uint8(200), m.SubscriberPriority)
+		assert.Equal(t, uint8(0), m.Forward)
+		assert.Equal(t, update{Priority: 10, Forward: 1, EndGroup: 0}, nextUpdate())
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		assert.NoError(t, tracks[1].UpdateSubscription(ctx,
+			moqtransport.WithUpdateSubscriberPriority(250),
+			moqtransport.WithUpdateForward(false),
+			moqtransport.WithUpdateEndGroup(8),
+		))
+		assert.Equal(t, update{Priority: 200, Forward: 0, EndGroup: 0}, nextUpdate())
+
+		// The end group of the shared upstream subscription is not narrowed
+		// after both subscriptions are bounded.
+		assert.NoError(t, tracks[0].UpdateSubscription(ctx,
+			moqtransport.WithUpdateSubscriberPriority(200),
+			moqtransport.WithUpdateForward(false),
+			moqtransport.WithUpdateEndGroup(5),
+		))
+
+		select {
+		case m := <-updates:
+			assert.Fail(t, "unexpected SUBSCRIBE_UPDATE", m)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+	t.Run("resubscribe_wider_demand", func(t *testing.T) {
+		relay := moqrelay.New()
+		defer relay.Close()
+
+		subscriptions := make(chan *moqtransport.SubscribeMessage, 2)
+		updates := make(chan *moqtransport.SubscribeUpdateMessage, 10)
+		publisher := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+			SubscribeHandler: moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+				assert.NoError(t, w.Accept())
+				subscriptions <- m
+			}),
+			SubscribeUpdateHandler: moqtransport.SubscribeUpdateHandlerFunc(func(m *moqtransport.SubscribeUpdateMessage) {
+				updates <- m
+			}),
+		}
+		defer connectRelay(t, relay, publisher)()
+		assert.NoError(t, publisher.Announce(context.Background(), []string{"namespace"}))
+
+		first := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, first)()
+		rt, err := first.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		defer rt.Close()
+		m := <-subscriptions
+		assert.Equal(t, moqtransport.FilterTypeLatestObject, m.FilterType)
+
+		// Moving the start of the only subscription forward narrows the
+		// upstream subscription.
+		start := moqtransport.Location{Group: 10, Object: 0}
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		assert.NoError(t, rt.UpdateSubscription(ctx,
+			moqtransport.WithUpdateStartLocation(start),
+		))
+		select {
+		case u := <-updates:
+			assert.Equal(t, start, u.StartLocation)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for SUBSCRIBE_UPDATE")
+		}
+
+		// A subscriber starting at the latest object requests a wider range,
+		// so the track is subscribed again.
+		second := &moqtransport.Session{
+			InitialMaxRequestID: 100,
+		}
+		defer connectRelay(t, relay, second)()
+		rt2, err := second.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		defer rt2.Close()
+		select {
+		case m := <-subscriptions:
+			assert.Equal(t, moqtransport.FilterTypeLatestObject, m.FilterType)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for SUBSCRIBE")
+		}
+	})
+}
@@ -2,6 +2,9 @@ package integrationtests
 
 import (
 	"context"
+	"errors"
+	"io"
+	"slices"
 	"testing"
 	"time"
 
@@ -78,38 +81,1026 @@ func TestSubscribe(t *testing.T) {
 		}
 
 		o1 := moqtransport.Object{
-			GroupID:    0,
+			GroupID:              0,
+			SubGroupID:           0,
+			ObjectID:             0,
+			ForwardingPreference: moqtransport.ObjectForwardingPreferenceDatagram,
+			Payload:              []byte("hello world"),
+		}
+		assert.NoError(t, publisher.SendDatagram(o1))
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, &o1, o)
+
+		sg, err := publisher.OpenSubgroup(1, 0, 0)
+		assert.NoError(t, err)
+		n, err := sg.WriteObject(0, []byte("hello again"))
+		assert.NoError(t, err)
+		assert.Equal(t, 11, n)
+		assert.NoError(t, sg.Close())
+
+		ctx2, cancelCtx2 := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx2()
+
+		o, err = rt.ReadObject(ctx2)
+		assert.NoError(t, err)
+		assert.EqualExportedValues(t, &moqtransport.Object{
+			GroupID:    1,
 			SubGroupID: 0,
 			ObjectID:   0,
-			Payload:    []byte("hello world"),
+			Payload:    []byte("hello again"),
+		}, o)
+	})
+
+	t.Run("receive_status_objects", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		sg, err := publisher.OpenSubgroup(1, 2, 3)
+		assert.NoError(t, err)
+		assert.NoError(t, sg.WriteStatus(5, moqtransport.ObjectStatusEndOfGroup))
+		assert.NoError(t, sg.Close())
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, &moqtransport.Object{
+			GroupID:              1,
+			SubGroupID:           2,
+			ObjectID:             5,
+			ForwardingPreference: moqtransport.ObjectForwardingPreferenceSubgroup,
+			PublisherPriority:    3,
+			Status:               moqtransport.ObjectStatusEndOfGroup,
+			Payload:              []byte{},
+		}, o)
+
+		assert.Error(t, publisher.SendDatagram(moqtransport.Object{
+			GroupID:  2,
+			ObjectID: 0,
+			Status:   moqtransport.ObjectStatusEndOfTrack,
+			Payload:  []byte("payload"),
+		}))
+		assert.NoError(t, publisher.SendDatagram(moqtransport.Object{
+			GroupID:  2,
+			ObjectID: 0,
+			Status:   moqtransport.ObjectStatusEndOfTrack,
+		}))
+
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, &moqtransport.Object{
+			GroupID:              2,
+			SubGroupID:           0,
+			ObjectID:             0,
+			ForwardingPreference: moqtransport.ObjectForwardingPreferenceDatagram,
+			Status:               moqtransport.ObjectStatusEndOfTrack,
+		}, o)
+	})
+
+	t.Run("receive_extension_headers", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		ts := time.UnixMicro(time.Now().UnixMicro())
+		ext := moqtransport.CaptureTimestampExtension.Set(nil, ts)
+		ext = moqtransport.VideoConfigExtension.Set(ext, []byte("config"))
+
+		sg, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		n, err := sg.WriteObjectWithExtensions(0, ext, []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		assert.NoError(t, sg.Close())
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, ext, o.ExtensionHeaders)
+		assert.Equal(t, []byte("hello"), o.Payload)
+		res, ok, err := moqtransport.CaptureTimestampExtension.Get(o.ExtensionHeaders)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, ts.Equal(res))
+
+		sg, err = publisher.OpenSubgroup(1, 1, 0, moqtransport.WithSubgroupExtensions(false))
+		assert.NoError(t, err)
+		_, err = sg.WriteObjectWithExtensions(0, ext, []byte("hello"))
+		assert.Error(t, err)
+		_, err = sg.WriteObject(0, []byte("no extensions"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.Close())
+
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Nil(t, o.ExtensionHeaders)
+		assert.Equal(t, uint64(1), o.SubGroupID)
+		assert.Equal(t, []byte("no extensions"), o.Payload)
+	})
+
+	t.Run("receive_large_datagram_objects", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		ext := moqtransport.AudioLevelExtension.Set(nil, 42)
+		small := moqtransport.Object{
+			GroupID:           0,
+			ObjectID:          0,
+			PublisherPriority: 7,
+			ExtensionHeaders:  ext,
+			Payload:           []byte("small"),
+		}
+		large := moqtransport.Object{
+			GroupID:           0,
+			ObjectID:          1,
+			PublisherPriority: 9,
+			ExtensionHeaders:  ext,
+			Payload:           make([]byte, 4096),
+		}
+
+		assert.NoError(t, publisher.SendDatagram(small))
+
+		err = publisher.SendDatagram(large)
+		var tooLarge *moqtransport.DatagramTooLargeError
+		assert.ErrorAs(t, err, &tooLarge)
+		assert.Greater(t, tooLarge.MaxDatagramSize, int64(0))
+
+		assert.NoError(t, publisher.SendDatagram(large, moqtransport.WithDatagramStreamFallback()))
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		// The datagram and the fallback stream may arrive in any order.
+		objects := map[uint64]*moqtransport.Object{}
+		for range 2 {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			objects[o.ObjectID] = o
+		}
+		assert.Equal(t, &moqtransport.Object{
+			GroupID:              0,
+			SubGroupID:           0,
+			ObjectID:             0,
+			ForwardingPreference: moqtransport.ObjectForwardingPreferenceDatagram,
+			PublisherPriority:    7,
+			ExtensionHeaders:     ext,
+			Payload:              []byte("small"),
+		}, objects[0])
+		assert.EqualExportedValues(t, &moqtransport.Object{
+			GroupID:              0,
+			SubGroupID:           1,
+			ObjectID:             1,
+			ForwardingPreference: moqtransport.ObjectForwardingPreferenceSubgroup,
+			PublisherPriority:    9,
+			ExtensionHeaders:     ext,
+			Payload:              make([]byte, 4096),
+		}, objects[1])
+	})
+
+	t.Run("delivery_timeout_stops_stale_streams", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			timeout, ok := m.Parameters.GetDeliveryTimeout()
+			assert.True(t, ok)
+			assert.Equal(t, 50*time.Millisecond, timeout)
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithDeliveryTimeout(50*time.Millisecond))
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		sg0, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg0.WriteObject(0, []byte("group 0"))
+		assert.NoError(t, err)
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), o.GroupID)
+
+		sg1, err := publisher.OpenSubgroup(1, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg1.WriteObject(0, []byte("group 1"))
+		assert.NoError(t, err)
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), o.GroupID)
+
+		// The subgroup of group 0 is idle for longer than the delivery timeout
+		// while group 1 is live, so the subscriber stops it.
+		time.Sleep(200 * time.Millisecond)
+		_, _ = sg0.WriteObject(1, []byte("stale"))
+		_, err = sg1.WriteObject(1, []byte("live"))
+		assert.NoError(t, err)
+
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), o.GroupID)
+		assert.Equal(t, []byte("live"), o.Payload)
+
+		shortCtx, cancelShortCtx := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancelShortCtx()
+		_, err = rt.ReadObject(shortCtx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("buffer_overflow", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithBufferCount(2),
+			moqtransport.WithOverflowPolicy(moqtransport.OverflowPolicyDropOldest),
+		)
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		sg, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		for i := range 5 {
+			_, err = sg.WriteObject(uint64(i), []byte("hello"))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, sg.Close())
+
+		assert.Eventually(t, func() bool {
+			return rt.DroppedObjects() == 3
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, uint64(15), rt.DroppedBytes())
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		for _, id := range []uint64{3, 4} {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, id, o.ObjectID)
+		}
+	})
+
+	t.Run("subgroup_readers", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithSubgroupReaders(true))
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		for id, priority := range []uint8{1, 2} {
+			sg, err := publisher.OpenSubgroup(0, uint64(id), priority)
+			assert.NoError(t, err)
+			for i := range 3 {
+				_, err = sg.WriteObject(uint64(i), []byte("hello"))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sg.Close())
+		}
+		assert.NoError(t, publisher.SendDatagram(moqtransport.Object{
+			GroupID:  1,
+			ObjectID: 0,
+			Payload:  []byte("datagram"),
+		}))
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		subgroups := map[uint64]*moqtransport.RemoteSubgroup{}
+		for range 2 {
+			sg, err := rt.AcceptSubgroup(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(0), sg.GroupID())
+			subgroups[sg.SubgroupID()] = sg
+		}
+		assert.Len(t, subgroups, 2)
+		assert.Equal(t, uint8(1), subgroups[0].PublisherPriority())
+		assert.Equal(t, uint8(2), subgroups[1].PublisherPriority())
+
+		for i := range 3 {
+			o, err := subgroups[0].ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(i), o.ObjectID)
+			assert.Equal(t, uint64(0), o.SubGroupID)
+		}
+		_, err = subgroups[0].ReadObject(ctx)
+		assert.ErrorIs(t, err, io.EOF)
+
+		subgroups[1].Stop()
+		_, err = subgroups[1].ReadObject(ctx)
+		assert.ErrorIs(t, err, moqtransport.ErrSubgroupStopped)
+
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("datagram"), o.Payload)
+	})
+
+	t.Run("stream_large_object_payloads", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithPayloadStreaming(1024))
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		payload := make([]byte, 4<<20)
+		for i := range payload {
+			payload[i] = byte(i)
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			sg, err := publisher.OpenSubgroup(0, 0, 0)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			w, err := sg.OpenObject(0, uint64(len(payload)), nil)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if _, err = sg.WriteObject(1, []byte("too early")); err == nil {
+				errCh <- errors.New("wrote object while object writer was open")
+				return
+			}
+			for chunk := range slices.Chunk(payload, 64*1024) {
+				if _, err = w.Write(chunk); err != nil {
+					errCh <- err
+					return
+				}
+			}
+			if _, err = w.Write([]byte("x")); err == nil {
+				errCh <- errors.New("wrote more than declared length")
+				return
+			}
+			if err = w.Close(); err != nil {
+				errCh <- err
+				return
+			}
+			if _, err = sg.WriteObject(1, []byte("small")); err != nil {
+				errCh <- err
+				return
+			}
+			errCh <- sg.Close()
+		}()
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelCtx()
+
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), o.ObjectID)
+		assert.Nil(t, o.Payload)
+		assert.Equal(t, uint64(len(payload)), o.PayloadLength())
+		received, err := io.ReadAll(o.PayloadReader())
+		assert.NoError(t, err)
+		assert.Equal(t, payload, received)
+
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), o.ObjectID)
+		assert.Equal(t, []byte("small"), o.Payload)
+
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("ordered_delivery", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithReorderWindow(200*time.Millisecond, 0))
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
 		}
-		assert.NoError(t, publisher.SendDatagram(o1))
 
 		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
 		defer cancelCtx()
 
+		// The first object is delivered immediately.
+		sg, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.Close())
 		o, err := rt.ReadObject(ctx)
 		assert.NoError(t, err)
-		assert.Equal(t, &o1, o)
+		assert.Equal(t, uint64(0), o.GroupID)
 
-		sg, err := publisher.OpenSubgroup(1, 0, 0)
+		// Objects 2 and 3 are held until object 1 of the same group arrives.
+		// Group 2 is held until group 1 starts.
+		writes := []struct {
+			groupID, subgroupID uint64
+			objectIDs           []uint64
+		}{
+			{groupID: 0, subgroupID: 1, objectIDs: []uint64{2, 3}},
+			{groupID: 2, subgroupID: 0, objectIDs: []uint64{0, 1, 2}},
+			{groupID: 0, subgroupID: 2, objectIDs: []uint64{1}},
+		}
+		for _, w := range writes {
+			sg, err := publisher.OpenSubgroup(w.groupID, w.subgroupID, 0)
+			assert.NoError(t, err)
+			for _, objectID := range w.objectIDs {
+				_, err = sg.WriteObject(objectID, []byte("hello"))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sg.Close())
+		}
+		for i := range 3 {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(0), o.GroupID)
+			assert.Equal(t, uint64(i+1), o.ObjectID)
+		}
+
+		sg, err = publisher.OpenSubgroup(1, 0, 0)
 		assert.NoError(t, err)
-		n, err := sg.WriteObject(0, []byte("hello again"))
+		_, err = sg.WriteObject(0, []byte("hello"))
 		assert.NoError(t, err)
-		assert.Equal(t, 11, n)
 		assert.NoError(t, sg.Close())
+		o, err = rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), o.GroupID)
+		assert.Equal(t, uint64(0), o.ObjectID)
+		for i := range 3 {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), o.GroupID)
+			assert.Equal(t, uint64(i), o.ObjectID)
+		}
+	})
 
-		ctx2, cancelCtx2 := context.WithTimeout(context.Background(), time.Second)
-		defer cancelCtx2()
+	t.Run("ordered_delivery_with_payload_streaming", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
 
-		o, err = rt.ReadObject(ctx2)
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		// With a count-only window, objects of a single stream must be
+		// released as they arrive, because the stream is not read further
+		// until the payload of the last object was consumed.
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track",
+			moqtransport.WithPayloadStreaming(16),
+			moqtransport.WithReorderWindow(0, 8),
+		)
 		assert.NoError(t, err)
-		assert.Equal(t, &moqtransport.Object{
-			GroupID:    1,
-			SubGroupID: 0,
-			ObjectID:   0,
-			Payload:    []byte("hello again"),
-		}, o)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		payload := make([]byte, 1024)
+		for groupID := range uint64(2) {
+			// Neither group starts at object 0.
+			errCh := make(chan error, 1)
+			go func() {
+				sg, err := publisher.OpenSubgroup(groupID, 0, 0)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				for i := range 3 {
+					if _, err = sg.WriteObject(uint64(i+1), payload); err != nil {
+						errCh <- err
+						return
+					}
+				}
+				errCh <- sg.Close()
+			}()
+			for i := range 3 {
+				o, err := rt.ReadObject(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, groupID, o.GroupID)
+				assert.Equal(t, uint64(i+1), o.ObjectID)
+				received, err := io.ReadAll(o.PayloadReader())
+				assert.NoError(t, err)
+				assert.Equal(t, payload, received)
+			}
+			assert.NoError(t, <-errCh)
+		}
+	})
+
+	t.Run("gap_detection", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		gapCh := make(chan moqtransport.Gap, 10)
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithGapHandler(func(g moqtransport.Gap) {
+			gapCh <- g
+		}))
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		sg, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.WriteStatus(1, moqtransport.ObjectStatusObjectDoesNotExist))
+		assert.NoError(t, sg.Close())
+
+		select {
+		case g := <-gapCh:
+			assert.Equal(t, moqtransport.Gap{
+				Reason:     moqtransport.GapReasonDoesNotExist,
+				GroupID:    0,
+				SubgroupID: 0,
+				ObjectID:   1,
+				Count:      1,
+			}, g)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for gap")
+		}
+
+		sg, err = publisher.OpenSubgroup(1, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("hello"))
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		for range 3 {
+			_, err = rt.ReadObject(ctx)
+			assert.NoError(t, err)
+		}
+
+		// An incomplete object resets the stream.
+		w, err := sg.OpenObject(1, 10, nil)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("hello"))
+		assert.NoError(t, err)
+		assert.Error(t, w.Close())
+
+		select {
+		case g := <-gapCh:
+			assert.Equal(t, moqtransport.Gap{
+				Reason:     moqtransport.GapReasonStreamReset,
+				GroupID:    1,
+				SubgroupID: 0,
+				ObjectID:   1,
+				Count:      0,
+			}, g)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for gap")
+		}
+		assert.Equal(t, moqtransport.GapStats{
+			DoesNotExist:     1,
+			StreamResets:     1,
+			DatagramsLost:    0,
+			ObjectsSkipped:   0,
+			SubgroupsMissing: 0,
+			GroupsSkipped:    0,
+		}, rt.GapStats())
+	})
+
+	t.Run("objects_iterator", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 1)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		var publisher moqtransport.Publisher
+		select {
+		case publisher = <-publisherCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for publisher")
+		}
+
+		sg, err := publisher.OpenSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		for i := range 3 {
+			_, err = sg.WriteObject(uint64(i), []byte("hello"))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, sg.Close())
+
+		_, ok := rt.SubscribeDone()
+		assert.False(t, ok)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := 0
+		for o, err := range rt.Objects(ctx) {
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(received), o.ObjectID)
+			received++
+			if received == 3 {
+				assert.NoError(t, publisher.CloseWithError(moqtransport.SubscribeStatusTrackEnded, "track ended"))
+			}
+		}
+		assert.Equal(t, 3, received)
+
+		done, ok := rt.SubscribeDone()
+		assert.True(t, ok)
+		assert.Equal(t, moqtransport.ErrSubscribeDone{
+			Status: moqtransport.SubscribeStatusTrackEnded,
+			Reason: "track ended",
+		}, done)
+	})
+
+	t.Run("select", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		publisherCh := make(chan moqtransport.Publisher, 2)
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+			publisherCh <- w
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt1, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track1")
+		assert.NoError(t, err)
+		rt2, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track2")
+		assert.NoError(t, err)
+
+		publishers := make([]moqtransport.Publisher, 0, 2)
+		for range 2 {
+			select {
+			case publisher := <-publisherCh:
+				publishers = append(publishers, publisher)
+			case <-time.After(time.Second):
+				assert.FailNow(t, "timeout while waiting for publisher")
+			}
+		}
+		for i, publisher := range publishers {
+			sg, err := publisher.OpenSubgroup(0, 0, 0)
+			assert.NoError(t, err)
+			for j := range 2 {
+				_, err = sg.WriteObject(uint64(j), []byte{byte(i)})
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, sg.Close())
+		}
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		received := map[*moqtransport.RemoteTrack]int{}
+		for to, err := range moqtransport.Select(ctx, rt1, rt2) {
+			assert.NoError(t, err)
+			assert.NotNil(t, to.Object)
+			received[to.Track]++
+			if received[rt1]+received[rt2] == 4 {
+				for _, publisher := range publishers {
+					assert.NoError(t, publisher.CloseWithError(moqtransport.SubscribeStatusTrackEnded, "track ended"))
+				}
+			}
+		}
+		assert.Equal(t, map[*moqtransport.RemoteTrack]int{rt1: 2, rt2: 2}, received)
+	})
+
+	t.Run("track_writer", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		tw := moqtransport.NewTrackWriter(moqtransport.WithGroupSize(10))
+		subscribedCh := make(chan struct{}, 2)
+
+		handler := moqtransport.HandlerFunc(func(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+			assert.Equal(t, moqtransport.MessageTrackStatusRequest, m.Method)
+			assert.NoError(t, tw.AcceptTrackStatus(w))
+		})
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, tw.Accept(w))
+			subscribedCh <- struct{}{}
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, handler, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		_, ok := rt.LargestLocation()
+		assert.False(t, ok)
+		select {
+		case <-subscribedCh:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for subscription")
+		}
+
+		// The size policy starts a new group after every second object, NewGroup
+		// after the fifth object.
+		for i := range 5 {
+			_, err = tw.WriteObject([]byte("hello"))
+			assert.NoError(t, err)
+			if i == 4 {
+				assert.NoError(t, tw.NewGroup())
+			}
+		}
+		l, err := tw.WriteObject([]byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, moqtransport.Location{Group: 3, Object: 0}, l)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		got := map[uint64][]moqtransport.ObjectStatus{}
+		for range 9 {
+			o, err := rt.ReadObject(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(len(got[o.GroupID])), o.ObjectID)
+			got[o.GroupID] = append(got[o.GroupID], o.Status)
+		}
+		normal := moqtransport.ObjectStatusNormal
+		end := moqtransport.ObjectStatusEndOfGroup
+		assert.Equal(t, map[uint64][]moqtransport.ObjectStatus{
+			0: {normal, normal, end},
+			1: {normal, normal, end},
+			2: {normal, end},
+			3: {normal},
+		}, got)
+
+		rt2, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		largest, ok := rt2.LargestLocation()
+		assert.True(t, ok)
+		assert.Equal(t, moqtransport.Location{Group: 3, Object: 0}, largest)
+
+		status, err := ct.RequestTrackStatus(ctx, []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.Equal(t, &moqtransport.TrackStatus{
+			Namespace:    []string{"namespace"},
+			Trackname:    "track",
+			StatusCode:   moqtransport.TrackStatusInProgress,
+			LastGroupID:  3,
+			LastObjectID: 0,
+		}, status)
+	})
+
+	t.Run("track_writer_fan_out", func(t *testing.T) {
+		tw := moqtransport.NewTrackWriter()
+		subscribedCh := make(chan struct{}, 2)
+
+		// The first session accepts subscriptions with default options, the
+		// second sends objects in datagrams.
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, tw.Accept(w))
+			subscribedCh <- struct{}{}
+		})
+		datagramSubscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept(moqtransport.WithLargestLocation(tw.LargestLocation())))
+			assert.NoError(t, tw.AddSubscriber(w, moqtransport.WithDeliveryMode(moqtransport.DeliveryModeDatagram)))
+			subscribedCh <- struct{}{}
+		})
+		sConn1, cConn1, cancel := connect(t)
+		defer cancel()
+		_, ct1, cancel := setupWithHandlers(t, sConn1, cConn1, nil, subscribeHandler)
+		defer cancel()
+		sConn2, cConn2, cancel := connect(t)
+		defer cancel()
+		_, ct2, cancel := setupWithHandlers(t, sConn2, cConn2, nil, datagramSubscribeHandler)
+		defer cancel()
+
+		waitSubscribed := func() {
+			select {
+			case <-subscribedCh:
+			case <-time.After(time.Second):
+				assert.FailNow(t, "timeout while waiting for subscription")
+			}
+		}
+
+		rt1, err := ct1.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		waitSubscribed()
+		for range 3 {
+			_, err = tw.WriteObject([]byte("hello"))
+			assert.NoError(t, err)
+		}
+
+		// A late subscriber starting at the next group.
+		rt2, err := ct2.Subscribe(context.Background(), []string{"namespace"}, "track", moqtransport.WithFilterType(moqtransport.FilterTypeNextGroupStart))
+		assert.NoError(t, err)
+		waitSubscribed()
+		_, err = tw.WriteObject([]byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, tw.NewGroup())
+		_, err = tw.WriteObject([]byte("hello"))
+		assert.NoError(t, err)
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+
+		// Datagrams are not ordered with SUBSCRIBE_DONE, so the late
+		// subscriber reads its object before the track is closed.
+		o, err := rt2.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, moqtransport.Location{Group: 1, Object: 0}, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+
+		// The end of the group is sent to the late subscriber in a status
+		// datagram.
+		assert.NoError(t, tw.NewGroup())
+		o, err = rt2.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, moqtransport.Location{Group: 1, Object: 1}, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+		assert.Equal(t, moqtransport.ObjectStatusEndOfGroup, o.Status)
+		assert.NoError(t, tw.Close())
+
+		locations := func(rt *moqtransport.RemoteTrack) []moqtransport.Location {
+			received := []moqtransport.Location{}
+			for o, err := range rt.Objects(ctx) {
+				assert.NoError(t, err)
+				if o.Status == moqtransport.ObjectStatusNormal {
+					received = append(received, moqtransport.Location{Group: o.GroupID, Object: o.ObjectID})
+				}
+			}
+			return received
+		}
+		// Groups are sent on separate streams and may arrive in any order.
+		assert.ElementsMatch(t, []moqtransport.Location{
+			{Group: 0, Object: 0},
+			{Group: 0, Object: 1},
+			{Group: 0, Object: 2},
+			{Group: 0, Object: 3},
+			{Group: 1, Object: 0},
+		}, locations(rt1))
+		assert.Empty(t, locations(rt2))
+
+		done, ok := rt2.SubscribeDone()
+		assert.True(t, ok)
+		assert.Equal(t, uint64(moqtransport.SubscribeStatusTrackEnded), done.Status)
 	})
 
 	t.Run("unsubscribe", func(t *testing.T) {
@@ -142,7 +1133,13 @@ func TestSubscribe(t *testing.T) {
 
 		assert.NoError(t, rt.Close())
 
-		time.Sleep(10 * time.Millisecond)
+		w := publisher.(*moqtransport.SubscribeResponseWriter)
+		select {
+		case <-w.Context().Done():
+			assert.ErrorIs(t, context.Cause(w.Context()), moqtransport.ErrUnsusbcribed)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for unsubscribe")
+		}
 
 		p, err := publisher.OpenSubgroup(0, 0, 0)
 		assert.Error(t, err)
@@ -189,4 +1186,61 @@ func TestSubscribe(t *testing.T) {
 		assert.ErrorContains(t, err, "done")
 		assert.Nil(t, o)
 	})
+
+	t.Run("peer_close_ends_subscription", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			assert.NoError(t, w.Accept())
+		})
+		st, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		rt, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+		assert.NoError(t, err)
+		assert.NotNil(t, rt)
+
+		st.Close()
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelCtx()
+		o, err := rt.ReadObject(ctx)
+		assert.ErrorIs(t, err, moqtransport.ErrSessionClosed)
+		assert.Nil(t, o)
+	})
+
+	t.Run("close_ends_pending_subscribe", func(t *testing.T) {
+		sConn, cConn, cancel := connect(t)
+		defer cancel()
+
+		received := make(chan struct{})
+		release := make(chan struct{})
+		subscribeHandler := moqtransport.SubscribeHandlerFunc(func(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+			close(received)
+			<-release
+		})
+		_, ct, cancel := setupWithHandlers(t, sConn, cConn, nil, subscribeHandler)
+		defer cancel()
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := ct.Subscribe(context.Background(), []string{"namespace"}, "track")
+			errCh <- err
+		}()
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout while waiting for subscribe")
+		}
+
+		ct.Close()
+		select {
+		case err := <-errCh:
+			assert.ErrorIs(t, err, moqtransport.ErrSessionClosed)
+		case <-time.After(time.Second):
+			assert.Fail(t, "pending subscribe did not end after session was closed")
+		}
+		close(release)
+	})
 }
@@ -64,6 +64,18 @@ func setupWithHandlers(t *testing.T, sConn, cConn *quic.Conn, handler moqtranspo
 		InitialMaxRequestID: 100,
 		Qlogger:             nil,
 	}
+	clientSession = &moqtransport.Session{
+		Handler:             handler,
+		SubscribeHandler:    subscribeHandler,
+		InitialMaxRequestID: 100,
+		Qlogger:             nil,
+	}
+	return serverSession, clientSession, runSessions(t, sConn, cConn, serverSession, clientSession)
+}
+
+// runSessions runs serverSession on sConn and clientSession on cConn and
+// returns after both completed the handshake.
+func runSessions(t *testing.T, sConn, cConn *quic.Conn, serverSession, clientSession *moqtransport.Session) (cancel func()) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -72,13 +84,6 @@ func setupWithHandlers(t *testing.T, sConn, cConn *quic.Conn, handler moqtranspo
 		assert.NoError(t, err)
 	}()
 
-	clientSession = &moqtransport.Session{
-		Handler:             handler,
-		SubscribeHandler:    subscribeHandler,
-		InitialMaxRequestID: 100,
-		Qlogger:             nil,
-	}
-
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
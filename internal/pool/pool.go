@@ -0,0 +1,64 @@
+// Package pool provides pooled byte buffers for object payloads.
+package pool
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	minClassBits = 6  // 64 B
+	maxClassBits = 20 // 1 MiB
+)
+
+// classes contains one pool per buffer size class. Buffers of class i have a
+// capacity of 1<<(minClassBits+i) bytes. The pools store pointers to slices to
+// avoid allocating when putting buffers back.
+var classes [maxClassBits - minClassBits + 1]sync.Pool
+
+// headers recycles the slice pointers stored in classes, so that Get and Put
+// don't allocate once the pools are warm.
+var headers = sync.Pool{
+	New: func() any {
+		return new([]byte)
+	},
+}
+
+// class returns the index of the smallest size class holding n bytes. It
+// returns false if n is larger than the largest class.
+func class(n int) (int, bool) {
+	if n <= 1<<minClassBits {
+		return 0, true
+	}
+	c := bits.Len(uint(n-1)) - minClassBits
+	return c, c < len(classes)
+}
+
+// Get returns a buffer of length n. Buffers larger than the largest size
+// class are allocated and not pooled.
+func Get(n int) []byte {
+	c, ok := class(n)
+	if !ok {
+		return make([]byte, n)
+	}
+	if h, ok := classes[c].Get().(*[]byte); ok {
+		buf := (*h)[:n]
+		*h = nil
+		headers.Put(h)
+		return buf
+	}
+	return make([]byte, n, 1<<(minClassBits+c))
+}
+
+// Put returns buf to the pool. buf must have been returned by Get and must not
+// be used after calling Put. Buffers larger than the largest size class are
+// not pooled.
+func Put(buf []byte) {
+	c, ok := class(cap(buf))
+	if !ok || cap(buf) != 1<<(minClassBits+c) {
+		return
+	}
+	h := headers.Get().(*[]byte)
+	*h = buf[:0]
+	classes[c].Put(h)
+}
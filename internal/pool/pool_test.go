@@ -0,0 +1,45 @@
+package pool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	cases := []struct {
+		n           int
+		expectedCap int
+	}{
+		{n: 0, expectedCap: 64},
+		{n: 1, expectedCap: 64},
+		{n: 64, expectedCap: 64},
+		{n: 65, expectedCap: 128},
+		{n: 1000, expectedCap: 1024},
+		{n: 1 << 20, expectedCap: 1 << 20},
+		{n: 1<<20 + 1, expectedCap: 1<<20 + 1},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			buf := Get(tc.n)
+			assert.Equal(t, tc.n, len(buf))
+			assert.Equal(t, tc.expectedCap, cap(buf))
+			Put(buf)
+		})
+	}
+}
+
+func TestPutIgnoresForeignBuffers(t *testing.T) {
+	// Put must not panic or pool buffers of other capacities.
+	Put(nil)
+	Put(make([]byte, 100))
+	Put(make([]byte, 10, 2<<20))
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		Put(Get(1024))
+	}
+}
@@ -1,7 +1,6 @@
 package wire
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 
@@ -59,6 +58,9 @@ func (p *KeyValuePair) parse(data []byte) (int, error) {
 			return parsed, err
 		}
 		data = data[n:]
+		if uint64(len(data)) < length {
+			return parsed, io.ErrUnexpectedEOF
+		}
 		p.ValueBytes = make([]byte, length) // TODO: Don't allocate memory here?
 		m := copy(p.ValueBytes, data[:length])
 		parsed += m
@@ -73,7 +75,7 @@ func (p *KeyValuePair) parse(data []byte) (int, error) {
 	return parsed, err
 }
 
-func (p *KeyValuePair) parseReader(br *bufio.Reader) error {
+func (p *KeyValuePair) parseReader(br quicvarint.Reader) error {
 	var err error
 	p.Type, err = quicvarint.Read(br)
 	if err != nil {
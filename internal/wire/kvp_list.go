@@ -1,7 +1,6 @@
 package wire
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 
@@ -63,23 +62,54 @@ func (pp KVPList) String() string {
 	return res + "]"
 }
 
-func (pp *KVPList) parseLengthReader(br *bufio.Reader) error {
-	length, err := quicvarint.Read(br)
+// parseLengthReader parses pp from r based on a length prefix in bytes.
+func (pp *KVPList) parseLengthReader(r quicvarint.Reader) error {
+	length, err := quicvarint.Read(r)
 	if err != nil {
 		return err
 	}
-	if length == 0 {
-		return nil
-	}
-	lr := io.LimitReader(br, int64(length))
-	lbr := bufio.NewReader(quicvarint.NewReader(lr))
-	for {
+	lr := &limitedReader{r: r, n: length}
+	for lr.n > 0 {
 		var hdrExt KeyValuePair
-		if err = hdrExt.parseReader(lbr); err != nil {
+		if err = hdrExt.parseReader(lr); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
 			return err
 		}
 		*pp = append(*pp, hdrExt)
 	}
+	return nil
+}
+
+// limitedReader reads at most n bytes from r. Unlike io.LimitReader, it also
+// implements io.ByteReader.
+type limitedReader struct {
+	r quicvarint.Reader
+	n uint64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n == 0 {
+		return 0, io.EOF
+	}
+	if uint64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= uint64(n)
+	return n, err
+}
+
+func (l *limitedReader) ReadByte() (byte, error) {
+	if l.n == 0 {
+		return 0, io.EOF
+	}
+	b, err := l.r.ReadByte()
+	if err == nil {
+		l.n--
+	}
+	return b, err
 }
 
 // Parses pp from data based on a length prefix in number of elements
@@ -110,6 +140,9 @@ func (pp *KVPList) parseLength(data []byte) (parsed int, err error) {
 		return
 	}
 	data = data[n:]
+	if uint64(len(data)) < length {
+		return parsed, io.ErrUnexpectedEOF
+	}
 	data = data[:length]
 
 	for len(data) > 0 {
@@ -119,6 +152,7 @@ func (pp *KVPList) parseLength(data []byte) (parsed int, err error) {
 		if err != nil {
 			return parsed, err
 		}
+		data = data[n:]
 		*pp = append(*pp, hdrExt)
 	}
 	return
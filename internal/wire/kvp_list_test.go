@@ -1,6 +1,8 @@
 package wire
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"testing"
@@ -92,3 +94,60 @@ func TestParseKVPList(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLengthKVPList(t *testing.T) {
+	cases := []struct {
+		data   []byte
+		expect KVPList
+		n      int
+		err    error
+	}{
+		{
+			data:   []byte{0x00},
+			expect: KVPList{},
+			n:      1,
+			err:    nil,
+		},
+		{
+			data: []byte{0x05, 0x02, 0x0a, 0x03, 0x01, 'A', 0xff},
+			expect: KVPList{
+				KeyValuePair{
+					Type:        0x02,
+					ValueVarInt: 0x0a,
+				},
+				KeyValuePair{
+					Type:       0x03,
+					ValueBytes: []byte("A"),
+				},
+			},
+			n:   6,
+			err: nil,
+		},
+		{
+			data:   []byte{0x05, 0x02, 0x0a},
+			expect: KVPList{},
+			n:      1,
+			err:    io.ErrUnexpectedEOF,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			res := KVPList{}
+			n, err := res.parseLength(tc.data)
+			assert.Equal(t, tc.expect, res)
+			assert.Equal(t, tc.n, n)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tc.err == nil {
+				res = KVPList{}
+				err = res.parseLengthReader(bufio.NewReader(bytes.NewReader(tc.data)))
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expect, res)
+			}
+		})
+	}
+}
@@ -23,6 +23,15 @@ type ObjectDatagramMessage struct {
 	ObjectStatus           ObjectStatus
 }
 
+// Append appends the datagram to buf. It uses the status datagram type if the
+// object carries a status other than ObjectStatusNormal.
+func (m *ObjectDatagramMessage) Append(buf []byte) []byte {
+	if m.ObjectStatus != ObjectStatusNormal {
+		return m.AppendDatagramStatus(buf)
+	}
+	return m.AppendDatagram(buf)
+}
+
 func (m *ObjectDatagramMessage) AppendDatagram(buf []byte) []byte {
 	typ := objectTypeDatagram
 	if m.ObjectExtensionHeaders != nil {
@@ -49,12 +58,14 @@ func (m *ObjectDatagramMessage) AppendDatagramStatus(buf []byte) []byte {
 	buf = quicvarint.Append(buf, m.GroupID)
 	buf = quicvarint.Append(buf, m.ObjectID)
 	buf = append(buf, m.PublisherPriority)
-	if typ == objectTypeDatagramExtension {
+	if typ == objectTypeDatagramStatusExtension {
 		buf = m.ObjectExtensionHeaders.appendLength(buf)
 	}
 	return quicvarint.Append(buf, uint64(m.ObjectStatus))
 }
 
+// Parse parses m from data. ObjectPayload refers to data instead of a copy, so
+// data must not be modified after parsing.
 func (m *ObjectDatagramMessage) Parse(data []byte) (parsed int, err error) {
 	var n int
 	var typ uint64
@@ -100,11 +111,11 @@ func (m *ObjectDatagramMessage) Parse(data []byte) (parsed int, err error) {
 		if err != nil {
 			return parsed, err
 		}
+		data = data[n:]
 	}
 	if typ&0x02 == 0 {
-		m.ObjectPayload = make([]byte, len(data))
-		n = copy(m.ObjectPayload, data)
-		parsed += n
+		m.ObjectPayload = data
+		parsed += len(data)
 	} else {
 		var status uint64
 		status, n, err = quicvarint.Parse(data)
@@ -0,0 +1,163 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectDatagramMessageAppend(t *testing.T) {
+	cases := []struct {
+		odm    ObjectDatagramMessage
+		buf    []byte
+		expect []byte
+	}{
+		{
+			odm: ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectPayload:     []byte{0x01, 0x02},
+			},
+			buf:    []byte{},
+			expect: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x01, 0x02},
+		},
+		{
+			odm: ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectExtensionHeaders: KVPList{
+					KeyValuePair{
+						Type:        2,
+						ValueVarInt: 5,
+					},
+				},
+				ObjectPayload: []byte{0x01, 0x02},
+			},
+			buf:    []byte{0x0a},
+			expect: []byte{0x0a, 0x01, 0x01, 0x02, 0x03, 0x04, 0x02, 0x02, 0x05, 0x01, 0x02},
+		},
+		{
+			odm: ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectStatus:      ObjectStatusEndOfGroup,
+			},
+			buf:    []byte{},
+			expect: []byte{0x02, 0x01, 0x02, 0x03, 0x04, 0x03},
+		},
+		{
+			odm: ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectExtensionHeaders: KVPList{
+					KeyValuePair{
+						Type:        2,
+						ValueVarInt: 5,
+					},
+				},
+				ObjectStatus: ObjectStatusEndOfTrack,
+			},
+			buf:    []byte{},
+			expect: []byte{0x03, 0x01, 0x02, 0x03, 0x04, 0x02, 0x02, 0x05, 0x04},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			res := tc.odm.Append(tc.buf)
+			assert.Equal(t, tc.expect, res)
+		})
+	}
+}
+
+func TestParseObjectDatagramMessage(t *testing.T) {
+	cases := []struct {
+		data   []byte
+		expect *ObjectDatagramMessage
+		err    error
+	}{
+		{
+			data:   []byte{0x00, 0x01, 0x02, 0x03},
+			expect: &ObjectDatagramMessage{TrackAlias: 1, GroupID: 2, ObjectID: 3},
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x01, 0x02},
+			expect: &ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectPayload:     []byte{0x01, 0x02},
+			},
+			err: nil,
+		},
+		{
+			data: []byte{0x01, 0x01, 0x02, 0x03, 0x04, 0x02, 0x02, 0x05, 0x01, 0x02},
+			expect: &ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectExtensionHeaders: KVPList{
+					KeyValuePair{
+						Type:        2,
+						ValueVarInt: 5,
+					},
+				},
+				ObjectPayload: []byte{0x01, 0x02},
+			},
+			err: nil,
+		},
+		{
+			data: []byte{0x02, 0x01, 0x02, 0x03, 0x04, 0x03},
+			expect: &ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectStatus:      ObjectStatusEndOfGroup,
+			},
+			err: nil,
+		},
+		{
+			data: []byte{0x03, 0x01, 0x02, 0x03, 0x04, 0x02, 0x02, 0x05, 0x04},
+			expect: &ObjectDatagramMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectExtensionHeaders: KVPList{
+					KeyValuePair{
+						Type:        2,
+						ValueVarInt: 5,
+					},
+				},
+				ObjectStatus: ObjectStatusEndOfTrack,
+			},
+			err: nil,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			res := &ObjectDatagramMessage{}
+			n, err := res.Parse(tc.data)
+			assert.Equal(t, tc.expect, res)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, len(tc.data), n)
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package wire
 
 import (
-	"bufio"
 	"io"
 
+	"github.com/mengelbart/moqtransport/internal/pool"
 	"github.com/quic-go/quic-go/quicvarint"
 )
 
@@ -16,18 +16,44 @@ type ObjectMessage struct {
 	ObjectExtensionHeaders KVPList
 	ObjectStatus           ObjectStatus
 	ObjectPayload          []byte
+
+	// ObjectPayloadLength and ObjectPayloadReader are only set if the payload
+	// was not read by the parser because it is larger than the streaming
+	// threshold. ObjectPayload is nil in that case and the payload must be
+	// read from ObjectPayloadReader.
+	ObjectPayloadLength uint64
+	ObjectPayloadReader io.Reader
 }
 
+// payloadLength returns the length of the payload, including payloads that
+// were not read by the parser.
+func (m *ObjectMessage) payloadLength() uint64 {
+	if m.ObjectPayloadReader != nil {
+		return m.ObjectPayloadLength
+	}
+	return uint64(len(m.ObjectPayload))
+}
+
+// AppendSubgroup appends the object as encoded on subgroup streams. Extension
+// headers are only appended if ObjectExtensionHeaders is non-nil, which must
+// match the stream type of the subgroup.
 func (m *ObjectMessage) AppendSubgroup(buf []byte) []byte {
 	buf = quicvarint.Append(buf, m.ObjectID)
-	buf = m.ObjectExtensionHeaders.appendLength(buf)
-	buf = quicvarint.Append(buf, uint64(len(m.ObjectPayload)))
-	if len(m.ObjectPayload) == 0 {
-		buf = quicvarint.Append(buf, uint64(m.ObjectStatus))
-	} else {
-		buf = append(buf, m.ObjectPayload...)
+	if m.ObjectExtensionHeaders != nil {
+		buf = m.ObjectExtensionHeaders.appendLength(buf)
+	}
+	return m.appendPayloadOrStatus(buf)
+}
+
+// AppendSubgroupHeader appends the object as encoded on subgroup streams
+// without the payload. The length bytes of payload must be written directly
+// after the header. length must not be zero.
+func (m *ObjectMessage) AppendSubgroupHeader(buf []byte, length uint64) []byte {
+	buf = quicvarint.Append(buf, m.ObjectID)
+	if m.ObjectExtensionHeaders != nil {
+		buf = m.ObjectExtensionHeaders.appendLength(buf)
 	}
-	return buf
+	return quicvarint.Append(buf, length)
 }
 
 func (m *ObjectMessage) AppendFetch(buf []byte) []byte {
@@ -36,17 +62,24 @@ func (m *ObjectMessage) AppendFetch(buf []byte) []byte {
 	buf = quicvarint.Append(buf, m.ObjectID)
 	buf = append(buf, m.PublisherPriority)
 	buf = m.ObjectExtensionHeaders.appendLength(buf)
-	buf = quicvarint.Append(buf, uint64(len(m.ObjectPayload)))
-	if len(m.ObjectPayload) == 0 {
-		buf = quicvarint.Append(buf, uint64(m.ObjectStatus))
-	} else {
-		buf = append(buf, m.ObjectPayload...)
+	return m.appendPayloadOrStatus(buf)
+}
+
+// appendPayloadOrStatus appends the payload length followed by either the
+// payload or, for empty payloads and status objects, the object status. Status
+// objects never carry a payload.
+func (m *ObjectMessage) appendPayloadOrStatus(buf []byte) []byte {
+	if len(m.ObjectPayload) == 0 || m.ObjectStatus != ObjectStatusNormal {
+		buf = quicvarint.Append(buf, 0)
+		return quicvarint.Append(buf, uint64(m.ObjectStatus))
 	}
-	return buf
+	buf = quicvarint.Append(buf, uint64(len(m.ObjectPayload)))
+	return append(buf, m.ObjectPayload...)
 }
 
-func (m *ObjectMessage) readSubgroup(r io.Reader) (err error) {
-	br := bufio.NewReader(r)
+// readSubgroup reads the object from br. Payloads are read into buffers from
+// the payload pool.
+func (m *ObjectMessage) readSubgroup(br messageReader, streamThreshold uint64) (err error) {
 	m.ObjectID, err = quicvarint.Read(br)
 	if err != nil {
 		return
@@ -58,26 +91,12 @@ func (m *ObjectMessage) readSubgroup(r io.Reader) (err error) {
 		}
 	}
 
-	length, err := quicvarint.Read(br)
-	if err != nil {
-		return
-	}
-	if length == 0 {
-		var status uint64
-		status, err = quicvarint.Read(br)
-		if err != nil {
-			return
-		}
-		m.ObjectStatus = ObjectStatus(status)
-		return
-	}
-	m.ObjectPayload = make([]byte, length)
-	_, err = io.ReadFull(r, m.ObjectPayload)
-	return
+	return m.readPayloadOrStatus(br, streamThreshold)
 }
 
-func (m *ObjectMessage) readFetch(r io.Reader) (err error) {
-	br := bufio.NewReader(r)
+// readFetch reads the object from br. Payloads are read into buffers from the
+// payload pool.
+func (m *ObjectMessage) readFetch(br messageReader, streamThreshold uint64) (err error) {
 	m.GroupID, err = quicvarint.Read(br)
 	if err != nil {
 		return
@@ -101,22 +120,37 @@ func (m *ObjectMessage) readFetch(r io.Reader) (err error) {
 		return err
 	}
 
+	return m.readPayloadOrStatus(br, streamThreshold)
+}
+
+// readPayloadOrStatus reads the payload length followed by either the payload
+// or, for empty payloads, the object status. If streamThreshold is not zero,
+// payloads larger than streamThreshold are not read. Instead,
+// ObjectPayloadReader is set to read the payload from br.
+func (m *ObjectMessage) readPayloadOrStatus(br messageReader, streamThreshold uint64) error {
 	length, err := quicvarint.Read(br)
 	if err != nil {
-		return
+		return err
 	}
-
 	if length == 0 {
 		var status uint64
 		status, err = quicvarint.Read(br)
 		if err != nil {
-			return
+			return err
 		}
 		m.ObjectStatus = ObjectStatus(status)
-		return
+		return nil
 	}
-
-	m.ObjectPayload = make([]byte, length)
-	_, err = io.ReadFull(r, m.ObjectPayload)
-	return
+	if streamThreshold > 0 && length > streamThreshold {
+		m.ObjectPayloadLength = length
+		m.ObjectPayloadReader = io.LimitReader(br, int64(length))
+		return nil
+	}
+	m.ObjectPayload = pool.Get(int(length))
+	if _, err = io.ReadFull(br, m.ObjectPayload); err != nil {
+		pool.Put(m.ObjectPayload)
+		m.ObjectPayload = nil
+		return err
+	}
+	return nil
 }
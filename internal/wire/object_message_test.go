@@ -0,0 +1,116 @@
+package wire
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectMessageAppendSubgroup(t *testing.T) {
+	cases := []struct {
+		om     ObjectMessage
+		buf    []byte
+		expect []byte
+	}{
+		{
+			om: ObjectMessage{
+				ObjectID:               1,
+				ObjectExtensionHeaders: KVPList{},
+				ObjectPayload:          []byte("a"),
+			},
+			buf:    []byte{},
+			expect: []byte{0x01, 0x00, 0x01, 'a'},
+		},
+		{
+			om: ObjectMessage{
+				ObjectID:               2,
+				ObjectExtensionHeaders: KVPList{},
+			},
+			buf:    []byte{},
+			expect: []byte{0x02, 0x00, 0x00, 0x00},
+		},
+		{
+			om: ObjectMessage{
+				ObjectID:               3,
+				ObjectExtensionHeaders: KVPList{},
+				ObjectStatus:           ObjectStatusEndOfGroup,
+			},
+			buf:    []byte{0x0a},
+			expect: []byte{0x0a, 0x03, 0x00, 0x00, 0x03},
+		},
+		{
+			om: ObjectMessage{
+				ObjectID:               4,
+				ObjectExtensionHeaders: KVPList{},
+				ObjectStatus:           ObjectStatusEndOfTrack,
+				ObjectPayload:          []byte("ignored"),
+			},
+			buf:    []byte{},
+			expect: []byte{0x04, 0x00, 0x00, 0x04},
+		},
+		{
+			om: ObjectMessage{
+				ObjectID:      5,
+				ObjectPayload: []byte("a"),
+			},
+			buf:    []byte{},
+			expect: []byte{0x05, 0x01, 'a'},
+		},
+		{
+			om: ObjectMessage{
+				ObjectID: 6,
+				ObjectExtensionHeaders: KVPList{
+					KeyValuePair{Type: 2, ValueVarInt: 7},
+					KeyValuePair{Type: 3, ValueBytes: []byte("b")},
+				},
+				ObjectPayload: []byte("a"),
+			},
+			buf:    []byte{},
+			expect: []byte{0x06, 0x05, 0x02, 0x07, 0x03, 0x01, 'b', 0x01, 'a'},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			res := tc.om.AppendSubgroup(tc.buf)
+			assert.Equal(t, tc.expect, res)
+		})
+	}
+}
+
+func TestObjectMessageAppendFetch(t *testing.T) {
+	cases := []struct {
+		om     ObjectMessage
+		buf    []byte
+		expect []byte
+	}{
+		{
+			om: ObjectMessage{
+				GroupID:           1,
+				SubgroupID:        2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectPayload:     []byte("a"),
+			},
+			buf:    []byte{},
+			expect: []byte{0x01, 0x02, 0x03, 0x04, 0x00, 0x01, 'a'},
+		},
+		{
+			om: ObjectMessage{
+				GroupID:           1,
+				SubgroupID:        2,
+				ObjectID:          3,
+				PublisherPriority: 4,
+				ObjectStatus:      ObjectStatusObjectDoesNotExist,
+			},
+			buf:    []byte{},
+			expect: []byte{0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x01},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			res := tc.om.AppendFetch(tc.buf)
+			assert.Equal(t, tc.expect, res)
+		})
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"iter"
 
-	"github.com/mengelbart/moqtransport/internal/slices"
 	"github.com/mengelbart/qlog"
 	"github.com/mengelbart/qlog/moqt"
 	"github.com/quic-go/quic-go/quicvarint"
@@ -39,6 +38,13 @@ type ObjectStreamParser struct {
 	hasSubgroupID bool
 	hasExtensions bool
 
+	// streamThreshold is the payload size above which payloads are not read
+	// by Parse. Zero disables streaming.
+	streamThreshold uint64
+	// payload is the reader of the last streamed payload. Unread bytes are
+	// discarded before parsing the next object.
+	payload io.Reader
+
 	PublisherPriority uint8
 	GroupID           uint64
 	SubgroupID        uint64
@@ -121,6 +127,15 @@ func NewObjectStreamParser(r io.Reader, streamID uint64, qlogger *qlog.Logger) (
 	return nil, fmt.Errorf("%w: %v", errInvalidStreamType, st)
 }
 
+// SetPayloadStreamingThreshold sets the payload size above which Parse does
+// not read object payloads. Instead, the returned message carries an
+// ObjectPayloadReader, which must be read before the next call to Parse.
+// Unread payload bytes are discarded by the next call to Parse. Zero, the
+// default, disables streaming.
+func (p *ObjectStreamParser) SetPayloadStreamingThreshold(threshold uint64) {
+	p.streamThreshold = threshold
+}
+
 func (p *ObjectStreamParser) Messages() iter.Seq2[*ObjectMessage, error] {
 	return func(yield func(*ObjectMessage, error) bool) {
 		for {
@@ -146,25 +161,17 @@ func (p *ObjectStreamParser) parseSubgroupObject() (*ObjectMessage, error) {
 		ObjectStatus:           0,
 		ObjectPayload:          nil,
 	}
-	if err := m.readSubgroup(p.reader); err != nil {
+	if err := m.readSubgroup(p.reader, p.streamThreshold); err != nil {
 		return nil, err
 	}
 	if !p.hasSubgroupID {
-		p.SubgroupID = m.SubgroupID
+		// The subgroup ID of stream types 0x0a and 0x0b is the object ID of
+		// the first object on the stream.
+		p.SubgroupID = m.ObjectID
 		p.hasSubgroupID = true
+		m.SubgroupID = p.SubgroupID
 	}
 	if p.qlogger != nil {
-		eth := slices.Collect(slices.Map(
-			m.ObjectExtensionHeaders,
-			func(e KeyValuePair) moqt.ExtensionHeader {
-				return moqt.ExtensionHeader{
-					HeaderType:   e.Type,
-					HeaderValue:  0, // TODO
-					HeaderLength: 0, // TODO
-					Payload:      qlog.RawInfo{},
-				}
-			}),
-		)
 		gid := new(uint64)
 		sid := new(uint64)
 		*gid = p.GroupID
@@ -175,13 +182,13 @@ func (p *ObjectStreamParser) parseSubgroupObject() (*ObjectMessage, error) {
 			GroupID:                gid,
 			SubgroupID:             sid,
 			ObjectID:               m.ObjectID,
-			ExtensionHeadersLength: uint64(len(m.ObjectExtensionHeaders)),
-			ExtensionHeaders:       eth,
-			ObjectPayloadLength:    uint64(len(m.ObjectPayload)),
+			ExtensionHeadersLength: m.ObjectExtensionHeaders.EncodedLength(),
+			ExtensionHeaders:       m.ObjectExtensionHeaders.QlogExtensionHeaders(),
+			ObjectPayloadLength:    m.payloadLength(),
 			ObjectStatus:           uint64(m.ObjectStatus),
 			ObjectPayload: qlog.RawInfo{
-				Length:        uint64(len(m.ObjectPayload)),
-				PayloadLength: uint64(len(m.ObjectPayload)),
+				Length:        m.payloadLength(),
+				PayloadLength: m.payloadLength(),
 				Data:          m.ObjectPayload,
 			},
 		})
@@ -199,21 +206,10 @@ func (p *ObjectStreamParser) parseFetchObject() (*ObjectMessage, error) {
 		ObjectStatus:      0,
 		ObjectPayload:     nil,
 	}
-	if err := m.readFetch(p.reader); err != nil {
+	if err := m.readFetch(p.reader, p.streamThreshold); err != nil {
 		return nil, err
 	}
 	if p.qlogger != nil {
-		eth := slices.Collect(slices.Map(
-			m.ObjectExtensionHeaders,
-			func(e KeyValuePair) moqt.ExtensionHeader {
-				return moqt.ExtensionHeader{
-					HeaderType:   e.Type,
-					HeaderValue:  0, // TODO
-					HeaderLength: 0, // TODO
-					Payload:      qlog.RawInfo{},
-				}
-			}),
-		)
 		p.qlogger.Log(moqt.FetchObjectEvent{
 			EventName:              moqt.FetchObjectEventParsed,
 			StreamID:               p.streamID,
@@ -221,13 +217,13 @@ func (p *ObjectStreamParser) parseFetchObject() (*ObjectMessage, error) {
 			SubgroupID:             m.SubgroupID,
 			ObjectID:               m.ObjectID,
 			PublisherPriority:      m.PublisherPriority,
-			ExtensionHeadersLength: uint64(len(m.ObjectExtensionHeaders)),
-			ExtensionHeaders:       eth,
-			ObjectPayloadLength:    uint64(len(m.ObjectPayload)),
+			ExtensionHeadersLength: m.ObjectExtensionHeaders.EncodedLength(),
+			ExtensionHeaders:       m.ObjectExtensionHeaders.QlogExtensionHeaders(),
+			ObjectPayloadLength:    m.payloadLength(),
 			ObjectStatus:           uint64(m.ObjectStatus),
 			ObjectPayload: qlog.RawInfo{
-				Length:        uint64(len(m.ObjectPayload)),
-				PayloadLength: uint64(len(m.ObjectPayload)),
+				Length:        m.payloadLength(),
+				PayloadLength: m.payloadLength(),
 				Data:          m.ObjectPayload,
 			},
 		})
@@ -236,6 +232,21 @@ func (p *ObjectStreamParser) parseFetchObject() (*ObjectMessage, error) {
 }
 
 func (p *ObjectStreamParser) Parse() (*ObjectMessage, error) {
+	if p.payload != nil {
+		if _, err := io.Copy(io.Discard, p.payload); err != nil {
+			return nil, err
+		}
+		p.payload = nil
+	}
+	m, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	p.payload = m.ObjectPayloadReader
+	return m, nil
+}
+
+func (p *ObjectStreamParser) parse() (*ObjectMessage, error) {
 	if p.typ == StreamTypeFetch {
 		return p.parseFetchObject()
 	}
@@ -0,0 +1,199 @@
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mengelbart/moqtransport/internal/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectStreamParser(t *testing.T) {
+	cases := []struct {
+		data   []byte
+		typ    StreamType
+		expect []*ObjectMessage
+	}{
+		{
+			data: []byte{
+				byte(StreamTypeSubgroupSIDExt), 0x01, 0x02, 0x03, 0x04,
+				0x00, 0x02, 0x02, 0x05, 0x01, 'a',
+				0x01, 0x00, 0x00, 0x03,
+			},
+			typ: StreamTypeSubgroupSIDExt,
+			expect: []*ObjectMessage{
+				{
+					TrackAlias:        1,
+					GroupID:           2,
+					SubgroupID:        3,
+					ObjectID:          0,
+					PublisherPriority: 4,
+					ObjectExtensionHeaders: KVPList{
+						KeyValuePair{Type: 2, ValueVarInt: 5},
+					},
+					ObjectStatus:  ObjectStatusNormal,
+					ObjectPayload: []byte("a"),
+				},
+				{
+					TrackAlias:             1,
+					GroupID:                2,
+					SubgroupID:             3,
+					ObjectID:               1,
+					PublisherPriority:      4,
+					ObjectExtensionHeaders: KVPList{},
+					ObjectStatus:           ObjectStatusEndOfGroup,
+				},
+			},
+		},
+		{
+			data: []byte{
+				byte(StreamTypeSubgroupNoSIDNoExt), 0x01, 0x02, 0x04,
+				0x07, 0x01, 'a',
+				0x08, 0x01, 'b',
+			},
+			typ: StreamTypeSubgroupNoSIDNoExt,
+			expect: []*ObjectMessage{
+				{
+					TrackAlias:        1,
+					GroupID:           2,
+					SubgroupID:        7,
+					ObjectID:          7,
+					PublisherPriority: 4,
+					ObjectPayload:     []byte("a"),
+				},
+				{
+					TrackAlias:        1,
+					GroupID:           2,
+					SubgroupID:        7,
+					ObjectID:          8,
+					PublisherPriority: 4,
+					ObjectPayload:     []byte("b"),
+				},
+			},
+		},
+		{
+			data: []byte{
+				byte(StreamTypeFetch), 0x09,
+				0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x04,
+			},
+			typ: StreamTypeFetch,
+			expect: []*ObjectMessage{
+				{
+					GroupID:                1,
+					SubgroupID:             2,
+					ObjectID:               3,
+					PublisherPriority:      4,
+					ObjectExtensionHeaders: KVPList{},
+					ObjectStatus:           ObjectStatusEndOfTrack,
+				},
+			},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			p, err := NewObjectStreamParser(bytes.NewReader(tc.data), 0, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.typ, p.Type())
+			res := []*ObjectMessage{}
+			for m, err := range p.Messages() {
+				if err != nil {
+					assert.Equal(t, io.EOF, err)
+					break
+				}
+				res = append(res, m)
+			}
+			assert.Equal(t, tc.expect, res)
+		})
+	}
+}
+
+func TestObjectStreamParserPayloadStreaming(t *testing.T) {
+	data := []byte{
+		byte(StreamTypeSubgroupSIDNoExt), 0x01, 0x02, 0x03, 0x04,
+		0x00, 0x01, 'a',
+		0x01, 0x05, 'h', 'e', 'l', 'l', 'o',
+		0x02, 0x05, 'w', 'o', 'r', 'l', 'd',
+		0x03, 0x00, 0x03,
+	}
+	p, err := NewObjectStreamParser(bytes.NewReader(data), 0, nil)
+	assert.NoError(t, err)
+	p.SetPayloadStreamingThreshold(1)
+
+	m, err := p.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), m.ObjectPayload)
+	assert.Nil(t, m.ObjectPayloadReader)
+
+	m, err = p.Parse()
+	assert.NoError(t, err)
+	assert.Nil(t, m.ObjectPayload)
+	assert.Equal(t, uint64(5), m.ObjectPayloadLength)
+	payload, err := io.ReadAll(m.ObjectPayloadReader)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), payload)
+
+	// Unread payload bytes are discarded by the next call to Parse.
+	m, err = p.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), m.ObjectID)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(m.ObjectPayloadReader, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("wo"), buf)
+
+	m, err = p.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), m.ObjectID)
+	assert.Equal(t, ObjectStatusEndOfGroup, m.ObjectStatus)
+
+	_, err = p.Parse()
+	assert.Equal(t, io.EOF, err)
+}
+
+func BenchmarkObjectStreamParser(b *testing.B) {
+	for _, ext := range []bool{false, true} {
+		b.Run(fmt.Sprintf("extensions=%v", ext), func(b *testing.B) {
+			shm := SubgroupHeaderMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				SubgroupID:        3,
+				PublisherPriority: 4,
+				HasExtensions:     ext,
+			}
+			data := shm.Append(nil)
+			payload := make([]byte, 1024)
+			for i := range 1000 {
+				m := ObjectMessage{
+					ObjectID:      uint64(i),
+					ObjectPayload: payload,
+				}
+				if ext {
+					m.ObjectExtensionHeaders = KVPList{
+						{Type: 2, ValueVarInt: uint64(i)},
+					}
+				}
+				data = m.AppendSubgroup(data)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				p, err := NewObjectStreamParser(bytes.NewReader(data), 0, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for m, err := range p.Messages() {
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+					pool.Put(m.ObjectPayload)
+				}
+			}
+		})
+	}
+}
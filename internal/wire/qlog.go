@@ -0,0 +1,42 @@
+package wire
+
+import (
+	"github.com/mengelbart/moqtransport/internal/slices"
+	"github.com/mengelbart/qlog"
+	"github.com/mengelbart/qlog/moqt"
+)
+
+// QlogExtensionHeaders converts pp to qlog extension headers. Even types carry
+// their value in HeaderValue, odd types carry their length in HeaderLength and
+// their value in Payload.
+func (pp KVPList) QlogExtensionHeaders() moqt.ExtensionHeaders {
+	return slices.Collect(slices.Map(
+		pp,
+		func(e KeyValuePair) moqt.ExtensionHeader {
+			if e.Type%2 == 1 {
+				return moqt.ExtensionHeader{
+					HeaderType:   e.Type,
+					HeaderValue:  0,
+					HeaderLength: uint64(len(e.ValueBytes)),
+					Payload: qlog.RawInfo{
+						Length:        uint64(len(e.ValueBytes)),
+						PayloadLength: uint64(len(e.ValueBytes)),
+						Data:          e.ValueBytes,
+					},
+				}
+			}
+			return moqt.ExtensionHeader{
+				HeaderType:   e.Type,
+				HeaderValue:  e.ValueVarInt,
+				HeaderLength: 0,
+				Payload:      qlog.RawInfo{},
+			}
+		}),
+	)
+}
+
+// EncodedLength returns the length of pp in bytes when encoded with a length
+// prefix, excluding the prefix itself.
+func (pp KVPList) EncodedLength() uint64 {
+	return pp.length()
+}
@@ -9,13 +9,35 @@ type SubgroupHeaderMessage struct {
 	GroupID           uint64
 	SubgroupID        uint64
 	PublisherPriority uint8
+
+	// HasExtensions indicates whether objects on the stream carry extension
+	// headers.
+	HasExtensions bool
+}
+
+// StreamType returns the stream type used to encode the header. The subgroup ID
+// is omitted from the header if it is zero.
+func (m *SubgroupHeaderMessage) StreamType() StreamType {
+	switch {
+	case m.SubgroupID == 0 && m.HasExtensions:
+		return StreamTypeSubgroupZeroSIDExt
+	case m.SubgroupID == 0:
+		return StreamTypeSubgroupZeroSIDNoExt
+	case m.HasExtensions:
+		return StreamTypeSubgroupSIDExt
+	default:
+		return StreamTypeSubgroupSIDNoExt
+	}
 }
 
 func (m *SubgroupHeaderMessage) Append(buf []byte) []byte {
-	buf = quicvarint.Append(buf, uint64(StreamTypeSubgroupSIDExt))
+	typ := m.StreamType()
+	buf = quicvarint.Append(buf, uint64(typ))
 	buf = quicvarint.Append(buf, m.TrackAlias)
 	buf = quicvarint.Append(buf, m.GroupID)
-	buf = quicvarint.Append(buf, m.SubgroupID)
+	if typ == StreamTypeSubgroupSIDExt || typ == StreamTypeSubgroupSIDNoExt {
+		buf = quicvarint.Append(buf, m.SubgroupID)
+	}
 	return append(buf, m.PublisherPriority)
 }
 
@@ -22,9 +22,10 @@ func TestStreamHeaderSubgroupMessageAppend(t *testing.T) {
 				GroupID:           0,
 				SubgroupID:        0,
 				PublisherPriority: 0,
+				HasExtensions:     true,
 			},
 			buf:    []byte{},
-			expect: []byte{byte(StreamTypeSubgroupSIDExt), 0x00, 0x00, 0x00, 0x00},
+			expect: []byte{byte(StreamTypeSubgroupZeroSIDExt), 0x00, 0x00, 0x00},
 		},
 		{
 			shgm: SubgroupHeaderMessage{
@@ -32,6 +33,7 @@ func TestStreamHeaderSubgroupMessageAppend(t *testing.T) {
 				GroupID:           2,
 				SubgroupID:        3,
 				PublisherPriority: 4,
+				HasExtensions:     true,
 			},
 			buf:    []byte{},
 			expect: []byte{byte(StreamTypeSubgroupSIDExt), 0x01, 0x02, 0x03, 0x04},
@@ -42,10 +44,31 @@ func TestStreamHeaderSubgroupMessageAppend(t *testing.T) {
 				GroupID:           2,
 				SubgroupID:        3,
 				PublisherPriority: 4,
+				HasExtensions:     true,
 			},
 			buf:    []byte{0x0a, 0x0b},
 			expect: []byte{0x0a, 0x0b, byte(StreamTypeSubgroupSIDExt), 0x01, 0x02, 0x03, 0x04},
 		},
+		{
+			shgm: SubgroupHeaderMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				SubgroupID:        0,
+				PublisherPriority: 4,
+			},
+			buf:    []byte{},
+			expect: []byte{byte(StreamTypeSubgroupZeroSIDNoExt), 0x01, 0x02, 0x04},
+		},
+		{
+			shgm: SubgroupHeaderMessage{
+				TrackAlias:        1,
+				GroupID:           2,
+				SubgroupID:        3,
+				PublisherPriority: 4,
+			},
+			buf:    []byte{},
+			expect: []byte{byte(StreamTypeSubgroupSIDNoExt), 0x01, 0x02, 0x03, 0x04},
+		},
 	}
 	for i, tc := range cases {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
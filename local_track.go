@@ -4,8 +4,9 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/mengelbart/moqtransport/internal/slices"
 	"github.com/mengelbart/moqtransport/internal/wire"
 	"github.com/mengelbart/qlog"
 	"github.com/mengelbart/qlog/moqt"
@@ -14,6 +15,8 @@ import (
 var (
 	ErrUnsusbcribed     = errors.New("track closed, peer unsubscribed")
 	ErrSubscriptionDone = errors.New("track closed, subscription done")
+
+	errStatusObjectWithPayload = errors.New("objects with a status other than normal must not carry a payload")
 )
 
 type subscribeDoneCallback func(code, count uint64, reason string) error
@@ -21,10 +24,32 @@ type subscribeDoneCallback func(code, count uint64, reason string) error
 type localTrack struct {
 	qlogger *qlog.Logger
 
-	conn            Connection
-	requestID       uint64
-	trackAlias      uint64
-	subgroupCount   uint64
+	conn               Connection
+	scheduler          *sendScheduler
+	requestID          uint64
+	trackAlias         uint64
+	subscriberPriority atomic.Uint32
+	groupOrder         atomic.Uint32
+	subgroupCount      uint64
+
+	// Delivery timeouts requested by the subscriber in SUBSCRIBE or
+	// SUBSCRIBE_UPDATE and by the publisher in SUBSCRIBE_OK.
+	subscriberDeliveryTimeout atomic.Int64
+	publisherDeliveryTimeout  atomic.Int64
+
+	// filterLock protects the filter requested by the subscriber in SUBSCRIBE
+	// and narrowed by SUBSCRIBE_UPDATE.
+	filterLock sync.Mutex
+	filter     subscriptionFilter
+	forward    atomic.Bool
+
+	// namespace and track are the subscribed track and largest is the
+	// largest location sent in SUBSCRIBE_OK, if any. Joining fetches are
+	// resolved against them.
+	namespace []string
+	track     string
+	largest   atomic.Pointer[Location]
+
 	fetchStreamLock sync.Mutex
 	fetchStream     *FetchStream
 	ctx             context.Context
@@ -32,23 +57,116 @@ type localTrack struct {
 	subscribeDone   subscribeDoneCallback
 }
 
-func newLocalTrack(conn Connection, requestID, trackAlias uint64, onSubscribeDone subscribeDoneCallback, qlogger *qlog.Logger) *localTrack {
+func newLocalTrack(conn Connection, scheduler *sendScheduler, requestID, trackAlias uint64, subscriberPriority uint8, groupOrder GroupOrder, onSubscribeDone subscribeDoneCallback, qlogger *qlog.Logger) *localTrack {
 	ctx, cancel := context.WithCancelCause(context.Background())
 	lt := &localTrack{
-		qlogger:         qlogger,
-		conn:            conn,
-		requestID:       requestID,
-		trackAlias:      trackAlias,
-		subgroupCount:   0,
+		qlogger:            qlogger,
+		conn:               conn,
+		scheduler:          scheduler,
+		requestID:          requestID,
+		trackAlias:         trackAlias,
+		subscriberPriority: atomic.Uint32{},
+		groupOrder:         atomic.Uint32{},
+		subgroupCount:      0,
+
+		subscriberDeliveryTimeout: atomic.Int64{},
+		publisherDeliveryTimeout:  atomic.Int64{},
+
+		filterLock: sync.Mutex{},
+		filter:     newSubscriptionFilter(FilterTypeLatestObject, Location{}, 0),
+		forward:    atomic.Bool{},
+
+		namespace: nil,
+		track:     "",
+		largest:   atomic.Pointer[Location]{},
+
 		fetchStreamLock: sync.Mutex{},
 		fetchStream:     nil,
 		ctx:             ctx,
 		cancelCtx:       cancel,
 		subscribeDone:   onSubscribeDone,
 	}
+	lt.setSubscriberPriority(subscriberPriority)
+	lt.setGroupOrder(groupOrder)
+	lt.forward.Store(true)
 	return lt
 }
 
+func (p *localTrack) setFilter(filter subscriptionFilter) {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	p.filter = filter
+}
+
+// resolveFilter resolves the start of relative filters given the location of
+// the next object published on the track.
+func (p *localTrack) resolveFilter(next Location) {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	p.filter.resolve(next)
+}
+
+func (p *localTrack) updateFilter(start Location, endGroup uint64) {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	p.filter.update(start, endGroup)
+}
+
+func (p *localTrack) getFilter() subscriptionFilter {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	return p.filter
+}
+
+func (p *localTrack) setSubscriberPriority(priority uint8) {
+	p.subscriberPriority.Store(uint32(priority))
+}
+
+func (p *localTrack) setGroupOrder(groupOrder GroupOrder) {
+	p.groupOrder.Store(uint32(groupOrder))
+}
+
+func (p *localTrack) sendPriority(publisherPriority uint8, groupID uint64) sendPriority {
+	return sendPriority{
+		subscriberPriority: uint8(p.subscriberPriority.Load()),
+		publisherPriority:  publisherPriority,
+		groupOrder:         GroupOrder(p.groupOrder.Load()),
+		groupID:            groupID,
+	}
+}
+
+// deliveryTimeout returns the effective delivery timeout of the track, which
+// is the smaller of the subscriber's and the publisher's timeout. Zero means
+// no timeout.
+func (p *localTrack) deliveryTimeout() time.Duration {
+	return minDeliveryTimeout(
+		time.Duration(p.subscriberDeliveryTimeout.Load()),
+		time.Duration(p.publisherDeliveryTimeout.Load()),
+	)
+}
+
+// write writes buf to stream through the session's send scheduler. If the
+// write does not complete within the delivery timeout, the stream is reset.
+// Each object is written with one call to write, so the timeout applies per
+// object from the time it is written. Once the transport accepted all data of
+// an object, the object is no longer subject to the timeout, because the
+// transport does not report when the data was acknowledged.
+func (p *localTrack) write(stream SendStream, publisherPriority uint8, groupID uint64, buf []byte) (int, error) {
+	return p.writeSince(time.Now(), stream, publisherPriority, groupID, buf)
+}
+
+// writeSince is like write for a part of an object of which the first part
+// was written at start. The delivery timeout is measured from start.
+func (p *localTrack) writeSince(start time.Time, stream SendStream, publisherPriority uint8, groupID uint64, buf []byte) (int, error) {
+	if timeout := p.deliveryTimeout(); timeout > 0 {
+		timer := time.AfterFunc(time.Until(start.Add(timeout)), func() {
+			stream.Reset(uint32(ErrorCodeStreamDeliveryTimeout))
+		})
+		defer timer.Stop()
+	}
+	return p.scheduler.write(p.ctx, p.sendPriority(publisherPriority, groupID), stream, buf)
+}
+
 func (p *localTrack) getFetchStream() (*FetchStream, error) {
 	p.fetchStreamLock.Lock()
 	defer p.fetchStreamLock.Unlock()
@@ -63,42 +181,50 @@ func (p *localTrack) getFetchStream() (*FetchStream, error) {
 	if err != nil {
 		return nil, err
 	}
-	p.fetchStream, err = newFetchStream(stream, p.requestID, p.qlogger)
+	p.fetchStream, err = newFetchStream(p, stream)
 	if err != nil {
 		return nil, err
 	}
 	return p.fetchStream, nil
 }
 
-func (p *localTrack) sendDatagram(o Object) error {
+func (p *localTrack) sendDatagram(o Object, options ...DatagramOption) error {
 	if err := p.closed(); err != nil {
 		return err
 	}
+	if o.Status != ObjectStatusNormal && len(o.Payload) > 0 {
+		return errStatusObjectWithPayload
+	}
+	opts := &DatagramOptions{
+		StreamFallback: false,
+	}
+	for _, option := range options {
+		option(opts)
+	}
 	om := &wire.ObjectDatagramMessage{
 		TrackAlias:             p.trackAlias,
 		GroupID:                o.GroupID,
 		ObjectID:               o.ObjectID,
-		PublisherPriority:      0,
-		ObjectExtensionHeaders: nil,
-		ObjectStatus:           0,
+		PublisherPriority:      o.PublisherPriority,
+		ObjectExtensionHeaders: o.ExtensionHeaders.ToWire(),
+		ObjectStatus:           o.Status,
 		ObjectPayload:          o.Payload,
 	}
 	var buf []byte
-	buf = om.AppendDatagram(buf)
+	buf = om.Append(buf)
+	err := p.scheduler.do(p.ctx, p.sendPriority(o.PublisherPriority, o.GroupID), func() error {
+		return p.conn.SendDatagram(buf)
+	})
+	var tooLarge *DatagramTooLargeError
+	if errors.As(err, &tooLarge) && opts.StreamFallback {
+		return p.sendSingleObjectSubgroup(o)
+	}
+	if err != nil {
+		return err
+	}
 	if p.qlogger != nil {
-		eth := slices.Collect(slices.Map(
-			om.ObjectExtensionHeaders,
-			func(e wire.KeyValuePair) moqt.ExtensionHeader {
-				return moqt.ExtensionHeader{
-					HeaderType:   0, // TODO
-					HeaderValue:  0, // TODO
-					HeaderLength: 0, // TODO
-					Payload:      qlog.RawInfo{},
-				}
-			}),
-		)
 		name := moqt.ObjectDatagramEventCreated
-		if len(om.ObjectPayload) > 0 {
+		if om.ObjectStatus != wire.ObjectStatusNormal {
 			name = moqt.ObjectDatagramStatusEventCreated
 		}
 		p.qlogger.Log(moqt.ObjectDatagramEvent{
@@ -107,8 +233,8 @@ func (p *localTrack) sendDatagram(o Object) error {
 			GroupID:                om.GroupID,
 			ObjectID:               om.ObjectID,
 			PublisherPriority:      om.PublisherPriority,
-			ExtensionHeadersLength: uint64(len(om.ObjectExtensionHeaders)),
-			ExtensionHeaders:       eth,
+			ExtensionHeadersLength: om.ObjectExtensionHeaders.EncodedLength(),
+			ExtensionHeaders:       om.ObjectExtensionHeaders.QlogExtensionHeaders(),
 			ObjectStatus:           uint64(om.ObjectStatus),
 			Payload: qlog.RawInfo{
 				Length:        uint64(len(om.ObjectPayload)),
@@ -117,19 +243,39 @@ func (p *localTrack) sendDatagram(o Object) error {
 			},
 		})
 	}
-	return p.conn.SendDatagram(buf)
+	return nil
+}
+
+// sendSingleObjectSubgroup sends o on a new subgroup stream containing only o.
+// The subgroup ID equals the object ID, matching the subgroup ID receivers
+// assign to datagram objects.
+func (p *localTrack) sendSingleObjectSubgroup(o Object) error {
+	sg, err := p.openSubgroup(o.GroupID, o.ObjectID, o.PublisherPriority, WithSubgroupExtensions(len(o.ExtensionHeaders) > 0))
+	if err != nil {
+		return err
+	}
+	if err = sg.writeObject(o.ObjectID, o.Status, o.ExtensionHeaders, o.Payload); err != nil {
+		return err
+	}
+	return sg.Close()
 }
 
-func (p *localTrack) openSubgroup(groupID, subgroupID uint64, priority uint8) (*Subgroup, error) {
+func (p *localTrack) openSubgroup(groupID, subgroupID uint64, priority uint8, options ...SubgroupOption) (*Subgroup, error) {
 	if err := p.closed(); err != nil {
 		return nil, err
 	}
+	opts := &SubgroupOptions{
+		Extensions: true,
+	}
+	for _, option := range options {
+		option(opts)
+	}
 	stream, err := p.conn.OpenUniStream()
 	if err != nil {
 		return nil, err
 	}
 	p.subgroupCount++
-	return newSubgroup(stream, p.trackAlias, groupID, subgroupID, priority, p.qlogger)
+	return newSubgroup(p, stream, groupID, subgroupID, priority, opts.Extensions)
 }
 
 func (s *localTrack) close(code uint64, reason string) error {
@@ -41,6 +41,14 @@ func (m *localTrackMap) findByID(id uint64) (*localTrack, bool) {
 	return sub, ok
 }
 
+// findOpen returns the track with request ID id if it was accepted.
+func (m *localTrackMap) findOpen(id uint64) (*localTrack, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	lt, ok := m.open[id]
+	return lt, ok
+}
+
 func (m *localTrackMap) delete(id uint64) (*localTrack, bool) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
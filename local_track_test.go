@@ -0,0 +1,92 @@
+package moqtransport
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestLocalTrackDeliveryTimeout(t *testing.T) {
+	t.Run("uses_smaller_timeout", func(t *testing.T) {
+		lt := newLocalTrack(nil, nil, 0, 0, 128, GroupOrderAscending, nil, nil)
+		assert.Equal(t, time.Duration(0), lt.deliveryTimeout())
+		lt.subscriberDeliveryTimeout.Store(int64(time.Second))
+		assert.Equal(t, time.Second, lt.deliveryTimeout())
+		lt.publisherDeliveryTimeout.Store(int64(100 * time.Millisecond))
+		assert.Equal(t, 100*time.Millisecond, lt.deliveryTimeout())
+	})
+
+	t.Run("resets_blocked_write", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := NewMockConnection(ctrl)
+		stream := NewMockSendStream(ctrl)
+		conn.EXPECT().OpenUniStream().Return(stream, nil)
+
+		lt := newLocalTrack(conn, newSendScheduler(), 0, 0, 128, GroupOrderAscending, nil, nil)
+		lt.subscriberDeliveryTimeout.Store(int64(10 * time.Millisecond))
+
+		resetCh := make(chan struct{})
+		gomock.InOrder(
+			stream.EXPECT().Write(gomock.Any()).Return(5, nil),
+			stream.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				<-resetCh
+				return 0, errors.New("stream reset")
+			}),
+		)
+		stream.EXPECT().Reset(uint32(ErrorCodeStreamDeliveryTimeout)).Do(func(uint32) {
+			close(resetCh)
+		})
+
+		sg, err := lt.openSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("stale"))
+		assert.Error(t, err)
+	})
+
+	t.Run("timeout_per_object", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := NewMockConnection(ctrl)
+		stream := NewMockSendStream(ctrl)
+		conn.EXPECT().OpenUniStream().Return(stream, nil)
+
+		lt := newLocalTrack(conn, newSendScheduler(), 0, 0, 128, GroupOrderAscending, nil, nil)
+		lt.publisherDeliveryTimeout.Store(int64(10 * time.Millisecond))
+
+		// Objects written after the subgroup was open for longer than the
+		// timeout and completed subgroups are not reset.
+		stream.EXPECT().Write(gomock.Any()).Return(5, nil).Times(3)
+		stream.EXPECT().Close()
+
+		sg, err := lt.openSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("hello"))
+		assert.NoError(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = sg.WriteObject(1, []byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.Close())
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	t.Run("no_timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := NewMockConnection(ctrl)
+		stream := NewMockSendStream(ctrl)
+		conn.EXPECT().OpenUniStream().Return(stream, nil)
+
+		lt := newLocalTrack(conn, newSendScheduler(), 0, 0, 128, GroupOrderAscending, nil, nil)
+
+		stream.EXPECT().Write(gomock.Any()).Return(5, nil).Times(2)
+		stream.EXPECT().Close()
+
+		sg, err := lt.openSubgroup(0, 0, 0)
+		assert.NoError(t, err)
+		_, err = sg.WriteObject(0, []byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, sg.Close())
+		time.Sleep(20 * time.Millisecond)
+	})
+}
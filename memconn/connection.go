@@ -0,0 +1,434 @@
+// Package memconn implements moqtransport.Connection over in-process pipes.
+//
+// Pipe returns two connected connections, one with the client and one with
+// the server perspective. Streams and datagrams written on one connection are
+// received by the other without any network I/O, which allows running
+// publishers and subscribers in one process and writing fast, deterministic
+// tests.
+package memconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// defaultMaxDatagramSize is the default maximum size of a datagram. It
+// matches the datagram payload size available on most QUIC connections.
+const defaultMaxDatagramSize = 1200
+
+// defaultDatagramQueue is the default number of datagrams queued for the
+// receiver.
+const defaultDatagramQueue = 1024
+
+var errStreamClosed = errors.New("write on closed stream")
+
+// ApplicationError is returned by the operations of a connection after the
+// connection was closed with CloseWithError.
+type ApplicationError struct {
+	// Remote is true if the peer closed the connection
+	Remote bool
+
+	// ErrorCode is the error code passed to CloseWithError
+	ErrorCode uint64
+
+	// ErrorMessage is the reason passed to CloseWithError
+	ErrorMessage string
+}
+
+func (e *ApplicationError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	return fmt.Sprintf("application error %v (%v): %v", e.ErrorCode, side, e.ErrorMessage)
+}
+
+// StreamError is returned by Read after the stream was stopped locally and by
+// Write after the peer stopped the stream.
+type StreamError struct {
+	// Remote is true if the peer stopped the stream
+	Remote bool
+
+	// ErrorCode is the error code passed to Stop
+	ErrorCode uint64
+}
+
+func (e *StreamError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	return fmt.Sprintf("stream stopped (%v), error code: %v", side, e.ErrorCode)
+}
+
+// Option is a functional option for configuring a Pipe.
+type Option func(*Options)
+
+// Options contains options for the connections created by Pipe.
+type Options struct {
+	// Protocol is the protocol reported by the connections
+	Protocol moqtransport.Protocol
+
+	// MaxDatagramSize is the maximum size of a datagram
+	MaxDatagramSize int
+
+	// DatagramQueue is the maximum number of datagrams queued for the
+	// receiver
+	DatagramQueue int
+
+	// StreamWindow is the maximum number of bytes buffered per stream
+	// direction, zero means unlimited
+	StreamWindow int
+}
+
+// WithProtocol sets the protocol reported by the connections. Default is
+// moqtransport.ProtocolQUIC.
+func WithProtocol(protocol moqtransport.Protocol) Option {
+	return func(opts *Options) {
+		opts.Protocol = protocol
+	}
+}
+
+// WithMaxDatagramSize sets the maximum size of a datagram. Larger datagrams
+// are rejected with a *moqtransport.DatagramTooLargeError. Default is 1200.
+func WithMaxDatagramSize(size int) Option {
+	return func(opts *Options) {
+		opts.MaxDatagramSize = size
+	}
+}
+
+// WithDatagramQueue sets the maximum number of datagrams queued for the
+// receiver. Datagrams sent while the queue is full are dropped. Default is
+// 1024.
+func WithDatagramQueue(n int) Option {
+	return func(opts *Options) {
+		opts.DatagramQueue = n
+	}
+}
+
+// WithStreamWindow sets the maximum number of bytes buffered in each
+// direction of a stream until the receiver reads them. Write blocks while the
+// window is full, like a QUIC stream without flow control credit. Default is
+// 0, which means unlimited.
+func WithStreamWindow(n int) Option {
+	return func(opts *Options) {
+		opts.StreamWindow = n
+	}
+}
+
+// link holds the state shared by the two connections of a pipe. All state,
+// including the state of the streams, is protected by one lock.
+type link struct {
+	lock sync.Mutex
+
+	// changed is closed and replaced after every change of the state.
+	changed chan struct{}
+
+	closed  bool
+	closeBy moqtransport.Perspective
+	code    uint64
+	reason  string
+
+	maxDatagramSize int
+	datagramQueue   int
+	streamWindow    int
+}
+
+// await calls try with the lock held until it returns true or an error, ctx
+// is done or the link is closed. The error of a closed link is returned for
+// the connection with perspective.
+func (l *link) await(ctx context.Context, perspective moqtransport.Perspective, try func() (bool, error)) error {
+	for {
+		l.lock.Lock()
+		if l.closed {
+			err := l.closeError(perspective)
+			l.lock.Unlock()
+			return err
+		}
+		done, err := try()
+		if done || err != nil {
+			l.lock.Unlock()
+			return err
+		}
+		changed := l.changed
+		l.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-changed:
+		}
+	}
+}
+
+// broadcast wakes all goroutines waiting for a change. l.lock must be held.
+func (l *link) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// closeError returns the error of operations of the connection with
+// perspective after the link was closed. l.lock must be held.
+func (l *link) closeError(perspective moqtransport.Perspective) error {
+	return &ApplicationError{
+		Remote:       l.closeBy != perspective,
+		ErrorCode:    l.code,
+		ErrorMessage: l.reason,
+	}
+}
+
+// Conn is one end of an in-process connection created by Pipe.
+type Conn struct {
+	link        *link
+	perspective moqtransport.Perspective
+	protocol    moqtransport.Protocol
+	peer        *Conn
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// The fields below are protected by the lock of the link.
+	streams    []*Stream
+	uniStreams []*ReceiveStream
+	datagrams  [][]byte
+	nextBidi   uint64
+	nextUni    uint64
+}
+
+var _ moqtransport.Connection = (*Conn)(nil)
+
+// Pipe creates two connected in-process connections. Streams can always be
+// opened.
+//
+// Default behavior when no options are provided:
+//   - Protocol: moqtransport.ProtocolQUIC
+//   - MaxDatagramSize: 1200
+//   - DatagramQueue: 1024
+//   - StreamWindow: 0 (unlimited)
+func Pipe(options ...Option) (client, server *Conn) {
+	opts := &Options{
+		Protocol:        moqtransport.ProtocolQUIC,
+		MaxDatagramSize: defaultMaxDatagramSize,
+		DatagramQueue:   defaultDatagramQueue,
+		StreamWindow:    0,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	l := &link{
+		lock:            sync.Mutex{},
+		changed:         make(chan struct{}),
+		closed:          false,
+		closeBy:         0,
+		code:            0,
+		reason:          "",
+		maxDatagramSize: opts.MaxDatagramSize,
+		datagramQueue:   opts.DatagramQueue,
+		streamWindow:    opts.StreamWindow,
+	}
+	client = newConn(l, moqtransport.PerspectiveClient, opts.Protocol)
+	server = newConn(l, moqtransport.PerspectiveServer, opts.Protocol)
+	client.peer = server
+	server.peer = client
+	return client, server
+}
+
+func newConn(l *link, perspective moqtransport.Perspective, protocol moqtransport.Protocol) *Conn {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &Conn{
+		link:        l,
+		perspective: perspective,
+		protocol:    protocol,
+		peer:        nil,
+		ctx:         ctx,
+		cancel:      cancel,
+		streams:     []*Stream{},
+		uniStreams:  []*ReceiveStream{},
+		datagrams:   [][]byte{},
+		nextBidi:    0,
+		nextUni:     0,
+	}
+}
+
+// AcceptStream implements moqtransport.Connection.
+func (c *Conn) AcceptStream(ctx context.Context) (moqtransport.Stream, error) {
+	var s *Stream
+	err := c.link.await(ctx, c.perspective, func() (bool, error) {
+		if len(c.streams) == 0 {
+			return false, nil
+		}
+		s = c.streams[0]
+		c.streams = c.streams[1:]
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AcceptUniStream implements moqtransport.Connection.
+func (c *Conn) AcceptUniStream(ctx context.Context) (moqtransport.ReceiveStream, error) {
+	var s *ReceiveStream
+	err := c.link.await(ctx, c.perspective, func() (bool, error) {
+		if len(c.uniStreams) == 0 {
+			return false, nil
+		}
+		s = c.uniStreams[0]
+		c.uniStreams = c.uniStreams[1:]
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenStream implements moqtransport.Connection. The peer can accept the
+// stream immediately.
+func (c *Conn) OpenStream() (moqtransport.Stream, error) {
+	c.link.lock.Lock()
+	defer c.link.lock.Unlock()
+	if c.link.closed {
+		return nil, c.link.closeError(c.perspective)
+	}
+	// Stream IDs are assigned like in QUIC: the lowest bit is the
+	// perspective of the opener and the second bit is set for
+	// unidirectional streams.
+	id := c.nextBidi<<2 | uint64(c.perspective)
+	c.nextBidi++
+	out := newPipe(c.link, id)
+	in := newPipe(c.link, id)
+	local := &Stream{
+		ReceiveStream: ReceiveStream{pipe: in, perspective: c.perspective},
+		SendStream:    SendStream{pipe: out, perspective: c.perspective},
+	}
+	remote := &Stream{
+		ReceiveStream: ReceiveStream{pipe: out, perspective: c.peer.perspective},
+		SendStream:    SendStream{pipe: in, perspective: c.peer.perspective},
+	}
+	c.peer.streams = append(c.peer.streams, remote)
+	c.link.broadcast()
+	return local, nil
+}
+
+// OpenStreamSync implements moqtransport.Connection. It does not block,
+// because streams can always be opened.
+func (c *Conn) OpenStreamSync(ctx context.Context) (moqtransport.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.OpenStream()
+}
+
+// OpenUniStream implements moqtransport.Connection. The peer can accept the
+// stream immediately.
+func (c *Conn) OpenUniStream() (moqtransport.SendStream, error) {
+	c.link.lock.Lock()
+	defer c.link.lock.Unlock()
+	if c.link.closed {
+		return nil, c.link.closeError(c.perspective)
+	}
+	id := c.nextUni<<2 | 0x02 | uint64(c.perspective)
+	c.nextUni++
+	p := newPipe(c.link, id)
+	c.peer.uniStreams = append(c.peer.uniStreams, &ReceiveStream{
+		pipe:        p,
+		perspective: c.peer.perspective,
+	})
+	c.link.broadcast()
+	return &SendStream{
+		pipe:        p,
+		perspective: c.perspective,
+	}, nil
+}
+
+// OpenUniStreamSync implements moqtransport.Connection. It does not block,
+// because streams can always be opened.
+func (c *Conn) OpenUniStreamSync(ctx context.Context) (moqtransport.SendStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.OpenUniStream()
+}
+
+// SendDatagram implements moqtransport.Connection. Datagrams are dropped if
+// the queue of the peer is full.
+func (c *Conn) SendDatagram(b []byte) error {
+	c.link.lock.Lock()
+	defer c.link.lock.Unlock()
+	if c.link.closed {
+		return c.link.closeError(c.perspective)
+	}
+	if len(b) > c.link.maxDatagramSize {
+		return &moqtransport.DatagramTooLargeError{
+			MaxDatagramSize: int64(c.link.maxDatagramSize),
+		}
+	}
+	if len(c.peer.datagrams) >= c.link.datagramQueue {
+		return nil
+	}
+	c.peer.datagrams = append(c.peer.datagrams, append([]byte(nil), b...))
+	c.link.broadcast()
+	return nil
+}
+
+// ReceiveDatagram implements moqtransport.Connection.
+func (c *Conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	var d []byte
+	err := c.link.await(ctx, c.perspective, func() (bool, error) {
+		if len(c.datagrams) == 0 {
+			return false, nil
+		}
+		d = c.datagrams[0]
+		c.datagrams[0] = nil
+		c.datagrams = c.datagrams[1:]
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CloseWithError implements moqtransport.Connection. It closes both
+// connections of the pipe. Pending and later operations on streams and
+// connections return an *ApplicationError.
+func (c *Conn) CloseWithError(code uint64, reason string) error {
+	c.link.lock.Lock()
+	if c.link.closed {
+		c.link.lock.Unlock()
+		return nil
+	}
+	c.link.closed = true
+	c.link.closeBy = c.perspective
+	c.link.code = code
+	c.link.reason = reason
+	c.link.broadcast()
+	localErr := c.link.closeError(c.perspective)
+	remoteErr := c.link.closeError(c.peer.perspective)
+	c.link.lock.Unlock()
+
+	c.cancel(localErr)
+	c.peer.cancel(remoteErr)
+	return nil
+}
+
+// Context implements moqtransport.Connection. The context is cancelled with
+// an *ApplicationError when the connection is closed.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Protocol implements moqtransport.Connection.
+func (c *Conn) Protocol() moqtransport.Protocol {
+	return c.protocol
+}
+
+// Perspective implements moqtransport.Connection.
+func (c *Conn) Perspective() moqtransport.Perspective {
+	return c.perspective
+}
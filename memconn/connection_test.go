@@ -0,0 +1,170 @@
+package memconn
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipe(t *testing.T) {
+	t.Run("bidirectional_stream", func(t *testing.T) {
+		client, server := Pipe()
+		cs, err := client.OpenStreamSync(context.Background())
+		assert.NoError(t, err)
+		_, err = cs.Write([]byte("hello"))
+		assert.NoError(t, err)
+
+		ss, err := server.AcceptStream(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), cs.StreamID())
+		assert.Equal(t, uint64(0), ss.StreamID())
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(ss, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(buf))
+
+		_, err = ss.Write([]byte("world"))
+		assert.NoError(t, err)
+		assert.NoError(t, ss.Close())
+		data, err := io.ReadAll(cs)
+		assert.NoError(t, err)
+		assert.Equal(t, "world", string(data))
+
+		next, err := server.OpenStream()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), next.StreamID())
+	})
+
+	t.Run("unidirectional_stream", func(t *testing.T) {
+		client, server := Pipe()
+		for i := range 2 {
+			s, err := server.OpenUniStream()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(i)<<2|0x03, s.StreamID())
+			_, err = s.Write([]byte{byte(i)})
+			assert.NoError(t, err)
+			assert.NoError(t, s.Close())
+		}
+		for i := range 2 {
+			r, err := client.AcceptUniStream(context.Background())
+			assert.NoError(t, err)
+			data, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{byte(i)}, data)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		client, server := Pipe()
+		s, err := client.OpenUniStream()
+		assert.NoError(t, err)
+		_, err = s.Write([]byte("discarded"))
+		assert.NoError(t, err)
+		s.Reset(7)
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		_, err = r.Read(make([]byte, 10))
+		assert.Equal(t, &moqtransport.StreamResetError{ErrorCode: 7}, err)
+		_, err = s.Write([]byte("hello"))
+		assert.Error(t, err)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		client, server := Pipe()
+		s, err := client.OpenUniStream()
+		assert.NoError(t, err)
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		r.Stop(3)
+
+		_, err = s.Write([]byte("hello"))
+		assert.Equal(t, &StreamError{Remote: true, ErrorCode: 3}, err)
+		_, err = r.Read(make([]byte, 10))
+		assert.Equal(t, &StreamError{Remote: false, ErrorCode: 3}, err)
+	})
+
+	t.Run("stream_window", func(t *testing.T) {
+		client, server := Pipe(WithStreamWindow(4))
+		s, err := client.OpenUniStream()
+		assert.NoError(t, err)
+
+		written := make(chan error)
+		go func() {
+			_, err := s.Write([]byte("hello world"))
+			written <- err
+		}()
+		select {
+		case err := <-written:
+			assert.Fail(t, "write did not block", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		r, err := server.AcceptUniStream(context.Background())
+		assert.NoError(t, err)
+		buf := make([]byte, 11)
+		_, err = io.ReadFull(r, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", string(buf))
+		assert.NoError(t, <-written)
+
+		// A blocked write fails when the receiver stops the stream.
+		go func() {
+			_, err := s.Write([]byte("hello world"))
+			written <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		r.Stop(5)
+		assert.Equal(t, &StreamError{Remote: true, ErrorCode: 5}, <-written)
+	})
+
+	t.Run("datagrams", func(t *testing.T) {
+		client, server := Pipe(WithMaxDatagramSize(4), WithDatagramQueue(2))
+		for _, d := range []string{"a", "b", "drop"} {
+			assert.NoError(t, client.SendDatagram([]byte(d)))
+		}
+		err := client.SendDatagram([]byte("too large"))
+		assert.Equal(t, &moqtransport.DatagramTooLargeError{MaxDatagramSize: 4}, err)
+
+		for _, want := range []string{"a", "b"} {
+			d, err := server.ReceiveDatagram(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, want, string(d))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = server.ReceiveDatagram(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("close_with_error", func(t *testing.T) {
+		client, server := Pipe()
+		s, err := client.OpenStream()
+		assert.NoError(t, err)
+
+		accepted := make(chan error)
+		go func() {
+			_, err := server.AcceptUniStream(context.Background())
+			accepted <- err
+		}()
+		assert.NoError(t, server.CloseWithError(42, "bye"))
+
+		remote := &ApplicationError{Remote: true, ErrorCode: 42, ErrorMessage: "bye"}
+		local := &ApplicationError{Remote: false, ErrorCode: 42, ErrorMessage: "bye"}
+		assert.Equal(t, local, <-accepted)
+		_, err = s.Read(make([]byte, 1))
+		assert.Equal(t, remote, err)
+		_, err = s.Write([]byte("hello"))
+		assert.Equal(t, remote, err)
+		_, err = client.OpenUniStream()
+		assert.Equal(t, remote, err)
+
+		<-client.Context().Done()
+		assert.Equal(t, remote, context.Cause(client.Context()))
+		assert.Equal(t, local, context.Cause(server.Context()))
+	})
+}
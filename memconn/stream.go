@@ -0,0 +1,190 @@
+package memconn
+
+import (
+	"context"
+	"io"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// pipe is one direction of a stream. It is protected by the lock of the
+// link.
+type pipe struct {
+	link *link
+	id   uint64
+
+	buf []byte
+	fin bool
+
+	reset     bool
+	resetCode uint64
+
+	stopped  bool
+	stopCode uint64
+}
+
+func newPipe(l *link, id uint64) *pipe {
+	return &pipe{
+		link:      l,
+		id:        id,
+		buf:       []byte{},
+		fin:       false,
+		reset:     false,
+		resetCode: 0,
+		stopped:   false,
+		stopCode:  0,
+	}
+}
+
+// ReceiveStream is the receiving end of a stream.
+type ReceiveStream struct {
+	pipe        *pipe
+	perspective moqtransport.Perspective
+}
+
+var _ moqtransport.ReceiveStream = (*ReceiveStream)(nil)
+
+// Read implements moqtransport.ReceiveStream. Data that was not read before
+// the sender reset the stream is discarded.
+func (r *ReceiveStream) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n := 0
+	err := r.pipe.link.await(context.Background(), r.perspective, func() (bool, error) {
+		p := r.pipe
+		if p.stopped {
+			return false, &StreamError{
+				Remote:    false,
+				ErrorCode: p.stopCode,
+			}
+		}
+		if p.reset {
+			return false, &moqtransport.StreamResetError{
+				ErrorCode: p.resetCode,
+			}
+		}
+		if len(p.buf) > 0 {
+			n = copy(b, p.buf)
+			p.buf = p.buf[n:]
+			if p.link.streamWindow > 0 {
+				// Wake writers waiting for the window.
+				p.link.broadcast()
+			}
+			return true, nil
+		}
+		if p.fin {
+			return false, io.EOF
+		}
+		return false, nil
+	})
+	return n, err
+}
+
+// Stop implements moqtransport.ReceiveStream. Later writes of the sender fail
+// with a *StreamError.
+func (r *ReceiveStream) Stop(code uint32) {
+	r.pipe.link.lock.Lock()
+	defer r.pipe.link.lock.Unlock()
+	if r.pipe.stopped {
+		return
+	}
+	r.pipe.stopped = true
+	r.pipe.stopCode = uint64(code)
+	r.pipe.buf = nil
+	r.pipe.link.broadcast()
+}
+
+// StreamID implements moqtransport.ReceiveStream.
+func (r *ReceiveStream) StreamID() uint64 {
+	return r.pipe.id
+}
+
+// SendStream is the sending end of a stream.
+type SendStream struct {
+	pipe        *pipe
+	perspective moqtransport.Perspective
+}
+
+var _ moqtransport.SendStream = (*SendStream)(nil)
+
+// Write implements moqtransport.SendStream. If the pipe has a stream window,
+// Write blocks until all of b is buffered within the window.
+func (s *SendStream) Write(b []byte) (int, error) {
+	n := 0
+	err := s.pipe.link.await(context.Background(), s.perspective, func() (bool, error) {
+		p := s.pipe
+		if p.stopped {
+			return false, &StreamError{
+				Remote:    true,
+				ErrorCode: p.stopCode,
+			}
+		}
+		if p.fin || p.reset {
+			return false, errStreamClosed
+		}
+		m := len(b) - n
+		if window := p.link.streamWindow; window > 0 {
+			m = min(m, window-len(p.buf))
+		}
+		if m > 0 {
+			p.buf = append(p.buf, b[n:n+m]...)
+			n += m
+			p.link.broadcast()
+		}
+		return n == len(b), nil
+	})
+	return n, err
+}
+
+// Close implements moqtransport.SendStream.
+func (s *SendStream) Close() error {
+	l := s.pipe.link
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return l.closeError(s.perspective)
+	}
+	if s.pipe.reset {
+		return errStreamClosed
+	}
+	if s.pipe.fin {
+		return nil
+	}
+	s.pipe.fin = true
+	l.broadcast()
+	return nil
+}
+
+// Reset implements moqtransport.SendStream. A reset has no effect after the
+// receiver read all data of a closed stream.
+func (s *SendStream) Reset(code uint32) {
+	l := s.pipe.link
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if s.pipe.reset || (s.pipe.fin && len(s.pipe.buf) == 0) {
+		return
+	}
+	s.pipe.reset = true
+	s.pipe.resetCode = uint64(code)
+	s.pipe.buf = nil
+	l.broadcast()
+}
+
+// StreamID implements moqtransport.SendStream.
+func (s *SendStream) StreamID() uint64 {
+	return s.pipe.id
+}
+
+// Stream is a bidirectional stream.
+type Stream struct {
+	ReceiveStream
+	SendStream
+}
+
+var _ moqtransport.Stream = (*Stream)(nil)
+
+// StreamID implements moqtransport.Stream.
+func (s *Stream) StreamID() uint64 {
+	return s.SendStream.pipe.id
+}
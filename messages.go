@@ -98,6 +98,34 @@ func (kvpl KVPList) GetDeliveryTimeout() (time.Duration, bool) {
 	return 0, false
 }
 
+// withDeliveryTimeout returns kvpl with the delivery timeout parameter set to
+// timeout. An existing delivery timeout parameter is replaced.
+func (kvpl KVPList) withDeliveryTimeout(timeout time.Duration) KVPList {
+	param := KeyValuePair{
+		Type:        wire.DeliveryTimeoutParameterKey,
+		ValueVarInt: uint64(timeout.Milliseconds()),
+	}
+	for i, p := range kvpl {
+		if p.Type == wire.DeliveryTimeoutParameterKey {
+			kvpl[i] = param
+			return kvpl
+		}
+	}
+	return append(kvpl, param)
+}
+
+// minDeliveryTimeout returns the smaller of two delivery timeouts. A timeout of
+// zero means no timeout.
+func minDeliveryTimeout(a, b time.Duration) time.Duration {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	return min(a, b)
+}
+
 // GetMaxCacheDuration extracts the max cache duration parameter if present.
 // Returns the cache duration and whether the parameter was found.
 func (kvpl KVPList) GetMaxCacheDuration() (time.Duration, bool) {
@@ -153,6 +181,60 @@ type SubscribeOptions struct {
 
 	// Parameters contains key-value parameters for the subscription
 	Parameters KVPList
+
+	// BufferCount is the maximum number of received objects buffered by the
+	// RemoteTrack. Zero or less means no limit.
+	BufferCount int
+
+	// BufferBytes is the maximum total payload size of received objects
+	// buffered by the RemoteTrack. Zero or less means no limit.
+	BufferBytes int
+
+	// OverflowPolicy determines how the RemoteTrack handles objects that
+	// don't fit into the buffer.
+	OverflowPolicy OverflowPolicy
+
+	// SubgroupReaders indicates whether objects received on subgroup streams
+	// are returned per subgroup by RemoteTrack.AcceptSubgroup instead of
+	// RemoteTrack.ReadObject.
+	SubgroupReaders bool
+
+	// PayloadStreamingThreshold is the payload size in bytes above which
+	// payloads of objects received on subgroup streams are streamed instead
+	// of buffered. Zero disables streaming.
+	PayloadStreamingThreshold uint64
+
+	// ReorderWindow and ReorderWindowObjects limit the time and the number of
+	// objects for which objects are held to deliver them in group order. If
+	// both are zero, objects are delivered in arrival order.
+	ReorderWindow        time.Duration
+	ReorderWindowObjects int
+
+	// GapHandler is called for each gap detected on the subscription.
+	GapHandler func(Gap)
+}
+
+// FetchOptions contains options for fetching a range of objects of a track.
+type FetchOptions struct {
+	// SubscriberPriority indicates the delivery priority (0-255, lower is more important)
+	SubscriberPriority uint8
+
+	// GroupOrder indicates the requested group order:
+	// 0 = None (publisher's order), 1 = Ascending, 2 = Descending
+	GroupOrder GroupOrder
+
+	// StartLocation specifies the location of the first requested object
+	StartLocation Location
+
+	// EndGroup specifies the last requested group
+	EndGroup uint64
+
+	// EndObject specifies the ID of the last requested object in EndGroup
+	// plus one. Zero requests all objects of EndGroup.
+	EndObject uint64
+
+	// Parameters contains key-value parameters for the fetch
+	Parameters KVPList
 }
 
 // SubscribeOkOptions contains options for customizing subscription acceptance responses.
@@ -173,6 +255,22 @@ type SubscribeOkOptions struct {
 	Parameters KVPList
 }
 
+// FetchOkOptions contains options for customizing fetch acceptance responses.
+type FetchOkOptions struct {
+	// GroupOrder specifies the actual group order that will be used
+	GroupOrder GroupOrder
+
+	// EndOfTrack indicates whether the fetched range includes the end of the
+	// track
+	EndOfTrack bool
+
+	// EndLocation specifies the location of the last object of the fetch
+	EndLocation Location
+
+	// Parameters contains response parameters
+	Parameters KVPList
+}
+
 // SubscribeUpdateOptions contains options for updating an existing subscription.
 type SubscribeUpdateOptions struct {
 	// StartLocation specifies the new start position for the subscription
@@ -192,6 +290,66 @@ type SubscribeUpdateOptions struct {
 	Parameters KVPList
 }
 
+// SubgroupOptions contains options for opening subgroups.
+type SubgroupOptions struct {
+	// Extensions indicates whether objects on the subgroup can carry
+	// extension headers
+	Extensions bool
+}
+
+// DatagramOptions contains options for sending objects in datagrams.
+type DatagramOptions struct {
+	// StreamFallback indicates whether objects that are too large for a
+	// datagram are sent on a single-object subgroup stream instead
+	StreamFallback bool
+}
+
+// TrackWriterOptions contains options for writing tracks with a TrackWriter.
+type TrackWriterOptions struct {
+	// Priority is the publisher priority of the subgroups opened by the
+	// TrackWriter
+	Priority uint8
+
+	// GroupDuration is the duration after which a new group is started. Zero
+	// disables starting groups by time.
+	GroupDuration time.Duration
+
+	// GroupSize is the number of payload bytes after which a new group is
+	// started. Zero disables starting groups by size.
+	GroupSize uint64
+
+	// SubscriberQueue is the maximum number of objects queued per subscriber
+	SubscriberQueue int
+
+	// Store, Namespace and Track determine where the objects written by the
+	// TrackWriter are recorded. Objects are not recorded if Store is nil.
+	Store     ObjectStore
+	Namespace []string
+	Track     string
+}
+
+// FileObjectStoreOptions contains options for storing objects with a
+// FileObjectStore.
+type FileObjectStoreOptions struct {
+	// SegmentSize is the number of bytes after which a new segment file is
+	// started for a track
+	SegmentSize uint64
+
+	// MaxAge is the duration after which objects are removed. Zero keeps
+	// objects regardless of their age.
+	MaxAge time.Duration
+
+	// MaxBytes is the number of bytes of segment files kept per track. Zero
+	// keeps segments regardless of their size.
+	MaxBytes uint64
+}
+
+// TrackSubscriberOptions contains options for subscribers of a TrackWriter.
+type TrackSubscriberOptions struct {
+	// DeliveryMode determines how objects are sent to the subscriber
+	DeliveryMode DeliveryMode
+}
+
 // SubscribeMessage represents a SUBSCRIBE message from the peer.
 type SubscribeMessage struct {
 	RequestID  uint64
@@ -212,6 +370,28 @@ type SubscribeMessage struct {
 	Parameters         KVPList    // Full parameter list from the subscribe message
 }
 
+// FetchMessage represents a FETCH message from the peer. The range of
+// joining fetches is resolved from the largest location sent in the
+// SUBSCRIBE_OK of the joined subscription, and Namespace and Track are those
+// of the joined subscription.
+type FetchMessage struct {
+	RequestID uint64
+	Namespace []string
+	Track     string
+
+	// Authorization token should be an object, see 8.2.1.1
+	Authorization string
+
+	// Fetch message specific fields
+	SubscriberPriority uint8      // Delivery priority (0-255, lower is more important)
+	GroupOrder         GroupOrder // Group ordering preference: 0=None, 1=Ascending, 2=Descending
+	StartLocation      Location   // Location of the first requested object
+	EndGroup           uint64     // Last requested group
+	EndObject          uint64     // Last requested object in EndGroup plus one, 0 requests the entire group
+	JoiningRequestID   *uint64    // Request ID of the joined subscription for joining fetches
+	Parameters         KVPList    // Full parameter list from the fetch message
+}
+
 // SubscribeUpdateMessage represents a SUBSCRIBE_UPDATE message from the peer.
 type SubscribeUpdateMessage struct {
 	RequestID uint64
@@ -116,6 +116,42 @@ func (c *MockObjectMessageParserMessagesCall) DoAndReturn(f func() iter.Seq2[*wi
 	return c
 }
 
+// SetPayloadStreamingThreshold mocks base method.
+func (m *MockObjectMessageParser) SetPayloadStreamingThreshold(arg0 uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPayloadStreamingThreshold", arg0)
+}
+
+// SetPayloadStreamingThreshold indicates an expected call of SetPayloadStreamingThreshold.
+func (mr *MockObjectMessageParserMockRecorder) SetPayloadStreamingThreshold(arg0 any) *MockObjectMessageParserSetPayloadStreamingThresholdCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPayloadStreamingThreshold", reflect.TypeOf((*MockObjectMessageParser)(nil).SetPayloadStreamingThreshold), arg0)
+	return &MockObjectMessageParserSetPayloadStreamingThresholdCall{Call: call}
+}
+
+// MockObjectMessageParserSetPayloadStreamingThresholdCall wrap *gomock.Call
+type MockObjectMessageParserSetPayloadStreamingThresholdCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockObjectMessageParserSetPayloadStreamingThresholdCall) Return() *MockObjectMessageParserSetPayloadStreamingThresholdCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockObjectMessageParserSetPayloadStreamingThresholdCall) Do(f func(uint64)) *MockObjectMessageParserSetPayloadStreamingThresholdCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockObjectMessageParserSetPayloadStreamingThresholdCall) DoAndReturn(f func(uint64)) *MockObjectMessageParserSetPayloadStreamingThresholdCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Type mocks base method.
 func (m *MockObjectMessageParser) Type() wire.StreamType {
 	m.ctrl.T.Helper()
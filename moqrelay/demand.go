@@ -0,0 +1,145 @@
+package moqrelay
+
+import (
+	"github.com/mengelbart/moqtransport"
+)
+
+// demand is the part of a subscription that determines which objects of a
+// track are requested and how urgently.
+type demand struct {
+	// priority is the subscriber priority, lower values are more important
+	priority uint8
+
+	forward bool
+
+	// start is nil for subscriptions that start at the latest object or the
+	// next group, which are served from the start of the upstream
+	// subscription.
+	start *moqtransport.Location
+
+	// endGroup is the last requested group plus one or zero if the
+	// subscription is open-ended.
+	endGroup uint64
+}
+
+// subscribeDemand returns the demand of the subscription in m.
+func subscribeDemand(m *moqtransport.SubscribeMessage) demand {
+	d := demand{
+		priority: m.SubscriberPriority,
+		forward:  m.Forward == 1,
+		start:    nil,
+		endGroup: 0,
+	}
+	switch m.FilterType {
+	case moqtransport.FilterTypeAbsoluteStart, moqtransport.FilterTypeAbsoluteRange:
+		if m.StartLocation != nil {
+			start := *m.StartLocation
+			d.start = &start
+		}
+	}
+	if m.FilterType == moqtransport.FilterTypeAbsoluteRange && m.EndGroup != nil {
+		d.endGroup = *m.EndGroup + 1
+	}
+	return d
+}
+
+// update returns d updated by the SUBSCRIBE_UPDATE in m. Like at the
+// publisher, the start can only move forward and the end only backward. A
+// start of group 0, object 0 does not move the start of subscriptions that
+// start at the latest object.
+func (d demand) update(m *moqtransport.SubscribeUpdateMessage) demand {
+	start := m.StartLocation
+	if d.start == nil && start != (moqtransport.Location{Group: 0, Object: 0}) {
+		d.start = &start
+	} else if d.start != nil && before(*d.start, start) {
+		d.start = &start
+	}
+	if m.EndGroup != 0 && (d.endGroup == 0 || m.EndGroup < d.endGroup) {
+		d.endGroup = m.EndGroup
+	}
+	d.priority = m.SubscriberPriority
+	d.forward = m.Forward == 1
+	return d
+}
+
+// includes reports whether the object at location l is requested.
+func (d demand) includes(l moqtransport.Location) bool {
+	if d.start != nil && before(l, *d.start) {
+		return false
+	}
+	return d.endGroup == 0 || l.Group < d.endGroup
+}
+
+// combine returns the demand that covers all demands: the most important
+// priority, the widest range and forward if any demand forwards. The zero
+// demand is returned for no demands.
+func combine(demands []demand) demand {
+	if len(demands) == 0 {
+		return demand{}
+	}
+	c := demands[0]
+	for _, d := range demands[1:] {
+		c.priority = min(c.priority, d.priority)
+		c.forward = c.forward || d.forward
+		if c.start != nil && (d.start == nil || before(*d.start, *c.start)) {
+			c.start = d.start
+		}
+		if c.endGroup != 0 && (d.endGroup == 0 || d.endGroup > c.endGroup) {
+			c.endGroup = d.endGroup
+		}
+	}
+	return c
+}
+
+// narrow returns the demand to request with a SUBSCRIBE_UPDATE of the
+// upstream subscription u for the combined demand c. SUBSCRIBE_UPDATE cannot
+// widen a subscription, so the start only moves forward. The end group of u
+// is kept, because the subscription is shared and narrowing it would stop
+// the groups after the end for subscribers added later.
+func (c demand) narrow(u demand) demand {
+	if c.start == nil || (u.start != nil && before(*c.start, *u.start)) {
+		c.start = u.start
+	}
+	c.endGroup = u.endGroup
+	return c
+}
+
+// covers reports whether the range of d includes the range of e. Objects
+// before next, the location of the next object to forward, are not
+// requested by e, because they were already forwarded. next is nil if no
+// object was forwarded yet.
+func (d demand) covers(e demand, next *moqtransport.Location) bool {
+	start := e.start
+	if next != nil && (start == nil || before(*start, *next)) {
+		start = next
+	}
+	if d.start != nil && (start == nil || before(*start, *d.start)) {
+		return false
+	}
+	return d.endGroup == 0 || (e.endGroup != 0 && e.endGroup <= d.endGroup)
+}
+
+// equal reports whether d and e are equal.
+func (d demand) equal(e demand) bool {
+	if (d.start == nil) != (e.start == nil) {
+		return false
+	}
+	if d.start != nil && *d.start != *e.start {
+		return false
+	}
+	return d.priority == e.priority && d.forward == e.forward && d.endGroup == e.endGroup
+}
+
+// updateOptions returns the options of a SUBSCRIBE_UPDATE requesting d.
+func (d demand) updateOptions() []moqtransport.SubscribeUpdateOption {
+	start := moqtransport.Location{Group: 0, Object: 0}
+	if d.start != nil {
+		start = *d.start
+	}
+	return []moqtransport.SubscribeUpdateOption{
+		moqtransport.WithUpdateStartLocation(start),
+		moqtransport.WithUpdateEndGroup(d.endGroup),
+		moqtransport.WithUpdateSubscriberPriority(d.priority),
+		moqtransport.WithUpdateForward(d.forward),
+	}
+}
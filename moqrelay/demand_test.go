@@ -0,0 +1,289 @@
+package moqrelay
+
+import (
+	"testing"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/stretchr/testify/assert"
+)
+
+func location(group, object uint64) *moqtransport.Location {
+	return &moqtransport.Location{Group: group, Object: object}
+}
+
+func TestCombine(t *testing.T) {
+	cases := []struct {
+		name     string
+		demands  []demand
+		expected demand
+	}{
+		{
+			name:     "none",
+			demands:  []demand{},
+			expected: demand{priority: 0, forward: false, start: nil, endGroup: 0},
+		},
+		{
+			name: "single",
+			demands: []demand{
+				{priority: 10, forward: true, start: location(2, 1), endGroup: 5},
+			},
+			expected: demand{priority: 10, forward: true, start: location(2, 1), endGroup: 5},
+		},
+		{
+			name: "most_important_priority_and_any_forward",
+			demands: []demand{
+				{priority: 200, forward: false, start: nil, endGroup: 0},
+				{priority: 10, forward: true, start: nil, endGroup: 0},
+				{priority: 100, forward: false, start: nil, endGroup: 0},
+			},
+			expected: demand{priority: 10, forward: true, start: nil, endGroup: 0},
+		},
+		{
+			name: "earliest_start_and_latest_end",
+			demands: []demand{
+				{priority: 0, forward: true, start: location(5, 0), endGroup: 8},
+				{priority: 0, forward: true, start: location(3, 2), endGroup: 6},
+				{priority: 0, forward: true, start: location(3, 4), endGroup: 10},
+			},
+			expected: demand{priority: 0, forward: true, start: location(3, 2), endGroup: 10},
+		},
+		{
+			name: "latest_object_start",
+			demands: []demand{
+				{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+				{priority: 0, forward: true, start: nil, endGroup: 0},
+			},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+		},
+		{
+			name: "open_end",
+			demands: []demand{
+				{priority: 0, forward: true, start: nil, endGroup: 4},
+				{priority: 0, forward: true, start: nil, endGroup: 0},
+				{priority: 0, forward: true, start: nil, endGroup: 9},
+			},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, combine(tc.demands))
+		})
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	cases := []struct {
+		name     string
+		combined demand
+		upstream demand
+		expected demand
+	}{
+		{
+			name:     "priority_and_forward_of_combined",
+			combined: demand{priority: 10, forward: true, start: nil, endGroup: 0},
+			upstream: demand{priority: 200, forward: false, start: nil, endGroup: 0},
+			expected: demand{priority: 10, forward: true, start: nil, endGroup: 0},
+		},
+		{
+			name:     "start_moves_forward",
+			combined: demand{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+			upstream: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			expected: demand{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+		},
+		{
+			name:     "start_does_not_move_backward",
+			combined: demand{priority: 0, forward: true, start: location(1, 0), endGroup: 0},
+			upstream: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			expected: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+		},
+		{
+			name:     "latest_object_start_keeps_start",
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			upstream: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			expected: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+		},
+		{
+			name:     "open_end_is_not_narrowed",
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+		},
+		{
+			name:     "end_is_not_narrowed",
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.combined.narrow(tc.upstream))
+		})
+	}
+}
+
+func TestCovers(t *testing.T) {
+	cases := []struct {
+		name     string
+		upstream demand
+		combined demand
+		next     *moqtransport.Location
+		expected bool
+	}{
+		{
+			name:     "latest_object",
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			next:     nil,
+			expected: true,
+		},
+		{
+			name:     "start_after_upstream_start",
+			upstream: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			combined: demand{priority: 0, forward: true, start: location(3, 0), endGroup: 0},
+			next:     nil,
+			expected: true,
+		},
+		{
+			name:     "start_before_upstream_start",
+			upstream: demand{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+			combined: demand{priority: 0, forward: true, start: location(3, 0), endGroup: 0},
+			next:     nil,
+			expected: false,
+		},
+		{
+			name:     "latest_object_before_upstream_start",
+			upstream: demand{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			next:     location(3, 0),
+			expected: false,
+		},
+		{
+			name:     "forwarded_objects_are_not_requested",
+			upstream: demand{priority: 0, forward: true, start: location(5, 0), endGroup: 0},
+			combined: demand{priority: 0, forward: true, start: location(1, 0), endGroup: 0},
+			next:     location(6, 2),
+			expected: true,
+		},
+		{
+			name:     "end_before_upstream_end",
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+			next:     nil,
+			expected: true,
+		},
+		{
+			name:     "end_after_upstream_end",
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 9},
+			next:     nil,
+			expected: false,
+		},
+		{
+			name:     "open_end_after_upstream_end",
+			upstream: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+			combined: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			next:     nil,
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.upstream.covers(tc.combined, tc.next))
+		})
+	}
+}
+
+func TestDemandUpdate(t *testing.T) {
+	cases := []struct {
+		name     string
+		demand   demand
+		update   moqtransport.SubscribeUpdateMessage
+		expected demand
+	}{
+		{
+			name:   "priority_and_forward",
+			demand: demand{priority: 10, forward: true, start: nil, endGroup: 0},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 0, Object: 0},
+				EndGroup:           0,
+				SubscriberPriority: 200,
+				Forward:            0,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 200, forward: false, start: nil, endGroup: 0},
+		},
+		{
+			name:   "start_moves_forward",
+			demand: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 4, Object: 1},
+				EndGroup:           0,
+				SubscriberPriority: 0,
+				Forward:            1,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 0, forward: true, start: location(4, 1), endGroup: 0},
+		},
+		{
+			name:   "start_does_not_move_backward",
+			demand: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 1, Object: 0},
+				EndGroup:           0,
+				SubscriberPriority: 0,
+				Forward:            1,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 0, forward: true, start: location(2, 0), endGroup: 0},
+		},
+		{
+			name:   "latest_object_start",
+			demand: demand{priority: 0, forward: true, start: nil, endGroup: 0},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 3, Object: 0},
+				EndGroup:           0,
+				SubscriberPriority: 0,
+				Forward:            1,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 0, forward: true, start: location(3, 0), endGroup: 0},
+		},
+		{
+			name:   "end_moves_backward",
+			demand: demand{priority: 0, forward: true, start: nil, endGroup: 8},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 0, Object: 0},
+				EndGroup:           5,
+				SubscriberPriority: 0,
+				Forward:            1,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+		},
+		{
+			name:   "end_does_not_move_forward",
+			demand: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+			update: moqtransport.SubscribeUpdateMessage{
+				RequestID:          0,
+				StartLocation:      moqtransport.Location{Group: 0, Object: 0},
+				EndGroup:           8,
+				SubscriberPriority: 0,
+				Forward:            1,
+				Parameters:         moqtransport.KVPList{},
+			},
+			expected: demand{priority: 0, forward: true, start: nil, endGroup: 5},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.demand.update(&tc.update))
+		})
+	}
+}
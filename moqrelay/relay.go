@@ -0,0 +1,661 @@
+// Package moqrelay implements a MoQ relay that forwards tracks from publisher
+// sessions to subscriber sessions.
+//
+// Publishers announce namespaces to the relay with ANNOUNCE, origins can also
+// be configured statically. Subscribers subscribe to and fetch tracks in
+// routed namespaces. The relay keeps one upstream subscription per track and
+// fans its objects out to all downstream subscribers. If the upstream session
+// fails, the relay subscribes to the track at the next origin. Subscribers
+// that sent SUBSCRIBE_ANNOUNCES receive the announcements of namespaces
+// matching their prefix.
+package moqrelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// defaultSubscriberQueue is the default maximum number of objects queued per
+// downstream subscriber.
+const defaultSubscriberQueue = 256
+
+// upstreamTimeout is the time to wait for responses of publishers to requests
+// forwarded by the relay.
+const upstreamTimeout = 10 * time.Second
+
+var (
+	errRelayClosed      = errors.New("relay closed")
+	errUnknownNamespace = errors.New("no publisher announced the namespace")
+	errOwnTrack         = errors.New("session publishes the track itself")
+)
+
+// Option is a functional option for configuring a Relay.
+type Option func(*Options)
+
+// Options contains options for relaying tracks with a Relay.
+type Options struct {
+	// InitialMaxRequestID is the initial MAX_REQUEST_ID of the sessions of
+	// the relay
+	InitialMaxRequestID uint64
+
+	// SubscriberQueue is the maximum number of objects queued per downstream
+	// subscriber
+	SubscriberQueue int
+
+	// Logger logs the events of the relay
+	Logger *slog.Logger
+}
+
+// WithInitialMaxRequestID sets the initial MAX_REQUEST_ID of the sessions of
+// the relay. Default is 100.
+func WithInitialMaxRequestID(id uint64) Option {
+	return func(opts *Options) {
+		opts.InitialMaxRequestID = id
+	}
+}
+
+// WithSubscriberQueue sets the maximum number of objects queued for a
+// downstream subscriber that cannot keep up with the track. Objects received
+// while the queue is full are not sent to the subscriber. Default is 256.
+func WithSubscriberQueue(n int) Option {
+	return func(opts *Options) {
+		opts.SubscriberQueue = n
+	}
+}
+
+// WithLogger sets the logger of the relay. Default is to not log.
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+// A Relay forwards announcements, subscriptions and fetches between the
+// sessions added with Run. Objects are forwarded with their original group
+// ID, subgroup ID, object ID, publisher priority, status and extension
+// headers. Objects received on the same subgroup stream from the publisher are
+// sent on one subgroup stream to each subscriber, objects received in
+// datagrams are sent in datagrams. SUBSCRIBE_DONE of an upstream subscription
+// is forwarded to all downstream subscribers of the track.
+//
+// Subscribers receive the objects received by the relay after they
+// subscribed that match the filter of their subscription, while their
+// forward state is set. The upstream subscription of a track requests the
+// combined demand of its subscribers: the most important subscriber
+// priority, the widest range and forward if any subscriber forwards. It is
+// updated with SUBSCRIBE_UPDATE when subscribers are added, removed or
+// updated. The end group of the upstream subscription is never narrowed,
+// since SUBSCRIBE_UPDATE cannot widen it again for later subscribers. If the
+// combined demand is wider than the upstream subscription, the track is
+// subscribed again and the previous upstream subscription is closed.
+//
+// The upstream session of a track is chosen from the routing table by the
+// longest namespace prefix, see Routes. If the upstream session of a
+// subscription fails, the relay subscribes to the track at the next upstream
+// session from the object after the last object it forwarded. Downstream
+// subscriptions only end with the SUBSCRIBE_DONE of the upstream
+// subscription, or when no upstream session is left.
+//
+// The upstream subscription of a track is closed when its last
+// subscriber is removed. A subscriber is removed when it unsubscribes, when
+// its session closes, or when sending to it fails. A Relay is safe for
+// concurrent use.
+type Relay struct {
+	logger              *slog.Logger
+	initialMaxRequestID uint64
+	queueSize           int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock     sync.Mutex
+	closed   bool
+	sessions map[*moqtransport.Session]*peer
+
+	// announcements holds the announced namespaces by key.
+	announcements map[string]*announcement
+
+	// origins holds the static origins added with AddOrigin.
+	origins []Route
+
+	// tracks holds the tracks with an upstream subscription by key.
+	tracks map[string]*track
+}
+
+// peer holds the state of a session of the relay.
+type peer struct {
+	// prefixes holds the namespace prefixes of the SUBSCRIBE_ANNOUNCES
+	// received from the session.
+	prefixes [][]string
+}
+
+type announcement struct {
+	namespace []string
+
+	// publishers holds the sessions that announced the namespace in the
+	// order of their announcements.
+	publishers []*moqtransport.Session
+
+	// forwarded holds the sessions the announcement was forwarded to.
+	forwarded map[*moqtransport.Session]struct{}
+}
+
+// New creates a new Relay.
+//
+// Default behavior when no options are provided:
+//   - InitialMaxRequestID: 100
+//   - SubscriberQueue: 256
+//   - Logger: nil (nothing is logged)
+func New(options ...Option) *Relay {
+	opts := &Options{
+		InitialMaxRequestID: 100,
+		SubscriberQueue:     defaultSubscriberQueue,
+		Logger:              nil,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		logger:              logger,
+		initialMaxRequestID: opts.InitialMaxRequestID,
+		queueSize:           opts.SubscriberQueue,
+		ctx:                 ctx,
+		cancel:              cancel,
+		lock:                sync.Mutex{},
+		closed:              false,
+		sessions:            map[*moqtransport.Session]*peer{},
+		announcements:       map[string]*announcement{},
+		origins:             []Route{},
+		tracks:              map[string]*track{},
+	}
+}
+
+// Run runs a session on conn whose messages are handled by the relay and
+// returns the session after the handshake completed. The session can
+// publish and subscribe to tracks. Remove the session with RemoveSession when
+// the connection is closed.
+func (r *Relay) Run(conn moqtransport.Connection) (*moqtransport.Session, error) {
+	s := &moqtransport.Session{
+		InitialMaxRequestID: r.initialMaxRequestID,
+	}
+	h := &sessionHandler{
+		relay:   r,
+		session: s,
+	}
+	s.Handler = h
+	s.SubscribeHandler = h
+	s.SubscribeUpdateHandler = h
+	s.FetchHandler = h
+
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return nil, errRelayClosed
+	}
+	r.sessions[s] = &peer{
+		prefixes: [][]string{},
+	}
+	r.lock.Unlock()
+
+	if err := s.Run(conn); err != nil {
+		r.lock.Lock()
+		delete(r.sessions, s)
+		r.lock.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// RemoveSession closes s and removes its announcements, static origins and
+// subscriptions. Upstream subscriptions to s fail over to other origins.
+func (r *Relay) RemoveSession(s *moqtransport.Session) error {
+	r.lock.Lock()
+	if _, ok := r.sessions[s]; !ok {
+		r.lock.Unlock()
+		return nil
+	}
+	delete(r.sessions, s)
+	unannounce := map[*moqtransport.Session][][]string{}
+	r.origins = slices.DeleteFunc(r.origins, func(o Route) bool {
+		return o.Session == s
+	})
+	for key, a := range r.announcements {
+		delete(a.forwarded, s)
+		a.publishers = slices.DeleteFunc(a.publishers, func(p *moqtransport.Session) bool {
+			return p == s
+		})
+		if len(a.publishers) > 0 {
+			continue
+		}
+		delete(r.announcements, key)
+		for target := range a.forwarded {
+			unannounce[target] = append(unannounce[target], a.namespace)
+		}
+	}
+	tracks := make([]*track, 0, len(r.tracks))
+	for _, t := range r.tracks {
+		tracks = append(tracks, t)
+	}
+	r.lock.Unlock()
+
+	for target, namespaces := range unannounce {
+		for _, namespace := range namespaces {
+			r.unannounce(target, namespace)
+		}
+	}
+	for _, t := range tracks {
+		t.removeSession(s)
+	}
+	return s.Close()
+}
+
+// Close closes all sessions of the relay.
+func (r *Relay) Close() error {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return errRelayClosed
+	}
+	r.closed = true
+	sessions := make([]*moqtransport.Session, 0, len(r.sessions))
+	for s := range r.sessions {
+		sessions = append(sessions, s)
+	}
+	r.lock.Unlock()
+
+	r.cancel()
+	var errs []error
+	for _, s := range sessions {
+		if err := r.RemoveSession(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// announce adds the announcement of namespace by publisher and forwards it to
+// the sessions that subscribed to matching announcements. A namespace
+// announced by several publishers is forwarded once.
+func (r *Relay) announce(publisher *moqtransport.Session, w moqtransport.ResponseWriter, namespace []string) {
+	key := namespaceKey(namespace)
+	r.lock.Lock()
+	if a, ok := r.announcements[key]; ok {
+		if !slices.Contains(a.publishers, publisher) {
+			a.publishers = append(a.publishers, publisher)
+		}
+		r.lock.Unlock()
+		r.respond(w.Accept())
+		return
+	}
+	a := &announcement{
+		namespace:  namespace,
+		publishers: []*moqtransport.Session{publisher},
+		forwarded:  map[*moqtransport.Session]struct{}{},
+	}
+	r.announcements[key] = a
+	targets := []*moqtransport.Session{}
+	for s, p := range r.sessions {
+		if s != publisher && p.matches(namespace) {
+			a.forwarded[s] = struct{}{}
+			targets = append(targets, s)
+		}
+	}
+	r.lock.Unlock()
+
+	r.respond(w.Accept())
+	for _, target := range targets {
+		go r.forwardAnnouncement(target, namespace)
+	}
+}
+
+// unannounceFrom removes the announcement of namespace by publisher. After
+// the last publisher of the namespace was removed, UNANNOUNCE is forwarded to
+// the sessions the announcement was forwarded to.
+func (r *Relay) unannounceFrom(publisher *moqtransport.Session, namespace []string) {
+	key := namespaceKey(namespace)
+	r.lock.Lock()
+	a, ok := r.announcements[key]
+	if !ok || !slices.Contains(a.publishers, publisher) {
+		r.lock.Unlock()
+		return
+	}
+	a.publishers = slices.DeleteFunc(a.publishers, func(p *moqtransport.Session) bool {
+		return p == publisher
+	})
+	if len(a.publishers) > 0 {
+		r.lock.Unlock()
+		return
+	}
+	delete(r.announcements, key)
+	r.lock.Unlock()
+
+	for target := range a.forwarded {
+		r.unannounce(target, namespace)
+	}
+}
+
+// announceCancelled stops forwarding the announcement of namespace to s after
+// s cancelled it.
+func (r *Relay) announceCancelled(s *moqtransport.Session, namespace []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if a, ok := r.announcements[namespaceKey(namespace)]; ok {
+		delete(a.forwarded, s)
+	}
+}
+
+// subscribeAnnouncements adds the announcement subscription of s for prefix
+// and forwards the matching announcements to s.
+func (r *Relay) subscribeAnnouncements(s *moqtransport.Session, w moqtransport.ResponseWriter, prefix []string) {
+	r.lock.Lock()
+	p, ok := r.sessions[s]
+	if !ok {
+		r.lock.Unlock()
+		r.respond(w.Reject(moqtransport.ErrorCodeSubscribeAnnouncesInternal, "unknown session"))
+		return
+	}
+	if slices.ContainsFunc(p.prefixes, func(x []string) bool { return slices.Equal(x, prefix) }) {
+		r.lock.Unlock()
+		r.respond(w.Reject(moqtransport.ErrorCodeSubscribeAnnouncesNamespacePrefixOverlap, "duplicate prefix"))
+		return
+	}
+	p.prefixes = append(p.prefixes, prefix)
+	namespaces := [][]string{}
+	for _, a := range r.announcements {
+		if _, ok := a.forwarded[s]; ok || slices.Contains(a.publishers, s) || !hasPrefix(a.namespace, prefix) {
+			continue
+		}
+		a.forwarded[s] = struct{}{}
+		namespaces = append(namespaces, a.namespace)
+	}
+	r.lock.Unlock()
+
+	r.respond(w.Accept())
+	for _, namespace := range namespaces {
+		go r.forwardAnnouncement(s, namespace)
+	}
+}
+
+// unsubscribeAnnouncements removes the announcement subscription of s for
+// prefix. Announcements already forwarded to s are not withdrawn.
+func (r *Relay) unsubscribeAnnouncements(s *moqtransport.Session, prefix []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if p, ok := r.sessions[s]; ok {
+		p.prefixes = slices.DeleteFunc(p.prefixes, func(x []string) bool {
+			return slices.Equal(x, prefix)
+		})
+	}
+}
+
+// forwardAnnouncement announces namespace to target. The announcement is no
+// longer considered forwarded if target rejects it.
+func (r *Relay) forwardAnnouncement(target *moqtransport.Session, namespace []string) {
+	ctx, cancel := context.WithTimeout(r.ctx, upstreamTimeout)
+	defer cancel()
+	if err := target.Announce(ctx, namespace); err != nil {
+		r.logger.Debug("failed to forward announcement", "namespace", namespace, "error", err)
+		r.announceCancelled(target, namespace)
+	}
+}
+
+func (r *Relay) unannounce(target *moqtransport.Session, namespace []string) {
+	if err := target.Unannounce(r.ctx, namespace); err != nil {
+		r.logger.Debug("failed to forward unannouncement", "namespace", namespace, "error", err)
+	}
+}
+
+// track returns the track with the upstream subscription of track in
+// namespace and subscribes to the track with demand d if there is no upstream
+// subscription yet.
+func (r *Relay) track(namespace []string, name string, d demand) (*track, error) {
+	key := trackKey(namespace, name)
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return nil, errRelayClosed
+	}
+	t, ok := r.tracks[key]
+	if !ok {
+		if len(r.upstreams(namespace)) == 0 {
+			r.lock.Unlock()
+			return nil, errUnknownNamespace
+		}
+		t = newTrack(r, key, namespace, name)
+		r.tracks[key] = t
+		r.lock.Unlock()
+		if err := t.subscribe(d); err != nil {
+			r.removeTrack(t)
+		}
+	} else {
+		r.lock.Unlock()
+	}
+	<-t.ready
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t, nil
+}
+
+// removeTrack removes t, so that the next subscriber of the track creates a
+// new upstream subscription.
+func (r *Relay) removeTrack(t *track) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.tracks[t.key] == t {
+		delete(r.tracks, t.key)
+	}
+}
+
+// subscribe subscribes w to the track in m.
+func (r *Relay) subscribe(s *moqtransport.Session, w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+	if r.publisherIs(s, m.Namespace) {
+		r.respond(w.Reject(moqtransport.ErrorCodeSubscribeTrackDoesNotExist, errOwnTrack.Error()))
+		return
+	}
+	d := subscribeDemand(m)
+	t, err := r.track(m.Namespace, m.Track, d)
+	if err != nil {
+		code := moqtransport.ErrorCodeSubscribeInternal
+		var protocolErr moqtransport.ProtocolError
+		if errors.As(err, &protocolErr) {
+			code = protocolErr.Code()
+		} else if errors.Is(err, errUnknownNamespace) || errors.Is(err, errOwnTrack) {
+			code = moqtransport.ErrorCodeSubscribeTrackDoesNotExist
+		}
+		r.respond(w.Reject(code, err.Error()))
+		return
+	}
+	r.respond(t.addSubscriber(s, w, m.RequestID, d))
+}
+
+// subscribeUpdate applies the SUBSCRIBE_UPDATE in m received from s to the
+// subscription it updates.
+func (r *Relay) subscribeUpdate(s *moqtransport.Session, m *moqtransport.SubscribeUpdateMessage) {
+	r.lock.Lock()
+	tracks := make([]*track, 0, len(r.tracks))
+	for _, t := range r.tracks {
+		tracks = append(tracks, t)
+	}
+	r.lock.Unlock()
+	for _, t := range tracks {
+		if t.updateSubscriber(s, m) {
+			return
+		}
+	}
+}
+
+// publisherIs reports whether s is the preferred upstream session of the
+// tracks in namespace.
+func (r *Relay) publisherIs(s *moqtransport.Session, namespace []string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	upstreams := r.upstreams(namespace)
+	return len(upstreams) > 0 && upstreams[0] == s
+}
+
+// fetch forwards the fetch in m to the upstream sessions of the track until
+// one of them accepts it and sends the fetched objects to w.
+func (r *Relay) fetch(s *moqtransport.Session, w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+	r.lock.Lock()
+	upstreams := slices.DeleteFunc(r.upstreams(m.Namespace), func(u *moqtransport.Session) bool {
+		return u == s
+	})
+	r.lock.Unlock()
+	if len(upstreams) == 0 {
+		r.respond(w.Reject(moqtransport.ErrorCodeFetchTrackDoesNotExist, errUnknownNamespace.Error()))
+		return
+	}
+	options := []moqtransport.FetchOption{
+		moqtransport.WithFetchSubscriberPriority(m.SubscriberPriority),
+		moqtransport.WithFetchGroupOrder(m.GroupOrder),
+		moqtransport.WithFetchRange(m.StartLocation, m.EndGroup, m.EndObject),
+	}
+	if m.Authorization != "" {
+		options = append(options, moqtransport.WithFetchAuthorizationToken(m.Authorization))
+	}
+	var upstream *moqtransport.RemoteTrack
+	var err error
+	for _, publisher := range upstreams {
+		ctx, cancel := context.WithTimeout(r.ctx, upstreamTimeout)
+		upstream, err = publisher.Fetch(ctx, m.Namespace, m.Track, options...)
+		cancel()
+		if err == nil {
+			break
+		}
+		r.logger.Debug("upstream fetch failed", "namespace", m.Namespace, "track", m.Track, "error", err)
+	}
+	if err != nil {
+		code := moqtransport.ErrorCodeFetchInternal
+		var protocolErr moqtransport.ProtocolError
+		if errors.As(err, &protocolErr) {
+			code = protocolErr.Code()
+		}
+		r.respond(w.Reject(code, err.Error()))
+		return
+	}
+	okOptions := []moqtransport.FetchOKOption{
+		moqtransport.WithFetchOKGroupOrder(upstream.GroupOrder()),
+		moqtransport.WithEndOfTrack(upstream.EndOfTrack()),
+	}
+	if end, ok := upstream.EndLocation(); ok {
+		okOptions = append(okOptions, moqtransport.WithEndLocation(end))
+	}
+	if err := w.Accept(okOptions...); err != nil {
+		r.logger.Debug("failed to accept fetch", "request_id", m.RequestID, "error", err)
+		_ = upstream.Close()
+		return
+	}
+	go r.forwardFetch(w, upstream)
+}
+
+// forwardFetch writes the objects of upstream to the fetch stream of w. The
+// fetch stream is reset if reading upstream fails.
+func (r *Relay) forwardFetch(w *moqtransport.FetchResponseWriter, upstream *moqtransport.RemoteTrack) {
+	fs, err := w.FetchStream()
+	if err != nil {
+		r.logger.Debug("failed to open fetch stream", "error", err)
+		_ = upstream.Close()
+		return
+	}
+	for o, err := range upstream.Objects(r.ctx) {
+		if err != nil {
+			r.logger.Debug("failed to read upstream fetch", "error", err)
+			_ = upstream.Close()
+			fs.Reset(moqtransport.ErrorCodeStreamInternal)
+			return
+		}
+		if o.Status == moqtransport.ObjectStatusNormal {
+			_, err = fs.WriteObjectWithExtensions(o.GroupID, o.SubGroupID, o.ObjectID, o.PublisherPriority, o.ExtensionHeaders, o.Payload)
+		} else {
+			err = fs.WriteStatus(o.GroupID, o.SubGroupID, o.ObjectID, o.PublisherPriority, o.Status)
+		}
+		if err != nil {
+			r.logger.Debug("failed to write fetch object", "error", err)
+			_ = upstream.Close()
+			return
+		}
+	}
+	if err := fs.Close(); err != nil {
+		r.logger.Debug("failed to close fetch stream", "error", err)
+	}
+}
+
+func (r *Relay) respond(err error) {
+	if err != nil {
+		r.logger.Debug("failed to respond", "error", err)
+	}
+}
+
+// matches reports whether namespace matches a prefix of the announcement
+// subscriptions of p.
+func (p *peer) matches(namespace []string) bool {
+	return slices.ContainsFunc(p.prefixes, func(prefix []string) bool {
+		return hasPrefix(namespace, prefix)
+	})
+}
+
+// sessionHandler handles the messages of a session of the relay.
+type sessionHandler struct {
+	relay   *Relay
+	session *moqtransport.Session
+}
+
+// Handle implements moqtransport.Handler.
+func (h *sessionHandler) Handle(w moqtransport.ResponseWriter, m *moqtransport.Message) {
+	switch m.Method {
+	case moqtransport.MessageAnnounce:
+		h.relay.announce(h.session, w, m.Namespace)
+	case moqtransport.MessageUnannounce:
+		h.relay.unannounceFrom(h.session, m.Namespace)
+	case moqtransport.MessageAnnounceCancel:
+		h.relay.announceCancelled(h.session, m.Namespace)
+	case moqtransport.MessageSubscribeAnnounces:
+		h.relay.subscribeAnnouncements(h.session, w, m.Namespace)
+	case moqtransport.MessageUnsubscribeAnnounces:
+		h.relay.unsubscribeAnnouncements(h.session, m.Namespace)
+	case moqtransport.MessageTrackStatusRequest:
+		h.relay.respond(w.Reject(0, "track status not supported by relay"))
+	}
+}
+
+// HandleSubscribe implements moqtransport.SubscribeHandler.
+func (h *sessionHandler) HandleSubscribe(w *moqtransport.SubscribeResponseWriter, m *moqtransport.SubscribeMessage) {
+	h.relay.subscribe(h.session, w, m)
+}
+
+// HandleSubscribeUpdate implements moqtransport.SubscribeUpdateHandler.
+func (h *sessionHandler) HandleSubscribeUpdate(m *moqtransport.SubscribeUpdateMessage) {
+	h.relay.subscribeUpdate(h.session, m)
+}
+
+// HandleFetch implements moqtransport.FetchHandler.
+func (h *sessionHandler) HandleFetch(w *moqtransport.FetchResponseWriter, m *moqtransport.FetchMessage) {
+	h.relay.fetch(h.session, w, m)
+}
+
+// hasPrefix reports whether prefix is a prefix of namespace.
+func hasPrefix(namespace, prefix []string) bool {
+	return len(prefix) <= len(namespace) && slices.Equal(namespace[:len(prefix)], prefix)
+}
+
+func namespaceKey(namespace []string) string {
+	return fmt.Sprintf("%q", namespace)
+}
+
+func trackKey(namespace []string, track string) string {
+	return fmt.Sprintf("%q", append(slices.Clone(namespace), track))
+}
@@ -0,0 +1,126 @@
+package moqrelay
+
+import (
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+var errUnknownSession = errors.New("unknown session")
+
+// A Route maps a namespace prefix to an upstream session that can serve the
+// tracks of namespaces with the prefix.
+type Route struct {
+	// Namespace is the namespace prefix of the route
+	Namespace []string
+
+	// Session is the upstream session of the route
+	Session *moqtransport.Session
+
+	// Static is true for origins added with AddOrigin and false for
+	// namespaces announced by the session
+	Static bool
+}
+
+// AddOrigin adds s as a static origin for the tracks of namespaces with the
+// prefix namespace. s must be a session of the relay, typically one running
+// on a connection the relay opened to the origin. Static origins are used
+// like sessions that announced the namespace, but announced namespaces are
+// preferred over static origins with a prefix of the same length.
+func (r *Relay) AddOrigin(namespace []string, s *moqtransport.Session) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.sessions[s]; !ok {
+		return errUnknownSession
+	}
+	if slices.ContainsFunc(r.origins, func(o Route) bool {
+		return o.Session == s && slices.Equal(o.Namespace, namespace)
+	}) {
+		return nil
+	}
+	r.origins = append(r.origins, Route{
+		Namespace: slices.Clone(namespace),
+		Session:   s,
+		Static:    true,
+	})
+	return nil
+}
+
+// RemoveOrigin removes the static origin s for namespace. Subscriptions
+// already served by s are not affected.
+func (r *Relay) RemoveOrigin(namespace []string, s *moqtransport.Session) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.origins = slices.DeleteFunc(r.origins, func(o Route) bool {
+		return o.Session == s && slices.Equal(o.Namespace, namespace)
+	})
+}
+
+// Routes returns the routing table of the relay, which contains the
+// namespaces announced by each session and the static origins.
+func (r *Relay) Routes() []Route {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	routes := []Route{}
+	for _, a := range r.announcements {
+		for _, p := range a.publishers {
+			routes = append(routes, Route{
+				Namespace: slices.Clone(a.namespace),
+				Session:   p,
+				Static:    false,
+			})
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return namespaceKey(routes[i].Namespace) < namespaceKey(routes[j].Namespace)
+	})
+	for _, o := range r.origins {
+		routes = append(routes, Route{
+			Namespace: slices.Clone(o.Namespace),
+			Session:   o.Session,
+			Static:    true,
+		})
+	}
+	return routes
+}
+
+// upstreams returns the sessions that can serve the tracks of namespace in
+// the order they should be tried: longer prefixes first and for prefixes of
+// the same length announced namespaces before static origins. Sessions of
+// the same announced namespace are ordered by the time of their
+// announcement. r.lock must be held.
+func (r *Relay) upstreams(namespace []string) []*moqtransport.Session {
+	routes := []Route{}
+	for _, a := range r.announcements {
+		if !hasPrefix(namespace, a.namespace) {
+			continue
+		}
+		for _, p := range a.publishers {
+			routes = append(routes, Route{
+				Namespace: a.namespace,
+				Session:   p,
+				Static:    false,
+			})
+		}
+	}
+	for _, o := range r.origins {
+		if hasPrefix(namespace, o.Namespace) {
+			routes = append(routes, o)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if len(routes[i].Namespace) != len(routes[j].Namespace) {
+			return len(routes[i].Namespace) > len(routes[j].Namespace)
+		}
+		return !routes[i].Static && routes[j].Static
+	})
+	sessions := make([]*moqtransport.Session, 0, len(routes))
+	for _, route := range routes {
+		if !slices.Contains(sessions, route.Session) {
+			sessions = append(sessions, route.Session)
+		}
+	}
+	return sessions
+}
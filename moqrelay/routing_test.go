@@ -0,0 +1,105 @@
+package moqrelay
+
+import (
+	"testing"
+
+	"github.com/mengelbart/moqtransport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreams(t *testing.T) {
+	a := &moqtransport.Session{}
+	b := &moqtransport.Session{}
+	c := &moqtransport.Session{}
+	// Sessions are compared by name, because the zero sessions are deeply
+	// equal.
+	names := map[*moqtransport.Session]string{a: "a", b: "b", c: "c"}
+
+	type announced struct {
+		namespace  []string
+		publishers []*moqtransport.Session
+	}
+	cases := []struct {
+		name      string
+		announced []announced
+		origins   []Route
+		namespace []string
+		expected  []string
+	}{
+		{
+			name:      "no_routes",
+			announced: []announced{},
+			origins:   []Route{},
+			namespace: []string{"a"},
+			expected:  []string{},
+		},
+		{
+			name: "no_matching_prefix",
+			announced: []announced{
+				{namespace: []string{"b"}, publishers: []*moqtransport.Session{a}},
+			},
+			origins:   []Route{{Namespace: []string{"a", "b"}, Session: b, Static: true}},
+			namespace: []string{"a"},
+			expected:  []string{},
+		},
+		{
+			name: "longest_prefix_first",
+			announced: []announced{
+				{namespace: []string{"a"}, publishers: []*moqtransport.Session{a}},
+				{namespace: []string{"a", "b"}, publishers: []*moqtransport.Session{b}},
+			},
+			origins:   []Route{{Namespace: []string{}, Session: c, Static: true}},
+			namespace: []string{"a", "b", "c"},
+			expected:  []string{"b", "a", "c"},
+		},
+		{
+			name: "announced_before_static",
+			announced: []announced{
+				{namespace: []string{"a"}, publishers: []*moqtransport.Session{b}},
+			},
+			origins:   []Route{{Namespace: []string{"a"}, Session: a, Static: true}},
+			namespace: []string{"a"},
+			expected:  []string{"b", "a"},
+		},
+		{
+			name: "publishers_in_announcement_order",
+			announced: []announced{
+				{namespace: []string{"a"}, publishers: []*moqtransport.Session{c, a, b}},
+			},
+			origins:   []Route{},
+			namespace: []string{"a"},
+			expected:  []string{"c", "a", "b"},
+		},
+		{
+			name: "sessions_listed_once",
+			announced: []announced{
+				{namespace: []string{"a", "b"}, publishers: []*moqtransport.Session{a}},
+				{namespace: []string{"a"}, publishers: []*moqtransport.Session{b, a}},
+			},
+			origins:   []Route{{Namespace: []string{"a"}, Session: b, Static: true}},
+			namespace: []string{"a", "b"},
+			expected:  []string{"a", "b"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New()
+			defer r.Close()
+			for _, an := range tc.announced {
+				r.announcements[namespaceKey(an.namespace)] = &announcement{
+					namespace:  an.namespace,
+					publishers: an.publishers,
+					forwarded:  map[*moqtransport.Session]struct{}{},
+				}
+			}
+			r.origins = tc.origins
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			upstreams := []string{}
+			for _, s := range r.upstreams(tc.namespace) {
+				upstreams = append(upstreams, names[s])
+			}
+			assert.Equal(t, tc.expected, upstreams)
+		})
+	}
+}
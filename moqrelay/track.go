@@ -0,0 +1,737 @@
+package moqrelay
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mengelbart/moqtransport"
+)
+
+// track is a track with an upstream subscription whose objects are sent to
+// the downstream subscribers.
+type track struct {
+	relay     *Relay
+	key       string
+	namespace []string
+	name      string
+
+	// ready is closed after the first upstream subscription was accepted or
+	// all upstream sessions rejected it. err is set if it was rejected.
+	ready chan struct{}
+	err   error
+
+	// updateLock serializes the updates of the upstream subscription.
+	updateLock sync.Mutex
+
+	lock     sync.Mutex
+	upstream *upstream
+	done     bool
+
+	// failed holds the upstream sessions that failed while serving the
+	// track.
+	failed map[*moqtransport.Session]bool
+
+	// forwarded is set after the first object was forwarded and then holds
+	// the largest location of the forwarded objects.
+	forwarded *moqtransport.Location
+
+	subscribers map[*moqtransport.SubscribeResponseWriter]*subscriber
+}
+
+// upstream is an upstream subscription of a track.
+type upstream struct {
+	session *moqtransport.Session
+	track   *moqtransport.RemoteTrack
+
+	// resume is set if the subscription replaced a failed subscription.
+	// Objects before resume were already forwarded and are dropped.
+	resume *moqtransport.Location
+
+	// demand is the demand requested from the publisher with the last
+	// SUBSCRIBE or SUBSCRIBE_UPDATE.
+	demand demand
+}
+
+// subgroupKey identifies a subgroup of a track.
+type subgroupKey struct {
+	groupID    uint64
+	subgroupID uint64
+}
+
+// relayItem is an object or the end of a subgroup queued for a downstream
+// subscriber. Items are shared by all subscribers and must not be modified.
+type relayItem struct {
+	subgroup subgroupKey
+	object   *moqtransport.Object // nil at the end of the subgroup
+	datagram bool
+}
+
+func newTrack(r *Relay, key string, namespace []string, name string) *track {
+	return &track{
+		relay:       r,
+		key:         key,
+		namespace:   namespace,
+		name:        name,
+		ready:       make(chan struct{}),
+		err:         nil,
+		updateLock:  sync.Mutex{},
+		lock:        sync.Mutex{},
+		upstream:    nil,
+		done:        false,
+		failed:      map[*moqtransport.Session]bool{},
+		forwarded:   nil,
+		subscribers: map[*moqtransport.SubscribeResponseWriter]*subscriber{},
+	}
+}
+
+// subscribe subscribes to the track with the priority and forward state of d
+// at the first upstream session that accepts the subscription and starts
+// forwarding its objects.
+func (t *track) subscribe(d demand) error {
+	defer close(t.ready)
+	u, err := t.subscribeUpstream(nil, demand{
+		priority: d.priority,
+		forward:  d.forward,
+		start:    nil,
+		endGroup: 0,
+	})
+	if err != nil {
+		t.err = err
+		return err
+	}
+	t.lock.Lock()
+	t.upstream = u
+	t.lock.Unlock()
+	t.forward(u)
+	return nil
+}
+
+// subscribeUpstream subscribes to the track with demand d at the upstream
+// sessions that did not fail yet until one accepts the subscription. If
+// resume is not nil, the subscription starts at resume, otherwise at the
+// latest object.
+func (t *track) subscribeUpstream(resume *moqtransport.Location, d demand) (*upstream, error) {
+	t.relay.lock.Lock()
+	sessions := t.relay.upstreams(t.namespace)
+	t.relay.lock.Unlock()
+
+	options := []moqtransport.SubscribeOption{
+		moqtransport.WithSubgroupReaders(true),
+		moqtransport.WithSubscriberPriority(d.priority),
+		moqtransport.WithForward(d.forward),
+	}
+	d.start = resume
+	if resume == nil {
+		d.endGroup = 0
+	} else if d.endGroup > resume.Group {
+		options = append(options,
+			moqtransport.WithFilterType(moqtransport.FilterTypeAbsoluteRange),
+			moqtransport.WithStartLocation(*resume),
+			moqtransport.WithEndGroup(d.endGroup-1),
+		)
+	} else {
+		d.endGroup = 0
+		options = append(options,
+			moqtransport.WithFilterType(moqtransport.FilterTypeAbsoluteStart),
+			moqtransport.WithStartLocation(*resume),
+		)
+	}
+	err := errUnknownNamespace
+	for _, session := range sessions {
+		t.lock.Lock()
+		failed := t.failed[session]
+		t.lock.Unlock()
+		if failed {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(t.relay.ctx, upstreamTimeout)
+		var rt *moqtransport.RemoteTrack
+		rt, err = session.Subscribe(ctx, t.namespace, t.name, options...)
+		cancel()
+		if err == nil {
+			return &upstream{
+				session: session,
+				track:   rt,
+				resume:  resume,
+				demand:  d,
+			}, nil
+		}
+		t.relay.logger.Debug("upstream subscription failed", "namespace", t.namespace, "track", t.name, "error", err)
+		var protocolErr moqtransport.ProtocolError
+		if !errors.As(err, &protocolErr) {
+			t.lock.Lock()
+			t.failed[session] = true
+			t.lock.Unlock()
+		}
+	}
+	return nil, err
+}
+
+// forward starts forwarding the objects of u.
+func (t *track) forward(u *upstream) {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go t.acceptSubgroups(wg, u)
+	go t.readDatagrams(wg, u)
+}
+
+// acceptSubgroups reads the subgroups of the upstream subscription until it
+// ends.
+func (t *track) acceptSubgroups(wg *sync.WaitGroup, u *upstream) {
+	defer wg.Done()
+	for {
+		sg, err := u.track.AcceptSubgroup(t.relay.ctx)
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go t.readSubgroup(wg, u, sg)
+	}
+}
+
+func (t *track) readSubgroup(wg *sync.WaitGroup, u *upstream, sg *moqtransport.RemoteSubgroup) {
+	defer wg.Done()
+	key := subgroupKey{
+		groupID:    sg.GroupID(),
+		subgroupID: sg.SubgroupID(),
+	}
+	for {
+		o, err := sg.ReadObject(t.relay.ctx)
+		if err != nil {
+			t.dispatch(u, relayItem{
+				subgroup: key,
+				object:   nil,
+				datagram: false,
+			})
+			return
+		}
+		t.dispatch(u, relayItem{
+			subgroup: key,
+			object:   o,
+			datagram: false,
+		})
+	}
+}
+
+// readDatagrams reads the objects received in datagrams until the upstream
+// subscription ends and then, after the subgroups were read, fails over to
+// another upstream session or ends the downstream subscriptions.
+func (t *track) readDatagrams(wg *sync.WaitGroup, u *upstream) {
+	for {
+		o, err := u.track.ReadObject(t.relay.ctx)
+		if err != nil {
+			wg.Wait()
+			t.upstreamDone(u, err)
+			return
+		}
+		t.dispatch(u, relayItem{
+			subgroup: subgroupKey{
+				groupID:    o.GroupID,
+				subgroupID: o.SubGroupID,
+			},
+			object:   o,
+			datagram: true,
+		})
+	}
+}
+
+// upstreamDone handles the end of the upstream subscription u with err. The
+// downstream subscriptions end if u ended with SUBSCRIBE_DONE. Otherwise the
+// session of u failed and the track is subscribed at the next upstream
+// session from the object after the last forwarded object.
+func (t *track) upstreamDone(u *upstream, err error) {
+	var done *moqtransport.ErrSubscribeDone
+	t.lock.Lock()
+	if t.done || t.upstream != u {
+		t.lock.Unlock()
+		return
+	}
+	if errors.As(err, &done) || t.relay.ctx.Err() != nil {
+		t.lock.Unlock()
+		t.finish(err)
+		return
+	}
+	t.failed[u.session] = true
+	resume := t.next()
+	d := u.demand
+	if len(t.subscribers) > 0 {
+		d = t.demand()
+	}
+	t.lock.Unlock()
+
+	t.relay.logger.Info("upstream subscription failed, subscribing at next upstream session", "namespace", t.namespace, "track", t.name, "error", err)
+	next, nextErr := t.subscribeUpstream(resume, d)
+	if nextErr != nil {
+		t.relay.logger.Info("no upstream session left", "namespace", t.namespace, "track", t.name, "error", nextErr)
+		t.finish(err)
+		return
+	}
+	t.lock.Lock()
+	if t.done {
+		t.lock.Unlock()
+		if closeErr := next.track.Close(); closeErr != nil {
+			t.relay.logger.Debug("failed to unsubscribe upstream", "error", closeErr)
+		}
+		return
+	}
+	t.upstream = next
+	t.lock.Unlock()
+	t.forward(next)
+}
+
+// next returns the location of the object after the last forwarded object
+// or nil if no object was forwarded yet. t.lock must be held.
+func (t *track) next() *moqtransport.Location {
+	if t.forwarded == nil {
+		return nil
+	}
+	return &moqtransport.Location{
+		Group:  t.forwarded.Group,
+		Object: t.forwarded.Object + 1,
+	}
+}
+
+// dispatch queues item of u for all subscribers. Objects of an upstream
+// subscription that was replaced are dropped.
+func (t *track) dispatch(u *upstream, item relayItem) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if item.object != nil {
+		if u != t.upstream {
+			return
+		}
+		location := moqtransport.Location{
+			Group:  item.object.GroupID,
+			Object: item.object.ObjectID,
+		}
+		if u.resume != nil && before(location, *u.resume) {
+			return
+		}
+		if t.forwarded == nil || before(*t.forwarded, location) {
+			t.forwarded = &location
+		}
+	}
+	for _, s := range t.subscribers {
+		if item.object != nil && !s.wants(item.object) {
+			continue
+		}
+		s.enqueue(item)
+	}
+}
+
+// before reports whether location a is before location b.
+func before(a, b moqtransport.Location) bool {
+	return a.Group < b.Group || (a.Group == b.Group && a.Object < b.Object)
+}
+
+// finish ends the downstream subscriptions after the upstream subscription
+// ended with err. SUBSCRIBE_DONE is forwarded with its status and reason.
+func (t *track) finish(err error) {
+	t.relay.removeTrack(t)
+	t.lock.Lock()
+	t.done = true
+	subscribers := t.subscribers
+	t.subscribers = map[*moqtransport.SubscribeResponseWriter]*subscriber{}
+	t.lock.Unlock()
+
+	code := uint64(moqtransport.SubscribeStatusInternalError)
+	reason := err.Error()
+	var done *moqtransport.ErrSubscribeDone
+	if errors.As(err, &done) {
+		code = done.Status
+		reason = done.Reason
+	}
+	for _, s := range subscribers {
+		s.end(code, reason)
+	}
+}
+
+// addSubscriber accepts the subscription of w with requestID and demand d and
+// adds w as a subscriber.
+func (t *track) addSubscriber(session *moqtransport.Session, w *moqtransport.SubscribeResponseWriter, requestID uint64, d demand) error {
+	if err := t.acceptSubscriber(session, w, requestID, d); err != nil {
+		return err
+	}
+	t.updateUpstream()
+	return nil
+}
+
+func (t *track) acceptSubscriber(session *moqtransport.Session, w *moqtransport.SubscribeResponseWriter, requestID uint64, d demand) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.done {
+		return w.Reject(moqtransport.ErrorCodeSubscribeTrackDoesNotExist, "track ended")
+	}
+	if t.upstream.session == session {
+		return w.Reject(moqtransport.ErrorCodeSubscribeTrackDoesNotExist, errOwnTrack.Error())
+	}
+	options := []moqtransport.SubscribeOKOption{
+		moqtransport.WithGroupOrder(t.upstream.track.GroupOrder()),
+	}
+	if largest, ok := t.upstream.track.LargestLocation(); ok {
+		options = append(options, moqtransport.WithLargestLocation(&largest))
+	}
+	if err := w.Accept(options...); err != nil {
+		return err
+	}
+	s := newSubscriber(t, session, w, requestID, d)
+	t.subscribers[w] = s
+	go s.watch()
+	return nil
+}
+
+// updateSubscriber applies the SUBSCRIBE_UPDATE in m of session to its
+// subscriber. It returns false if session has no subscriber with the request
+// ID of m.
+func (t *track) updateSubscriber(session *moqtransport.Session, m *moqtransport.SubscribeUpdateMessage) bool {
+	t.lock.Lock()
+	var found *subscriber
+	for _, s := range t.subscribers {
+		if s.session == session && s.requestID == m.RequestID {
+			found = s
+			break
+		}
+	}
+	if found == nil {
+		t.lock.Unlock()
+		return false
+	}
+	found.demand = found.demand.update(m)
+	t.lock.Unlock()
+	t.updateUpstream()
+	return true
+}
+
+// demand returns the combined demand of the subscribers. t.lock must be
+// held.
+func (t *track) demand() demand {
+	demands := make([]demand, 0, len(t.subscribers))
+	for _, s := range t.subscribers {
+		demands = append(demands, s.demand)
+	}
+	return combine(demands)
+}
+
+// updateUpstream sends a SUBSCRIBE_UPDATE if the combined demand of the
+// subscribers differs from the demand of the upstream subscription. If the
+// combined demand is wider than the upstream subscription, the track is
+// subscribed again instead, because SUBSCRIBE_UPDATE cannot widen a
+// subscription.
+func (t *track) updateUpstream() {
+	t.updateLock.Lock()
+	defer t.updateLock.Unlock()
+
+	t.lock.Lock()
+	if t.done || t.upstream == nil || len(t.subscribers) == 0 {
+		t.lock.Unlock()
+		return
+	}
+	u := t.upstream
+	c := t.demand()
+	next := t.next()
+	if !u.demand.covers(c, next) {
+		t.lock.Unlock()
+		t.resubscribe(u, c, next)
+		return
+	}
+	d := c.narrow(u.demand)
+	if d.equal(u.demand) {
+		t.lock.Unlock()
+		return
+	}
+	t.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(t.relay.ctx, upstreamTimeout)
+	defer cancel()
+	if err := u.track.UpdateSubscription(ctx, d.updateOptions()...); err != nil {
+		t.relay.logger.Debug("failed to update upstream subscription", "namespace", t.namespace, "track", t.name, "error", err)
+		return
+	}
+	t.lock.Lock()
+	u.demand = d
+	t.lock.Unlock()
+}
+
+// resubscribe replaces the upstream subscription u by a subscription of the
+// combined demand c starting at the later of the start of c and next, the
+// location of the next object to forward. u is closed after the new
+// subscription was accepted.
+func (t *track) resubscribe(u *upstream, c demand, next *moqtransport.Location) {
+	resume := c.start
+	if next != nil && (resume == nil || before(*resume, *next)) {
+		resume = next
+	}
+	replacement, err := t.subscribeUpstream(resume, c)
+	if err != nil {
+		t.relay.logger.Debug("failed to subscribe upstream with wider demand", "namespace", t.namespace, "track", t.name, "error", err)
+		return
+	}
+	t.lock.Lock()
+	if t.done || t.upstream != u {
+		t.lock.Unlock()
+		if closeErr := replacement.track.Close(); closeErr != nil {
+			t.relay.logger.Debug("failed to unsubscribe upstream", "error", closeErr)
+		}
+		return
+	}
+	// Objects forwarded from u while subscribing are not forwarded again.
+	if next = t.next(); next != nil && (replacement.resume == nil || before(*replacement.resume, *next)) {
+		replacement.resume = next
+	}
+	t.upstream = replacement
+	t.lock.Unlock()
+	t.forward(replacement)
+	if err := u.track.Close(); err != nil {
+		t.relay.logger.Debug("failed to unsubscribe upstream", "error", err)
+	}
+}
+
+// removeSubscriber removes w and discards its queue. The upstream
+// subscription is closed after the last subscriber was removed.
+func (t *track) removeSubscriber(w *moqtransport.SubscribeResponseWriter) {
+	t.lock.Lock()
+	s, ok := t.subscribers[w]
+	if !ok {
+		t.lock.Unlock()
+		return
+	}
+	delete(t.subscribers, w)
+	s.remove()
+	last := len(t.subscribers) == 0 && !t.done
+	if last {
+		t.done = true
+	}
+	u := t.upstream
+	t.lock.Unlock()
+
+	if !last {
+		t.updateUpstream()
+		return
+	}
+	t.relay.removeTrack(t)
+	if err := u.track.Close(); err != nil {
+		t.relay.logger.Debug("failed to unsubscribe upstream", "error", err)
+	}
+}
+
+// removeSession removes the subscribers of session.
+func (t *track) removeSession(session *moqtransport.Session) {
+	t.lock.Lock()
+	writers := []*moqtransport.SubscribeResponseWriter{}
+	for w, s := range t.subscribers {
+		if s.session == session {
+			writers = append(writers, w)
+		}
+	}
+	t.lock.Unlock()
+	for _, w := range writers {
+		t.removeSubscriber(w)
+	}
+}
+
+// subscriber queues the objects of a track for a downstream subscriber and
+// sends them from a goroutine that runs while objects are queued.
+type subscriber struct {
+	track     *track
+	session   *moqtransport.Session
+	publisher *moqtransport.SubscribeResponseWriter
+	requestID uint64
+
+	// demand is protected by the lock of the track.
+	demand demand
+
+	lock    sync.Mutex
+	queue   []relayItem
+	running bool
+	removed bool
+
+	// gone is closed when the subscriber is removed.
+	gone chan struct{}
+
+	// ending is set when the subscription is closed with endCode and
+	// endReason after the queue was sent.
+	ending    bool
+	endCode   uint64
+	endReason string
+
+	// Fields below are only accessed by the goroutine sending the queue.
+	subgroups map[subgroupKey]*moqtransport.Subgroup
+	failed    map[subgroupKey]bool
+}
+
+func newSubscriber(t *track, session *moqtransport.Session, w *moqtransport.SubscribeResponseWriter, requestID uint64, d demand) *subscriber {
+	return &subscriber{
+		track:     t,
+		session:   session,
+		publisher: w,
+		requestID: requestID,
+		demand:    d,
+		lock:      sync.Mutex{},
+		queue:     []relayItem{},
+		running:   false,
+		removed:   false,
+		gone:      make(chan struct{}),
+		ending:    false,
+		endCode:   0,
+		endReason: "",
+		subgroups: map[subgroupKey]*moqtransport.Subgroup{},
+		failed:    map[subgroupKey]bool{},
+	}
+}
+
+// wants reports whether o is requested by the subscriber. The lock of the
+// track must be held.
+func (s *subscriber) wants(o *moqtransport.Object) bool {
+	return s.demand.forward && s.demand.includes(moqtransport.Location{
+		Group:  o.GroupID,
+		Object: o.ObjectID,
+	})
+}
+
+// enqueue queues item. Objects are dropped while the queue is full, the ends
+// of subgroups are always queued.
+func (s *subscriber) enqueue(item relayItem) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.removed || s.ending {
+		return
+	}
+	if item.object != nil && s.track.relay.queueSize > 0 && len(s.queue) >= s.track.relay.queueSize {
+		s.track.relay.logger.Debug("subscriber queue full, dropping object", "group_id", item.object.GroupID, "object_id", item.object.ObjectID)
+		return
+	}
+	s.queue = append(s.queue, item)
+	s.start()
+}
+
+// end closes the subscription with code and reason after the queue was sent.
+func (s *subscriber) end(code uint64, reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.removed || s.ending {
+		return
+	}
+	s.ending = true
+	s.endCode = code
+	s.endReason = reason
+	s.start()
+}
+
+// remove discards the queue without closing the subscription.
+func (s *subscriber) remove() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.removed = true
+	s.queue = nil
+	close(s.gone)
+	s.start()
+}
+
+// watch removes the subscriber after the downstream session sent
+// UNSUBSCRIBE. It returns when the subscription ends or the subscriber is
+// removed.
+func (s *subscriber) watch() {
+	ctx := s.publisher.Context()
+	select {
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), moqtransport.ErrUnsusbcribed) {
+			s.track.removeSubscriber(s.publisher)
+		}
+	case <-s.gone:
+	case <-s.track.relay.ctx.Done():
+	}
+}
+
+// start starts the goroutine sending the queue. s.lock must be held.
+func (s *subscriber) start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	go s.run()
+}
+
+func (s *subscriber) run() {
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			if !s.removed && !s.ending {
+				s.running = false
+				s.lock.Unlock()
+				return
+			}
+			// running stays set, because the subscriber is finished and no
+			// further goroutine must access the subgroups.
+			removed := s.removed
+			code, reason := s.endCode, s.endReason
+			s.lock.Unlock()
+			for key := range s.subgroups {
+				s.closeSubgroup(key)
+			}
+			if !removed {
+				if err := s.publisher.CloseWithError(code, reason); err != nil {
+					s.track.relay.logger.Debug("failed to close subscription", "error", err)
+				}
+			}
+			return
+		}
+		item := s.queue[0]
+		s.queue[0] = relayItem{}
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+		s.send(item)
+	}
+}
+
+func (s *subscriber) send(item relayItem) {
+	if item.object == nil {
+		s.closeSubgroup(item.subgroup)
+		delete(s.failed, item.subgroup)
+		return
+	}
+	o := item.object
+	if item.datagram {
+		if err := s.publisher.SendDatagram(*o, moqtransport.WithDatagramStreamFallback()); err != nil {
+			s.track.relay.logger.Debug("failed to send datagram", "group_id", o.GroupID, "object_id", o.ObjectID, "error", err)
+		}
+		return
+	}
+	if s.failed[item.subgroup] {
+		return
+	}
+	sg, ok := s.subgroups[item.subgroup]
+	if !ok {
+		var err error
+		sg, err = s.publisher.OpenSubgroup(o.GroupID, o.SubGroupID, o.PublisherPriority)
+		if err != nil {
+			s.track.relay.logger.Debug("removing subscriber after failing to open subgroup", "group_id", o.GroupID, "error", err)
+			s.track.removeSubscriber(s.publisher)
+			return
+		}
+		s.subgroups[item.subgroup] = sg
+	}
+	var err error
+	if o.Status == moqtransport.ObjectStatusNormal {
+		_, err = sg.WriteObjectWithExtensions(o.ObjectID, o.ExtensionHeaders, o.Payload)
+	} else {
+		err = sg.WriteStatus(o.ObjectID, o.Status)
+	}
+	if err != nil {
+		// Skip the rest of the subgroup, the subscriber notices the gap.
+		s.track.relay.logger.Debug("skipping rest of subgroup after failed write", "group_id", o.GroupID, "error", err)
+		s.closeSubgroup(item.subgroup)
+		s.failed[item.subgroup] = true
+	}
+}
+
+func (s *subscriber) closeSubgroup(key subgroupKey) {
+	sg, ok := s.subgroups[key]
+	if !ok {
+		return
+	}
+	delete(s.subgroups, key)
+	if err := sg.Close(); err != nil {
+		s.track.relay.logger.Debug("failed to close subgroup", "group_id", key.groupID, "error", err)
+	}
+}
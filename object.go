@@ -1,10 +1,42 @@
 package moqtransport
 
+import (
+	"bytes"
+	"io"
+
+	"github.com/mengelbart/moqtransport/internal/pool"
+	"github.com/mengelbart/moqtransport/internal/wire"
+)
+
 type ObjectForwardingPreference int
 
 const (
 	ObjectForwardingPreferenceSubgroup ObjectForwardingPreference = 0x00
-	ObjectForwardingPreferenceDatagarm
+	ObjectForwardingPreferenceDatagram ObjectForwardingPreference = 0x01
+
+	// Deprecated: Use ObjectForwardingPreferenceDatagram.
+	ObjectForwardingPreferenceDatagarm = ObjectForwardingPreferenceDatagram
+)
+
+// ObjectStatus is the status of an object. Objects with a status other than
+// ObjectStatusNormal don't carry a payload.
+type ObjectStatus = wire.ObjectStatus
+
+const (
+	// ObjectStatusNormal indicates a normal object with a payload.
+	ObjectStatusNormal ObjectStatus = wire.ObjectStatusNormal
+
+	// ObjectStatusObjectDoesNotExist indicates that the object does not exist
+	// at any publisher and will not be published in the future.
+	ObjectStatusObjectDoesNotExist ObjectStatus = wire.ObjectStatusObjectDoesNotExist
+
+	// ObjectStatusEndOfGroup indicates that no objects with the same group ID
+	// and a larger object ID exist.
+	ObjectStatusEndOfGroup ObjectStatus = wire.ObjectStatusEndOfGroup
+
+	// ObjectStatusEndOfTrack indicates that no objects with a larger location
+	// exist in the track.
+	ObjectStatusEndOfTrack ObjectStatus = wire.ObjectStatusEndOfTrack
 )
 
 // An Object is a MoQ Object.
@@ -13,5 +45,80 @@ type Object struct {
 	ObjectID             uint64
 	ForwardingPreference ObjectForwardingPreference
 	SubGroupID           uint64
-	Payload              []byte
+
+	// PublisherPriority is the priority assigned to the object by the
+	// publisher.
+	PublisherPriority uint8
+
+	// Status is the object status. Payload is empty unless Status is
+	// ObjectStatusNormal.
+	Status ObjectStatus
+
+	// ExtensionHeaders contains the object extension headers. It is nil if the
+	// object did not carry any extension headers.
+	ExtensionHeaders KVPList
+
+	// Payload is nil if the payload of a received object is streamed. See
+	// PayloadReader.
+	Payload []byte
+
+	// payload is set if the payload is streamed.
+	payload *payloadReader
+
+	// pooled is the payload buffer if it was taken from the payload pool.
+	pooled []byte
+}
+
+// Release returns the payload buffer of an object received on a stream to the
+// buffer pool, so that it can be reused for objects received later. The
+// object and its payload must not be used after calling Release. Calling
+// Release is optional, payloads that are not released are garbage collected.
+// Release has no effect on other objects, such as objects received in
+// datagrams or created by the application.
+func (o *Object) Release() {
+	if o.pooled == nil {
+		return
+	}
+	pool.Put(o.pooled)
+	o.pooled = nil
+	o.Payload = nil
+}
+
+// PayloadReader returns a reader for the payload of the object. If the
+// payload of a received object is streamed (see WithPayloadStreaming), Payload
+// is nil and the reader reads the payload from the stream as it arrives. In
+// that case, the application must read the payload to the end or close the
+// reader, because no further objects are received on the same stream before.
+// Otherwise, the reader reads Payload.
+func (o *Object) PayloadReader() io.ReadCloser {
+	if o.payload != nil {
+		return o.payload
+	}
+	return io.NopCloser(bytes.NewReader(o.Payload))
+}
+
+// PayloadLength returns the length of the payload, including streamed
+// payloads.
+func (o *Object) PayloadLength() uint64 {
+	if o.payload != nil {
+		return o.payload.length
+	}
+	return uint64(len(o.Payload))
+}
+
+// discardPayload closes the payload reader of a streamed payload, so that the
+// stream continues with the next object.
+func (o *Object) discardPayload() {
+	if o.payload != nil {
+		o.payload.Close()
+	}
+}
+
+// objectExtensionHeaders converts wire extension headers to the public type.
+// Empty lists are returned as nil.
+func objectExtensionHeaders(headers wire.KVPList) KVPList {
+	if len(headers) == 0 {
+		return nil
+	}
+	return FromWire(headers)
 }
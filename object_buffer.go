@@ -0,0 +1,188 @@
+package moqtransport
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultBufferCount is the default maximum number of objects buffered by a
+// RemoteTrack.
+const defaultBufferCount = 100
+
+// OverflowPolicy determines how a RemoteTrack handles incoming objects when
+// its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowPolicyDropNewest drops incoming objects while the buffer is
+	// full.
+	OverflowPolicyDropNewest OverflowPolicy = iota
+
+	// OverflowPolicyDropOldest drops the oldest buffered objects to make room
+	// for incoming objects.
+	OverflowPolicyDropOldest
+
+	// OverflowPolicyDropGroup drops an incoming object that does not fit into
+	// the buffer and all further objects of the same group.
+	OverflowPolicyDropGroup
+
+	// OverflowPolicyBlock blocks reading from the stream until the buffer has
+	// room, so that QUIC flow control pushes back to the publisher. Datagrams
+	// cannot be pushed back and are dropped while the buffer is full.
+	OverflowPolicyBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowPolicyDropNewest:
+		return "drop_newest"
+	case OverflowPolicyDropOldest:
+		return "drop_oldest"
+	case OverflowPolicyDropGroup:
+		return "drop_group"
+	case OverflowPolicyBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// objectBuffer is a FIFO queue of received objects bounded by the number of
+// objects and the total payload size.
+type objectBuffer struct {
+	maxCount int
+	maxBytes int
+	policy   OverflowPolicy
+
+	lock          sync.Mutex
+	objects       []*Object
+	bytes         int
+	droppedGroups map[uint64]struct{}
+
+	// notEmpty and notFull are signalled when objects are pushed or popped.
+	notEmpty chan struct{}
+	notFull  chan struct{}
+
+	droppedObjects atomic.Uint64
+	droppedBytes   atomic.Uint64
+}
+
+// newObjectBuffer creates a new buffer holding at most maxCount objects and
+// maxBytes bytes of payload. A limit of zero or less disables the limit.
+func newObjectBuffer(maxCount, maxBytes int, policy OverflowPolicy) *objectBuffer {
+	return &objectBuffer{
+		maxCount:       maxCount,
+		maxBytes:       maxBytes,
+		policy:         policy,
+		lock:           sync.Mutex{},
+		objects:        []*Object{},
+		bytes:          0,
+		droppedGroups:  map[uint64]struct{}{},
+		notEmpty:       make(chan struct{}, 1),
+		notFull:        make(chan struct{}, 1),
+		droppedObjects: atomic.Uint64{},
+		droppedBytes:   atomic.Uint64{},
+	}
+}
+
+// full reports whether o does not fit into the buffer. An object always fits
+// into an empty buffer.
+func (b *objectBuffer) full(o *Object) bool {
+	if len(b.objects) == 0 {
+		return false
+	}
+	if b.maxCount > 0 && len(b.objects) >= b.maxCount {
+		return true
+	}
+	return b.maxBytes > 0 && b.bytes+len(o.Payload) > b.maxBytes
+}
+
+func (b *objectBuffer) drop(o *Object) {
+	b.droppedObjects.Add(1)
+	b.droppedBytes.Add(o.PayloadLength())
+	o.discardPayload()
+	o.Release()
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push adds o to the buffer according to the overflow policy. If block is
+// true and the policy is OverflowPolicyBlock, push blocks until the buffer has
+// room or ctx is done. It returns false if o was dropped.
+func (b *objectBuffer) push(ctx context.Context, o *Object, block bool) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.policy == OverflowPolicyDropGroup {
+		if _, ok := b.droppedGroups[o.GroupID]; ok {
+			b.drop(o)
+			return false
+		}
+	}
+	for b.full(o) {
+		switch b.policy {
+		case OverflowPolicyDropOldest:
+			oldest := b.objects[0]
+			b.objects[0] = nil
+			b.objects = b.objects[1:]
+			b.bytes -= len(oldest.Payload)
+			b.drop(oldest)
+			continue
+		case OverflowPolicyDropGroup:
+			// Forget groups older than o, they are not current anymore.
+			for id := range b.droppedGroups {
+				if id < o.GroupID {
+					delete(b.droppedGroups, id)
+				}
+			}
+			b.droppedGroups[o.GroupID] = struct{}{}
+		case OverflowPolicyBlock:
+			if block {
+				b.lock.Unlock()
+				select {
+				case <-ctx.Done():
+					b.lock.Lock()
+					b.drop(o)
+					return false
+				case <-b.notFull:
+				}
+				b.lock.Lock()
+				continue
+			}
+		}
+		b.drop(o)
+		return false
+	}
+	b.objects = append(b.objects, o)
+	b.bytes += len(o.Payload)
+	signal(b.notEmpty)
+	// Wake up another blocked writer if there is room left.
+	if !b.full(&Object{}) {
+		signal(b.notFull)
+	}
+	return true
+}
+
+// pop removes and returns the oldest object from the buffer. It returns false
+// if the buffer is empty.
+func (b *objectBuffer) pop() (*Object, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.objects) == 0 {
+		return nil, false
+	}
+	o := b.objects[0]
+	b.objects[0] = nil
+	b.objects = b.objects[1:]
+	b.bytes -= len(o.Payload)
+	signal(b.notFull)
+	if len(b.objects) > 0 {
+		signal(b.notEmpty)
+	}
+	return o, true
+}
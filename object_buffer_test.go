@@ -0,0 +1,107 @@
+package moqtransport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectBuffer(t *testing.T) {
+	obj := func(groupID, objectID uint64, payload string) *Object {
+		return &Object{
+			GroupID:  groupID,
+			ObjectID: objectID,
+			Payload:  []byte(payload),
+		}
+	}
+	popAll := func(b *objectBuffer) []uint64 {
+		ids := []uint64{}
+		for o, ok := b.pop(); ok; o, ok = b.pop() {
+			ids = append(ids, o.GroupID<<8|o.ObjectID)
+		}
+		return ids
+	}
+
+	t.Run("drop_newest", func(t *testing.T) {
+		b := newObjectBuffer(2, 0, OverflowPolicyDropNewest)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "a"), true))
+		assert.True(t, b.push(context.Background(), obj(0, 1, "b"), true))
+		assert.False(t, b.push(context.Background(), obj(0, 2, "c"), true))
+		assert.Equal(t, []uint64{0, 1}, popAll(b))
+		assert.Equal(t, uint64(1), b.droppedObjects.Load())
+		assert.Equal(t, uint64(1), b.droppedBytes.Load())
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		b := newObjectBuffer(2, 0, OverflowPolicyDropOldest)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "a"), true))
+		assert.True(t, b.push(context.Background(), obj(0, 1, "b"), true))
+		assert.True(t, b.push(context.Background(), obj(0, 2, "c"), true))
+		assert.Equal(t, []uint64{1, 2}, popAll(b))
+		assert.Equal(t, uint64(1), b.droppedObjects.Load())
+	})
+
+	t.Run("drop_group", func(t *testing.T) {
+		b := newObjectBuffer(2, 0, OverflowPolicyDropGroup)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "a"), true))
+		assert.True(t, b.push(context.Background(), obj(0, 1, "b"), true))
+		assert.False(t, b.push(context.Background(), obj(0, 2, "c"), true))
+		o, ok := b.pop()
+		assert.True(t, ok)
+		assert.Equal(t, uint64(0), o.ObjectID)
+		// The rest of group 0 is dropped even though there is room now.
+		assert.False(t, b.push(context.Background(), obj(0, 3, "d"), true))
+		assert.True(t, b.push(context.Background(), obj(1, 0, "e"), true))
+		assert.Equal(t, []uint64{1, 1 << 8}, popAll(b))
+		assert.Equal(t, uint64(2), b.droppedObjects.Load())
+	})
+
+	t.Run("limit_bytes", func(t *testing.T) {
+		b := newObjectBuffer(0, 4, OverflowPolicyDropNewest)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "aaa"), true))
+		assert.False(t, b.push(context.Background(), obj(0, 1, "bb"), true))
+		assert.True(t, b.push(context.Background(), obj(0, 2, "c"), true))
+		assert.Equal(t, []uint64{0, 2}, popAll(b))
+		// Objects larger than the limit fit into an empty buffer.
+		assert.True(t, b.push(context.Background(), obj(0, 3, "ddddd"), true))
+		assert.Equal(t, uint64(2), b.droppedBytes.Load())
+	})
+
+	t.Run("block", func(t *testing.T) {
+		b := newObjectBuffer(1, 0, OverflowPolicyBlock)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "a"), true))
+
+		// Datagrams are not blocked.
+		assert.False(t, b.push(context.Background(), obj(0, 1, "b"), false))
+
+		pushed := make(chan bool)
+		go func() {
+			pushed <- b.push(context.Background(), obj(0, 2, "c"), true)
+		}()
+		select {
+		case <-pushed:
+			assert.FailNow(t, "push did not block")
+		case <-time.After(10 * time.Millisecond):
+		}
+		o, ok := b.pop()
+		assert.True(t, ok)
+		assert.Equal(t, uint64(0), o.ObjectID)
+		assert.True(t, <-pushed)
+		assert.Equal(t, []uint64{2}, popAll(b))
+	})
+
+	t.Run("block_cancel", func(t *testing.T) {
+		b := newObjectBuffer(1, 0, OverflowPolicyBlock)
+		assert.True(t, b.push(context.Background(), obj(0, 0, "a"), true))
+		ctx, cancel := context.WithCancel(context.Background())
+		pushed := make(chan bool)
+		go func() {
+			pushed <- b.push(ctx, obj(0, 1, "b"), true)
+		}()
+		cancel()
+		assert.False(t, <-pushed)
+		assert.Equal(t, uint64(1), b.droppedObjects.Load())
+	})
+}
@@ -0,0 +1,76 @@
+package moqtransport
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrObjectPayloadClosed is returned when reading a streamed object payload
+// after the reader was closed.
+var ErrObjectPayloadClosed = errors.New("object payload closed")
+
+// payloadReader reads a streamed object payload as it arrives on the stream.
+// No further objects are read from the stream until the payload was read
+// completely or the reader was closed.
+type payloadReader struct {
+	length uint64
+
+	lock      sync.Mutex
+	reader    io.Reader
+	remaining uint64
+	err       error
+
+	// done is closed after the payload was read completely, reading failed or
+	// the reader was closed.
+	done chan struct{}
+}
+
+func newPayloadReader(r io.Reader, length uint64) *payloadReader {
+	return &payloadReader{
+		length:    length,
+		lock:      sync.Mutex{},
+		reader:    r,
+		remaining: length,
+		err:       nil,
+		done:      make(chan struct{}),
+	}
+}
+
+// Read implements io.Reader.
+func (r *payloadReader) Read(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= uint64(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.finishLocked(err)
+		return n, err
+	}
+	if r.remaining == 0 {
+		r.finishLocked(io.EOF)
+	}
+	return n, nil
+}
+
+// Close implements io.Closer. Unread bytes of the payload are discarded.
+func (r *payloadReader) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.finishLocked(ErrObjectPayloadClosed)
+	return nil
+}
+
+func (r *payloadReader) finishLocked(err error) {
+	if r.err != nil {
+		return
+	}
+	r.err = err
+	close(r.done)
+}
@@ -0,0 +1,257 @@
+package moqtransport
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrTrackNotStored is returned by FetchPublisher.AcceptFromStore if the
+// ObjectStore of the Session does not hold any objects of the requested track.
+var ErrTrackNotStored = errors.New("track not stored in object store")
+
+// An ObjectStore stores the objects of published tracks, so that a Session
+// can answer FETCH requests for the tracks with the stored objects.
+// Implementations must be safe for concurrent use.
+type ObjectStore interface {
+	// Put stores o as an object of track in namespace. The payload and
+	// extension headers of o must not be modified after Put returns.
+	Put(namespace []string, track string, o Object) error
+
+	// Largest returns the location of the largest object of track in
+	// namespace that was stored. ok is false if no objects of the track were
+	// ever stored.
+	Largest(namespace []string, track string) (location Location, ok bool)
+
+	// Objects returns an iterator over the stored objects of track in
+	// namespace from start to end, including both. Groups are ordered by
+	// order and objects within a group by ascending object ID. Objects that
+	// are removed from the store while iterating may be skipped. If reading
+	// an object fails, the iterator yields the error and stops.
+	Objects(namespace []string, track string, start, end Location, order GroupOrder) iter.Seq2[Object, error]
+}
+
+// MemoryObjectStore is an ObjectStore that keeps objects in memory. When the
+// payloads of the stored objects exceed the configured number of bytes, the
+// least recently used objects are evicted. Objects that were stored longer
+// than the configured maximum cache duration are evicted as well.
+type MemoryObjectStore struct {
+	maxBytes         uint64
+	maxCacheDuration time.Duration
+	now              func() time.Time
+
+	lock   sync.Mutex
+	tracks map[string]*memoryStoreTrack
+	bytes  uint64
+
+	// lru holds the entries in least recently used order with the most
+	// recently used entry at the front. age holds the entries in the order
+	// they were stored with the oldest entry at the front.
+	lru *list.List
+	age *list.List
+}
+
+type memoryStoreTrack struct {
+	groups  map[uint64]map[uint64]*memoryStoreEntry
+	largest Location
+}
+
+type memoryStoreEntry struct {
+	track   *memoryStoreTrack
+	object  Object
+	size    uint64
+	stored  time.Time
+	lruElem *list.Element
+	ageElem *list.Element
+}
+
+// NewMemoryObjectStore creates a MemoryObjectStore that holds up to maxBytes
+// bytes of object payloads for at most maxCacheDuration. A maxCacheDuration
+// of zero keeps objects until they are evicted by size.
+func NewMemoryObjectStore(maxBytes uint64, maxCacheDuration time.Duration) *MemoryObjectStore {
+	return &MemoryObjectStore{
+		maxBytes:         maxBytes,
+		maxCacheDuration: maxCacheDuration,
+		now:              time.Now,
+		lock:             sync.Mutex{},
+		tracks:           map[string]*memoryStoreTrack{},
+		bytes:            0,
+		lru:              list.New(),
+		age:              list.New(),
+	}
+}
+
+// Put implements ObjectStore. Objects with a payload larger than the size of
+// the store are not stored, but still count for Largest.
+func (s *MemoryObjectStore) Put(namespace []string, track string, o Object) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t := s.track(namespace, track, o)
+	size := uint64(len(o.Payload))
+	s.expire()
+	if size > s.maxBytes {
+		return nil
+	}
+	if group, ok := t.groups[o.GroupID]; ok {
+		if e, ok := group[o.ObjectID]; ok {
+			s.remove(e)
+		}
+	}
+	for s.bytes+size > s.maxBytes {
+		s.remove(s.lru.Back().Value.(*memoryStoreEntry))
+	}
+	e := &memoryStoreEntry{
+		track:   t,
+		object:  o,
+		size:    size,
+		stored:  s.now(),
+		lruElem: nil,
+		ageElem: nil,
+	}
+	e.lruElem = s.lru.PushFront(e)
+	e.ageElem = s.age.PushBack(e)
+	group, ok := t.groups[o.GroupID]
+	if !ok {
+		group = map[uint64]*memoryStoreEntry{}
+		t.groups[o.GroupID] = group
+	}
+	group[o.ObjectID] = e
+	s.bytes += size
+	return nil
+}
+
+// Largest implements ObjectStore. The largest location includes objects that
+// were evicted since.
+func (s *MemoryObjectStore) Largest(namespace []string, track string) (Location, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.tracks[memoryStoreKey(namespace, track)]
+	if !ok {
+		return Location{}, false
+	}
+	return t.largest, true
+}
+
+// Objects implements ObjectStore. The objects of each group are collected
+// while holding the lock of the store and yielded after releasing it.
+func (s *MemoryObjectStore) Objects(namespace []string, track string, start, end Location, order GroupOrder) iter.Seq2[Object, error] {
+	return func(yield func(Object, error) bool) {
+		for _, groupID := range s.groupIDs(namespace, track, start, end, order) {
+			for _, o := range s.group(namespace, track, groupID, start, end) {
+				if !yield(o, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// groupIDs returns the IDs of the stored groups of a track from start to end
+// in order.
+func (s *MemoryObjectStore) groupIDs(namespace []string, track string, start, end Location, order GroupOrder) []uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.tracks[memoryStoreKey(namespace, track)]
+	if !ok {
+		return nil
+	}
+	s.expire()
+	groupIDs := []uint64{}
+	for id := range t.groups {
+		if id >= start.Group && id <= end.Group {
+			groupIDs = append(groupIDs, id)
+		}
+	}
+	slices.Sort(groupIDs)
+	if order == GroupOrderDescending {
+		slices.Reverse(groupIDs)
+	}
+	return groupIDs
+}
+
+// group returns the stored objects of a group from start to end ordered by
+// object ID.
+func (s *MemoryObjectStore) group(namespace []string, track string, groupID uint64, start, end Location) []Object {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.tracks[memoryStoreKey(namespace, track)]
+	if !ok {
+		return nil
+	}
+	group := t.groups[groupID]
+	objectIDs := []uint64{}
+	for id := range group {
+		l := Location{Group: groupID, Object: id}
+		if !locationBefore(l, start, false) && !locationBefore(end, l, false) {
+			objectIDs = append(objectIDs, id)
+		}
+	}
+	slices.Sort(objectIDs)
+	objects := make([]Object, 0, len(objectIDs))
+	for _, id := range objectIDs {
+		e := group[id]
+		s.lru.MoveToFront(e.lruElem)
+		objects = append(objects, e.object)
+	}
+	return objects
+}
+
+// track returns the track with namespace and name and adds it if it does not
+// exist yet. The largest location of the track is updated with o. s.lock
+// must be held.
+func (s *MemoryObjectStore) track(namespace []string, track string, o Object) *memoryStoreTrack {
+	key := memoryStoreKey(namespace, track)
+	l := objectLocation(&o)
+	t, ok := s.tracks[key]
+	if !ok {
+		t = &memoryStoreTrack{
+			groups:  map[uint64]map[uint64]*memoryStoreEntry{},
+			largest: l,
+		}
+		s.tracks[key] = t
+	}
+	if locationBefore(t.largest, l, false) {
+		t.largest = l
+	}
+	return t
+}
+
+// expire removes the entries that were stored longer than the maximum cache
+// duration. s.lock must be held.
+func (s *MemoryObjectStore) expire() {
+	if s.maxCacheDuration == 0 {
+		return
+	}
+	deadline := s.now().Add(-s.maxCacheDuration)
+	for front := s.age.Front(); front != nil; front = s.age.Front() {
+		e := front.Value.(*memoryStoreEntry)
+		if e.stored.After(deadline) {
+			return
+		}
+		s.remove(e)
+	}
+}
+
+// remove removes e from the store. s.lock must be held.
+func (s *MemoryObjectStore) remove(e *memoryStoreEntry) {
+	s.lru.Remove(e.lruElem)
+	s.age.Remove(e.ageElem)
+	group := e.track.groups[e.object.GroupID]
+	delete(group, e.object.ObjectID)
+	if len(group) == 0 {
+		delete(e.track.groups, e.object.GroupID)
+	}
+	s.bytes -= e.size
+}
+
+func memoryStoreKey(namespace []string, track string) string {
+	return fmt.Sprintf("%q", append(slices.Clone(namespace), track))
+}
@@ -0,0 +1,143 @@
+package moqtransport
+
+import (
+	"iter"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testObjectStore runs the checks of the ObjectStore contract against the
+// stores created by newStore.
+func testObjectStore(t *testing.T, newStore func(t *testing.T) ObjectStore) {
+	ns := []string{"namespace"}
+	obj := func(groupID, objectID uint64, payload string) Object {
+		return Object{
+			GroupID:              groupID,
+			ObjectID:             objectID,
+			ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+			Payload:              []byte(payload),
+		}
+	}
+	ids := func(t *testing.T, objects iter.Seq2[Object, error]) []uint64 {
+		res := []uint64{}
+		for o, err := range objects {
+			assert.NoError(t, err)
+			res = append(res, o.GroupID<<8|o.ObjectID)
+		}
+		return res
+	}
+	all := Location{Group: math.MaxUint64, Object: math.MaxUint64}
+
+	t.Run("unknown_track", func(t *testing.T) {
+		s := newStore(t)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		_, ok := s.Largest(ns, "other")
+		assert.False(t, ok)
+		_, ok = s.Largest([]string{"other"}, "track")
+		assert.False(t, ok)
+		assert.Empty(t, ids(t, s.Objects(ns, "other", Location{}, all, GroupOrderAscending)))
+	})
+
+	t.Run("range_and_order", func(t *testing.T) {
+		s := newStore(t)
+		for g := range uint64(3) {
+			for o := range uint64(3) {
+				assert.NoError(t, s.Put(ns, "track", obj(g, o, "a")))
+			}
+		}
+		assert.Equal(t, []uint64{0x002, 0x100, 0x101, 0x102, 0x200}, ids(t, s.Objects(ns, "track", Location{Group: 0, Object: 2}, Location{Group: 2, Object: 0}, GroupOrderAscending)))
+		assert.Equal(t, []uint64{0x200, 0x201, 0x202, 0x101, 0x102}, ids(t, s.Objects(ns, "track", Location{Group: 1, Object: 1}, all, GroupOrderDescending)))
+		assert.Empty(t, ids(t, s.Objects(ns, "track", Location{Group: 5, Object: 0}, all, GroupOrderAscending)))
+	})
+
+	t.Run("largest", func(t *testing.T) {
+		s := newStore(t)
+		assert.NoError(t, s.Put(ns, "track", obj(1, 2, "a")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 5, "b")))
+		largest, ok := s.Largest(ns, "track")
+		assert.True(t, ok)
+		assert.Equal(t, Location{Group: 1, Object: 2}, largest)
+	})
+
+	t.Run("stop_iteration", func(t *testing.T) {
+		s := newStore(t)
+		for g := range uint64(2) {
+			for o := range uint64(2) {
+				assert.NoError(t, s.Put(ns, "track", obj(g, o, "a")))
+			}
+		}
+		res := []uint64{}
+		for o, err := range s.Objects(ns, "track", Location{}, all, GroupOrderAscending) {
+			assert.NoError(t, err)
+			res = append(res, o.GroupID<<8|o.ObjectID)
+			if len(res) == 3 {
+				break
+			}
+		}
+		assert.Equal(t, []uint64{0x000, 0x001, 0x100}, res)
+	})
+}
+
+func TestMemoryObjectStore(t *testing.T) {
+	testObjectStore(t, func(t *testing.T) ObjectStore {
+		return NewMemoryObjectStore(100, 0)
+	})
+
+	ns := []string{"namespace"}
+	obj := func(groupID, objectID uint64, payload string) Object {
+		return Object{
+			GroupID:  groupID,
+			ObjectID: objectID,
+			Payload:  []byte(payload),
+		}
+	}
+	ids := func(t *testing.T, objects iter.Seq2[Object, error]) []uint64 {
+		res := []uint64{}
+		for o, err := range objects {
+			assert.NoError(t, err)
+			res = append(res, o.GroupID<<8|o.ObjectID)
+		}
+		return res
+	}
+	all := Location{Group: math.MaxUint64, Object: math.MaxUint64}
+
+	t.Run("largest_includes_evicted", func(t *testing.T) {
+		s := NewMemoryObjectStore(3, 0)
+		assert.NoError(t, s.Put(ns, "track", obj(1, 2, "a")))
+
+		// Evicted and oversized objects still count.
+		assert.NoError(t, s.Put(ns, "track", obj(2, 0, "toolarge")))
+		largest, ok := s.Largest(ns, "track")
+		assert.True(t, ok)
+		assert.Equal(t, Location{Group: 2, Object: 0}, largest)
+	})
+
+	t.Run("evict_least_recently_used", func(t *testing.T) {
+		s := NewMemoryObjectStore(3, 0)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "b")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 2, "c")))
+		ids(t, s.Objects(ns, "track", Location{Group: 0, Object: 0}, Location{Group: 0, Object: 0}, GroupOrderAscending))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 3, "d")))
+		assert.NoError(t, s.Put(ns, "track", obj(0, 4, "toolarge")))
+
+		assert.Equal(t, []uint64{0, 2, 3}, ids(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending)))
+		assert.Equal(t, uint64(3), s.bytes)
+	})
+
+	t.Run("max_cache_duration", func(t *testing.T) {
+		now := time.Now()
+		s := NewMemoryObjectStore(100, time.Second)
+		s.now = func() time.Time { return now }
+		assert.NoError(t, s.Put(ns, "track", obj(0, 0, "a")))
+		now = now.Add(600 * time.Millisecond)
+		assert.NoError(t, s.Put(ns, "track", obj(0, 1, "b")))
+		now = now.Add(600 * time.Millisecond)
+
+		assert.Equal(t, []uint64{1}, ids(t, s.Objects(ns, "track", Location{}, all, GroupOrderAscending)))
+		assert.Equal(t, uint64(1), s.bytes)
+	})
+}
@@ -2,6 +2,7 @@ package quicmoq
 
 import (
 	"context"
+	"errors"
 
 	"github.com/mengelbart/moqtransport"
 	"github.com/quic-go/quic-go"
@@ -85,7 +86,14 @@ func (c *connection) OpenUniStreamSync(ctx context.Context) (moqtransport.SendSt
 }
 
 func (c *connection) SendDatagram(b []byte) error {
-	return c.connection.SendDatagram(b)
+	err := c.connection.SendDatagram(b)
+	var tooLarge *quic.DatagramTooLargeError
+	if errors.As(err, &tooLarge) {
+		return &moqtransport.DatagramTooLargeError{
+			MaxDatagramSize: tooLarge.MaxDatagramPayloadSize,
+		}
+	}
+	return err
 }
 
 func (c *connection) ReceiveDatagram(ctx context.Context) ([]byte, error) {
@@ -13,7 +13,8 @@ type Stream struct {
 
 // Read implements moqtransport.Stream.
 func (s *Stream) Read(p []byte) (n int, err error) {
-	return s.stream.Read(p)
+	n, err = s.stream.Read(p)
+	return n, readError(err)
 }
 
 // Write implements moqtransport.Stream.
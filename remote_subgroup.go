@@ -0,0 +1,126 @@
+package moqtransport
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrSubgroupStopped is returned when reading from a RemoteSubgroup after it
+// was stopped.
+var ErrSubgroupStopped = errors.New("subgroup stopped")
+
+// remoteSubgroupBufferSize is the number of objects buffered per
+// RemoteSubgroup. If the buffer is full, reading from the stream blocks and
+// QUIC flow control pushes back to the publisher.
+const remoteSubgroupBufferSize = 16
+
+// RemoteSubgroup is a subgroup stream received from the peer. Objects are read
+// in the order in which they were sent on the stream.
+type RemoteSubgroup struct {
+	stream            ReceiveStream
+	groupID           uint64
+	subgroupID        uint64
+	publisherPriority uint8
+
+	objects chan *Object
+	err     error
+
+	stopCtx    context.Context
+	stopCancel context.CancelCauseFunc
+}
+
+func newRemoteSubgroup(stream ReceiveStream, groupID, subgroupID uint64, publisherPriority uint8) *RemoteSubgroup {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &RemoteSubgroup{
+		stream:            stream,
+		groupID:           groupID,
+		subgroupID:        subgroupID,
+		publisherPriority: publisherPriority,
+		objects:           make(chan *Object, remoteSubgroupBufferSize),
+		err:               nil,
+		stopCtx:           ctx,
+		stopCancel:        cancel,
+	}
+}
+
+// GroupID returns the group ID of the subgroup.
+func (s *RemoteSubgroup) GroupID() uint64 {
+	return s.groupID
+}
+
+// SubgroupID returns the subgroup ID of the subgroup.
+func (s *RemoteSubgroup) SubgroupID() uint64 {
+	return s.subgroupID
+}
+
+// PublisherPriority returns the publisher priority of the subgroup.
+func (s *RemoteSubgroup) PublisherPriority() uint8 {
+	return s.publisherPriority
+}
+
+// ReadObject returns the next object of the subgroup. It returns io.EOF after
+// the last object of the subgroup was read and ErrSubgroupStopped after Stop
+// was called.
+func (s *RemoteSubgroup) ReadObject(ctx context.Context) (*Object, error) {
+	if s.stopCtx.Err() != nil {
+		return nil, context.Cause(s.stopCtx)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	case <-s.stopCtx.Done():
+		return nil, context.Cause(s.stopCtx)
+	case o, ok := <-s.objects:
+		if !ok {
+			return nil, s.err
+		}
+		return o, nil
+	}
+}
+
+// Stop stops receiving the subgroup and asks the publisher to stop sending
+// it. Objects that were not read yet are discarded. Other subgroups of the
+// track are not affected.
+func (s *RemoteSubgroup) Stop() {
+	s.stopCancel(ErrSubgroupStopped)
+	s.stream.Stop(uint32(ErrorCodeStreamCancelled))
+	for {
+		select {
+		case o, ok := <-s.objects:
+			if !ok {
+				return
+			}
+			o.discardPayload()
+			o.Release()
+		default:
+			return
+		}
+	}
+}
+
+// push adds o to the subgroup, blocking until there is room or ctx is done.
+func (s *RemoteSubgroup) push(ctx context.Context, o *Object) {
+	select {
+	case s.objects <- o:
+	case <-ctx.Done():
+		o.discardPayload()
+		o.Release()
+	case <-s.stopCtx.Done():
+		o.discardPayload()
+		o.Release()
+	}
+}
+
+// close ends the subgroup after the stream was read with error err.
+func (s *RemoteSubgroup) close(err error) {
+	switch {
+	case s.stopCtx.Err() != nil:
+		s.err = context.Cause(s.stopCtx)
+	case err == nil:
+		s.err = io.EOF
+	default:
+		s.err = err
+	}
+	close(s.objects)
+}
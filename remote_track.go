@@ -5,12 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-var errTooManyFetchStreams = errors.New("got too many fetch streams for remote track")
+var (
+	errTooManyFetchStreams     = errors.New("got too many fetch streams for remote track")
+	errSubgroupReadersDisabled = errors.New("subgroup readers are not enabled for remote track")
+)
+
+// subscribeDoneTimeout is the maximum time a RemoteTrack waits for the
+// subgroup streams announced in SUBSCRIBE_DONE before the subscription ends.
+const subscribeDoneTimeout = time.Second
 
 // ErrSubscribeDone is returned when reading from a RemoteTrack when the
 // subscription has ended.
@@ -36,10 +45,27 @@ type RemoteTrack struct {
 	largestLocation *Location // Only set iff ContentExists is true
 	parameters      KVPList
 
+	// endOfTrack and endLocation are returned in the FETCH_OK of a fetch.
+	// The group order and parameters of a fetch are stored above.
+	endOfTrack  bool
+	endLocation *Location // Only set for fetches
+
 	logger          *slog.Logger
 	unsubscribeFunc func() error
 	updateFunc      func(context.Context, ...SubscribeUpdateOption) error
-	buffer          chan *Object
+	buffer          *objectBuffer
+
+	// subgroups is nil unless subgroup readers are enabled.
+	subgroups chan *RemoteSubgroup
+
+	gaps *gapDetector
+
+	// reorder is nil unless ordered delivery is enabled.
+	reorder *reorderBuffer
+
+	// payloadStreamingThreshold is the payload size above which payloads
+	// received on streams are streamed. Zero disables streaming.
+	payloadStreamingThreshold uint64
 
 	doneCtx       context.Context
 	doneCtxCancel context.CancelCauseFunc
@@ -47,6 +73,22 @@ type RemoteTrack struct {
 	subGroupCount atomic.Uint64
 	fetchCount    atomic.Uint64 // should never grow larger than one for now.
 
+	// doneLock protects a SUBSCRIBE_DONE that is held back until the number
+	// of subgroup streams it announced were read.
+	doneLock       sync.Mutex
+	streamsRead    uint64
+	pendingDone    *ErrSubscribeDone
+	pendingStreams uint64
+	doneTimer      *time.Timer
+
+	// Delivery timeouts requested by the subscriber in SUBSCRIBE or
+	// SUBSCRIBE_UPDATE and by the publisher in SUBSCRIBE_OK.
+	subscriberDeliveryTimeout atomic.Int64
+	publisherDeliveryTimeout  atomic.Int64
+
+	// largestGroupID is the largest group ID received on the track.
+	largestGroupID atomic.Uint64
+
 	responseChan chan error
 }
 
@@ -80,6 +122,22 @@ func (t *RemoteTrack) Parameters() KVPList {
 	return t.parameters
 }
 
+// EndOfTrack reports whether the publisher indicated in FETCH_OK that the
+// fetched range includes the end of the track. It is false for
+// subscriptions.
+func (t *RemoteTrack) EndOfTrack() bool {
+	return t.endOfTrack
+}
+
+// EndLocation returns the location of the last object of a fetch as sent by
+// the publisher in FETCH_OK. Returns false for subscriptions.
+func (t *RemoteTrack) EndLocation() (Location, bool) {
+	if t.endLocation != nil {
+		return *t.endLocation, true
+	}
+	return Location{}, false
+}
+
 // UpdateSubscription updates the subscription parameters for this track.
 // No response is expected according to draft-11 specification.
 func (t *RemoteTrack) UpdateSubscription(ctx context.Context, options ...SubscribeUpdateOption) error {
@@ -93,29 +151,102 @@ func newRemoteTrack(requestID uint64, unsubscribeFunc func() error, updateFunc f
 	ctx, cancel := context.WithCancelCause(context.Background())
 	t := &RemoteTrack{
 		requestID:       requestID,
+		endOfTrack:      false,
+		endLocation:     nil,
 		logger:          defaultLogger,
 		unsubscribeFunc: unsubscribeFunc,
 		updateFunc:      updateFunc,
-		buffer:          make(chan *Object, 100),
+		buffer:          newObjectBuffer(defaultBufferCount, 0, OverflowPolicyDropNewest),
+		gaps:            newGapDetector(nil),
 		doneCtx:         ctx,
 		doneCtxCancel:   cancel,
 		subGroupCount:   atomic.Uint64{},
 		fetchCount:      atomic.Uint64{},
-		responseChan:    make(chan error, 1),
+		doneLock:        sync.Mutex{},
+		streamsRead:     0,
+		pendingDone:     nil,
+		pendingStreams:  0,
+		doneTimer:       nil,
+
+		subscriberDeliveryTimeout: atomic.Int64{},
+		publisherDeliveryTimeout:  atomic.Int64{},
+		largestGroupID:            atomic.Uint64{},
+
+		responseChan: make(chan error, 1),
 	}
 	return t
 }
 
-// ReadObject returns the next object received from the peer.
+// ReadObject returns the next object received from the peer. Objects
+// buffered before the subscription ended are returned before the error ending
+// the subscription. After a subscription was ended by SUBSCRIBE_DONE,
+// ReadObject returns an *ErrSubscribeDone. After all objects of a fetch were
+// read, ReadObject returns io.EOF.
 func (t *RemoteTrack) ReadObject(ctx context.Context) (*Object, error) {
-	select {
-	case <-ctx.Done():
-		return nil, context.Cause(ctx)
-	case <-t.doneCtx.Done():
-		return nil, context.Cause(t.doneCtx)
-	case obj := <-t.buffer:
-		return obj, t.doneCtx.Err()
+	for {
+		if obj, ok := t.buffer.pop(); ok {
+			return obj, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-t.doneCtx.Done():
+			return nil, context.Cause(t.doneCtx)
+		case <-t.buffer.notEmpty:
+		}
+	}
+}
+
+// Objects returns an iterator over the objects received from the peer. The
+// iteration ends without an error when the subscription is ended by
+// SUBSCRIBE_DONE or all objects of a fetch were read. Use SubscribeDone to get
+// the status of the SUBSCRIBE_DONE. Other errors, including errors of ctx, are
+// yielded and end the iteration.
+func (t *RemoteTrack) Objects(ctx context.Context) iter.Seq2[*Object, error] {
+	return func(yield func(*Object, error) bool) {
+		for {
+			o, err := t.ReadObject(ctx)
+			if err != nil {
+				if !isTrackEnd(err) {
+					yield(nil, err)
+				}
+				return
+			}
+			if !yield(o, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SubscribeDone returns the status code and reason of the SUBSCRIBE_DONE that
+// ended the subscription. It returns false if the subscription was not ended
+// by SUBSCRIBE_DONE.
+func (t *RemoteTrack) SubscribeDone() (ErrSubscribeDone, bool) {
+	var done *ErrSubscribeDone
+	if errors.As(context.Cause(t.doneCtx), &done) {
+		return *done, true
 	}
+	return ErrSubscribeDone{}, false
+}
+
+// isTrackEnd reports whether err indicates the regular end of a subscription
+// or fetch.
+func isTrackEnd(err error) bool {
+	var done *ErrSubscribeDone
+	return err == io.EOF || errors.As(err, &done)
+}
+
+// DroppedObjects returns the number of received objects that were dropped
+// because the buffer was full.
+func (t *RemoteTrack) DroppedObjects() uint64 {
+	return t.buffer.droppedObjects.Load()
+}
+
+// DroppedBytes returns the total payload size of received objects that were
+// dropped because the buffer was full.
+func (t *RemoteTrack) DroppedBytes() uint64 {
+	return t.buffer.droppedBytes.Load()
 }
 
 // Close implements io.Closer. Calling close unsubscribes from the subscription.
@@ -130,15 +261,114 @@ func (t *RemoteTrack) readFetchStream(parser objectMessageParser) error {
 	if t.fetchCount.Add(1) > 1 {
 		return errTooManyFetchStreams
 	}
-	return t.readStream(parser)
+	err := t.readStream(parser, nil, func(o *Object) {
+		t.gaps.object(o)
+		t.push(true, o)
+	})
+	if err == nil {
+		// All objects of the fetch were received.
+		t.finish(io.EOF)
+		return nil
+	}
+	// The fetch has only one stream, so it ends with the error of the
+	// stream, e.g. when the publisher reset it.
+	t.finish(err)
+	return err
 }
 
-func (t *RemoteTrack) readSubgroupStream(parser objectMessageParser) error {
+func (t *RemoteTrack) readSubgroupStream(stream ReceiveStream, parser objectMessageParser) error {
 	t.subGroupCount.Add(1)
-	return t.readStream(parser)
+	defer t.streamRead()
+	var sg *RemoteSubgroup
+	var last *Location
+	var subgroupID uint64
+	ended := false
+	err := t.readStream(parser, stream, func(o *Object) {
+		last = &Location{Group: o.GroupID, Object: o.ObjectID}
+		subgroupID = o.SubGroupID
+		ended = o.Status == ObjectStatusEndOfGroup || o.Status == ObjectStatusEndOfTrack
+		t.gaps.subgroupObject(o)
+		if t.subgroups == nil {
+			t.push(true, o)
+			return
+		}
+		t.observeGroup(o.GroupID)
+		// The subgroup is announced when the first object arrives, because
+		// the subgroup ID of some stream types is only known after the
+		// first object.
+		if sg == nil {
+			sg = newRemoteSubgroup(stream, o.GroupID, o.SubGroupID, o.PublisherPriority)
+			select {
+			case t.subgroups <- sg:
+			case <-t.doneCtx.Done():
+			}
+		}
+		sg.push(t.doneCtx, o)
+	})
+	if sg != nil {
+		sg.close(err)
+	}
+	var resetErr *StreamResetError
+	t.gaps.subgroupEnd(last, subgroupID, !ended && errors.As(err, &resetErr))
+	return err
 }
 
-func (t *RemoteTrack) readStream(parser objectMessageParser) error {
+// pushDatagram adds an object received in a datagram.
+func (t *RemoteTrack) pushDatagram(o *Object) {
+	t.gaps.datagram(o)
+	t.push(false, o)
+}
+
+// GapStats returns the number of gaps detected on the subscription.
+func (t *RemoteTrack) GapStats() GapStats {
+	return t.gaps.stats()
+}
+
+// AcceptSubgroup returns the next subgroup stream received from the peer. It
+// is only available if the subscription was created with
+// WithSubgroupReaders(true). Objects received on subgroup streams are then
+// only returned by the RemoteSubgroups, objects received in datagrams are still
+// returned by ReadObject.
+func (t *RemoteTrack) AcceptSubgroup(ctx context.Context) (*RemoteSubgroup, error) {
+	if t.subgroups == nil {
+		return nil, errSubgroupReadersDisabled
+	}
+	select {
+	case sg := <-t.subgroups:
+		return sg, nil
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	case <-t.doneCtx.Done():
+		return nil, context.Cause(t.doneCtx)
+	case sg := <-t.subgroups:
+		return sg, nil
+	}
+}
+
+// deliveryTimeout returns the effective delivery timeout of the track, which
+// is the smaller of the subscriber's and the publisher's timeout. Zero means
+// no timeout.
+func (t *RemoteTrack) deliveryTimeout() time.Duration {
+	return minDeliveryTimeout(
+		time.Duration(t.subscriberDeliveryTimeout.Load()),
+		time.Duration(t.publisherDeliveryTimeout.Load()),
+	)
+}
+
+// readStream reads objects from parser and passes them to deliver. If stream
+// is not nil, the stream is stopped when it becomes stale according to the
+// delivery timeout.
+func (t *RemoteTrack) readStream(parser objectMessageParser, stream ReceiveStream, deliver func(*Object)) error {
+	if t.payloadStreamingThreshold > 0 {
+		parser.SetPayloadStreamingThreshold(t.payloadStreamingThreshold)
+	}
+	var watchdog *staleStreamWatchdog
+	defer func() {
+		watchdog.stop()
+	}()
 	for m, err := range parser.Messages() {
 		if err != nil {
 			if err == io.EOF {
@@ -146,34 +376,144 @@ func (t *RemoteTrack) readStream(parser objectMessageParser) error {
 			}
 			return err
 		}
-		t.logger.Debug("subgroup got new object message", "message", m)
-		payload := make([]byte, len(m.ObjectPayload))
-		n := copy(payload, m.ObjectPayload)
-		if n != len(m.ObjectPayload) {
-			// TODO
-			return errors.New("failed to copy object payload: copied less bytes than expected")
+		if stream != nil && watchdog == nil {
+			if timeout := t.deliveryTimeout(); timeout > 0 {
+				watchdog = newStaleStreamWatchdog(t, stream, m.GroupID, timeout)
+			}
+		}
+		watchdog.touch()
+		if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+			t.logger.Debug("subgroup got new object message", "message", m)
+		}
+		o := &Object{
+			GroupID:              m.GroupID,
+			SubGroupID:           m.SubgroupID,
+			ObjectID:             m.ObjectID,
+			ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+			PublisherPriority:    m.PublisherPriority,
+			Status:               m.ObjectStatus,
+			ExtensionHeaders:     objectExtensionHeaders(m.ObjectExtensionHeaders),
+			Payload:              m.ObjectPayload,
+			payload:              nil,
+			pooled:               m.ObjectPayload,
+		}
+		if m.ObjectPayloadReader != nil {
+			o.payload = newPayloadReader(m.ObjectPayloadReader, m.ObjectPayloadLength)
+		} else if o.Payload == nil {
+			o.Payload = []byte{}
+		}
+		deliver(o)
+		if o.payload != nil {
+			// Wait until the application consumed the payload before
+			// parsing the next object from the stream.
+			select {
+			case <-o.payload.done:
+			case <-t.doneCtx.Done():
+				o.discardPayload()
+				return context.Cause(t.doneCtx)
+			}
 		}
-		t.push(&Object{
-			GroupID:    m.GroupID,
-			SubGroupID: m.SubgroupID,
-			ObjectID:   m.ObjectID,
-			Payload:    payload,
-		})
 	}
 	return nil
 }
 
-func (t *RemoteTrack) done(status uint64, reason string) {
-	t.doneCtxCancel(&ErrSubscribeDone{
+// observeGroup records that an object of groupID was received.
+func (t *RemoteTrack) observeGroup(groupID uint64) {
+	for {
+		largest := t.largestGroupID.Load()
+		if groupID <= largest || t.largestGroupID.CompareAndSwap(largest, groupID) {
+			return
+		}
+	}
+}
+
+// done ends the subscription after streamCount subgroup streams were read or
+// after subscribeDoneTimeout, whichever happens first, so that objects on
+// streams arriving after the SUBSCRIBE_DONE are not lost.
+func (t *RemoteTrack) done(status uint64, reason string, streamCount uint64) {
+	err := &ErrSubscribeDone{
 		Status: status,
 		Reason: reason,
+	}
+	t.doneLock.Lock()
+	if t.streamsRead >= streamCount {
+		t.doneLock.Unlock()
+		t.finish(err)
+		return
+	}
+	t.pendingDone = err
+	t.pendingStreams = streamCount
+	t.doneTimer = time.AfterFunc(subscribeDoneTimeout, func() {
+		t.doneLock.Lock()
+		err := t.pendingDone
+		t.pendingDone = nil
+		t.doneLock.Unlock()
+		if err != nil {
+			t.finish(err)
+		}
 	})
+	t.doneLock.Unlock()
 }
 
-func (t *RemoteTrack) push(o *Object) {
-	select {
-	case t.buffer <- o:
-	default:
-		t.logger.Info("buffer overflow: dropping incoming object")
+// streamRead counts a subgroup stream that was read until its end and ends a
+// pending SUBSCRIBE_DONE once all announced streams were read.
+func (t *RemoteTrack) streamRead() {
+	t.doneLock.Lock()
+	t.streamsRead++
+	err := t.pendingDone
+	if err == nil || t.streamsRead < t.pendingStreams {
+		t.doneLock.Unlock()
+		return
+	}
+	t.pendingDone = nil
+	t.doneTimer.Stop()
+	t.doneLock.Unlock()
+	t.finish(err)
+}
+
+// finish ends the track with err, which is returned by ReadObject after all
+// buffered objects were read.
+func (t *RemoteTrack) finish(err error) {
+	if t.reorder != nil {
+		// Deliver held objects before the track ends.
+		t.reorder.flush()
+	}
+	t.doneCtxCancel(err)
+}
+
+// push adds o to the buffer, or to the reorder buffer if ordered delivery is
+// enabled. If block is true, push may block according to the overflow policy
+// of the buffer.
+func (t *RemoteTrack) push(block bool, o *Object) {
+	t.observeGroup(o.GroupID)
+	if t.reorder != nil {
+		t.reorder.push(o)
+		return
+	}
+	t.deliver(block, o)
+}
+
+// enableReorder enables ordered delivery of objects with the given reorder
+// window.
+func (t *RemoteTrack) enableReorder(window time.Duration, maxObjects int, groupOrder GroupOrder) {
+	t.reorder = newReorderBuffer(window, maxObjects, groupOrder == GroupOrderDescending,
+		func(o *Object) {
+			// Objects are released with the reorder buffer's lock held,
+			// so they must not block.
+			t.deliver(false, o)
+		},
+		func(o *Object) {
+			t.buffer.drop(o)
+			t.logger.Debug("reorder buffer: dropped late object",
+				"group_id", o.GroupID, "object_id", o.ObjectID)
+		},
+	)
+}
+
+func (t *RemoteTrack) deliver(block bool, o *Object) {
+	if !t.buffer.push(t.doneCtx, o, block) {
+		t.logger.Debug("buffer overflow: dropped incoming object",
+			"group_id", o.GroupID, "object_id", o.ObjectID,
+			"policy", t.buffer.policy, "dropped_objects", t.buffer.droppedObjects.Load())
 	}
 }
@@ -114,3 +114,24 @@ func (m *remoteTrackMap) findByTrackAlias(alias uint64) (*RemoteTrack, bool) {
 	}
 	return m.findByRequestID(id)
 }
+
+// closeAll ends all pending and open tracks with err.
+func (m *remoteTrackMap) closeAll(err error) {
+	m.lock.Lock()
+	pending := m.pending
+	open := m.open
+	m.pending = map[uint64]*RemoteTrack{}
+	m.open = map[uint64]*RemoteTrack{}
+	m.trackAliasToRequestID = map[uint64]uint64{}
+	m.lock.Unlock()
+	for _, rt := range pending {
+		select {
+		case rt.responseChan <- err:
+		default:
+		}
+		rt.finish(err)
+	}
+	for _, rt := range open {
+		rt.finish(err)
+	}
+}
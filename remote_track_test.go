@@ -0,0 +1,147 @@
+package moqtransport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mengelbart/moqtransport/internal/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectRelease(t *testing.T) {
+	t.Run("stream_object", func(t *testing.T) {
+		shm := wire.SubgroupHeaderMessage{}
+		om := wire.ObjectMessage{
+			ObjectPayload: []byte("hello"),
+		}
+		data := om.AppendSubgroup(shm.Append(nil))
+		p, err := wire.NewObjectStreamParser(bytes.NewReader(data), 0, nil)
+		assert.NoError(t, err)
+		rt := newRemoteTrack(0, nil, nil)
+		var objects []*Object
+		assert.NoError(t, rt.readStream(p, nil, func(o *Object) {
+			objects = append(objects, o)
+		}))
+		assert.Len(t, objects, 1)
+		assert.Equal(t, []byte("hello"), objects[0].Payload)
+		objects[0].Release()
+		assert.Nil(t, objects[0].Payload)
+	})
+
+	t.Run("application_object", func(t *testing.T) {
+		// Buffers that were not taken from the pool are not recycled.
+		payload := make([]byte, 64)
+		o := &Object{
+			Payload: payload,
+		}
+		o.Release()
+		assert.Equal(t, payload, o.Payload)
+	})
+}
+
+func TestRemoteTrackSubscribeDone(t *testing.T) {
+	subgroupParser := func(t *testing.T) objectMessageParser {
+		shm := wire.SubgroupHeaderMessage{}
+		om := wire.ObjectMessage{
+			ObjectPayload: []byte("hello"),
+		}
+		data := om.AppendSubgroup(shm.Append(nil))
+		p, err := wire.NewObjectStreamParser(bytes.NewReader(data), 0, nil)
+		assert.NoError(t, err)
+		return p
+	}
+	isDone := func(rt *RemoteTrack) bool {
+		select {
+		case <-rt.doneCtx.Done():
+			return true
+		default:
+			return false
+		}
+	}
+
+	t.Run("no_streams", func(t *testing.T) {
+		rt := newRemoteTrack(0, nil, nil)
+		rt.done(SubscribeStatusTrackEnded, "", 0)
+		assert.True(t, isDone(rt))
+	})
+
+	t.Run("streams_read_before_done", func(t *testing.T) {
+		rt := newRemoteTrack(0, nil, nil)
+		assert.NoError(t, rt.readSubgroupStream(nil, subgroupParser(t)))
+		rt.done(SubscribeStatusTrackEnded, "", 1)
+		assert.True(t, isDone(rt))
+	})
+
+	t.Run("waits_for_streams", func(t *testing.T) {
+		rt := newRemoteTrack(0, nil, nil)
+		rt.done(SubscribeStatusTrackEnded, "", 1)
+		assert.False(t, isDone(rt))
+		assert.NoError(t, rt.readSubgroupStream(nil, subgroupParser(t)))
+		assert.True(t, isDone(rt))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		o, err := rt.ReadObject(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), o.Payload)
+		_, err = rt.ReadObject(ctx)
+		assert.Equal(t, &ErrSubscribeDone{
+			Status: SubscribeStatusTrackEnded,
+			Reason: "",
+		}, err)
+	})
+
+	t.Run("times_out", func(t *testing.T) {
+		rt := newRemoteTrack(0, nil, nil)
+		rt.done(SubscribeStatusTrackEnded, "", 1)
+		assert.False(t, isDone(rt))
+		assert.Eventually(t, func() bool {
+			return isDone(rt)
+		}, 2*subscribeDoneTimeout, 10*time.Millisecond)
+	})
+}
+
+func BenchmarkRemoteTrackReadStream(b *testing.B) {
+	for _, size := range []int{100, 1024, 16 * 1024} {
+		shm := wire.SubgroupHeaderMessage{
+			TrackAlias:        1,
+			GroupID:           2,
+			SubgroupID:        3,
+			PublisherPriority: 4,
+		}
+		data := shm.Append(nil)
+		payload := make([]byte, size)
+		for i := range 1000 {
+			m := wire.ObjectMessage{
+				ObjectID:      uint64(i),
+				ObjectPayload: payload,
+			}
+			data = m.AppendSubgroup(data)
+		}
+		for _, release := range []bool{false, true} {
+			b.Run(fmt.Sprintf("size=%v/release=%v", size, release), func(b *testing.B) {
+				rt := newRemoteTrack(0, nil, nil)
+				b.SetBytes(int64(len(data)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					p, err := wire.NewObjectStreamParser(bytes.NewReader(data), 0, nil)
+					if err != nil {
+						b.Fatal(err)
+					}
+					err = rt.readStream(p, nil, func(o *Object) {
+						if release {
+							o.Release()
+						}
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
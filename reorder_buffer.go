@@ -0,0 +1,230 @@
+package moqtransport
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type reorderEntry struct {
+	object  *Object
+	arrival time.Time
+}
+
+// reorderQueue is a heap of held objects ordered by group and object ID.
+type reorderQueue struct {
+	descending bool
+	entries    []reorderEntry
+}
+
+// locationBefore reports whether an object at a is delivered before an object
+// at b. Groups are delivered in ascending order unless descending is true,
+// objects within a group in ascending order.
+func locationBefore(a, b Location, descending bool) bool {
+	if a.Group != b.Group {
+		if descending {
+			return a.Group > b.Group
+		}
+		return a.Group < b.Group
+	}
+	return a.Object < b.Object
+}
+
+func objectLocation(o *Object) Location {
+	return Location{Group: o.GroupID, Object: o.ObjectID}
+}
+
+func (q *reorderQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *reorderQueue) Less(i, j int) bool {
+	return locationBefore(objectLocation(q.entries[i].object), objectLocation(q.entries[j].object), q.descending)
+}
+
+func (q *reorderQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+}
+
+func (q *reorderQueue) Push(x any) {
+	q.entries = append(q.entries, x.(reorderEntry))
+}
+
+func (q *reorderQueue) Pop() any {
+	n := len(q.entries)
+	e := q.entries[n-1]
+	q.entries[n-1] = reorderEntry{}
+	q.entries = q.entries[:n-1]
+	return e
+}
+
+// reorderBuffer holds received objects and releases them in group order. An
+// object is released as soon as it directly follows the last released object
+// or is object 0 of the next group.
+// Otherwise, it is held until it was held for longer than window or more than
+// maxObjects objects are held. The buffer then skips ahead to the next held
+// object. Objects arriving after a later object was released are dropped.
+type reorderBuffer struct {
+	window     time.Duration
+	maxObjects int
+
+	// release and drop are called with the lock held.
+	release func(*Object)
+	drop    func(*Object)
+
+	lock    sync.Mutex
+	queue   reorderQueue
+	last    Location // location of the last released object
+	hasLast bool
+	timer   *time.Timer
+	closed  bool
+}
+
+// newReorderBuffer creates a new reorderBuffer. A window or maxObjects of zero
+// disables the respective limit.
+func newReorderBuffer(window time.Duration, maxObjects int, descending bool, release, drop func(*Object)) *reorderBuffer {
+	return &reorderBuffer{
+		window:     window,
+		maxObjects: maxObjects,
+		release:    release,
+		drop:       drop,
+		lock:       sync.Mutex{},
+		queue: reorderQueue{
+			descending: descending,
+			entries:    []reorderEntry{},
+		},
+		last:    Location{},
+		hasLast: false,
+		timer:   nil,
+		closed:  false,
+	}
+}
+
+// setDescending sets the group order in which objects are released.
+func (b *reorderBuffer) setDescending(descending bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.queue.descending == descending {
+		return
+	}
+	b.queue.descending = descending
+	heap.Init(&b.queue)
+}
+
+func (b *reorderBuffer) push(o *Object) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		b.release(o)
+		return
+	}
+	if b.hasLast && !locationBefore(b.last, objectLocation(o), b.queue.descending) {
+		b.drop(o)
+		return
+	}
+	heap.Push(&b.queue, reorderEntry{
+		object:  o,
+		arrival: time.Now(),
+	})
+	b.releaseReady(time.Now())
+}
+
+// next reports whether o directly follows the last released object. The
+// first object is in order, even if it is not the first object of its group,
+// because a subscription does not necessarily start at object 0. The next
+// group is only in order at object 0, because its earlier objects may still
+// arrive on other streams, and groups that do not start at object 0 are
+// released when the window expires. Without a time window, the first object
+// received of the next group is in order, because it would otherwise be held
+// until maxObjects objects are held, which stalls a stream whose last object
+// is still being read.
+func (b *reorderBuffer) next(o *Object) bool {
+	if !b.hasLast {
+		return true
+	}
+	if o.GroupID == b.last.Group {
+		return o.ObjectID == b.last.Object+1
+	}
+	if o.ObjectID != 0 && b.window > 0 {
+		return false
+	}
+	if b.queue.descending {
+		return o.GroupID+1 == b.last.Group
+	}
+	return o.GroupID == b.last.Group+1
+}
+
+// expired reports whether any held object was held for at least the window.
+func (b *reorderBuffer) expired(now time.Time) bool {
+	if b.window <= 0 {
+		return false
+	}
+	for _, e := range b.queue.entries {
+		if now.Sub(e.arrival) >= b.window {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *reorderBuffer) releaseReady(now time.Time) {
+	for b.queue.Len() > 0 {
+		head := b.queue.entries[0].object
+		if !b.next(head) &&
+			(b.maxObjects <= 0 || b.queue.Len() <= b.maxObjects) &&
+			!b.expired(now) {
+			break
+		}
+		heap.Pop(&b.queue)
+		b.last = objectLocation(head)
+		b.hasLast = true
+		b.release(head)
+	}
+	b.armTimer(now)
+}
+
+// armTimer arms the timer to fire when the oldest held object expires.
+func (b *reorderBuffer) armTimer(now time.Time) {
+	if b.window <= 0 || b.queue.Len() == 0 {
+		return
+	}
+	oldest := b.queue.entries[0].arrival
+	for _, e := range b.queue.entries[1:] {
+		if e.arrival.Before(oldest) {
+			oldest = e.arrival
+		}
+	}
+	d := oldest.Add(b.window).Sub(now)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(d, b.onTimer)
+		return
+	}
+	b.timer.Reset(d)
+}
+
+func (b *reorderBuffer) onTimer() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return
+	}
+	b.releaseReady(time.Now())
+}
+
+// flush releases all held objects in order. Objects pushed after flush are
+// released immediately.
+func (b *reorderBuffer) flush() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	for b.queue.Len() > 0 {
+		e := heap.Pop(&b.queue).(reorderEntry)
+		b.release(e.object)
+	}
+}
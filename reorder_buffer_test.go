@@ -0,0 +1,131 @@
+package moqtransport
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	// Objects are identified by groupID<<8|objectID.
+	type result struct {
+		lock     sync.Mutex
+		released []uint64
+		dropped  []uint64
+	}
+	id := func(o *Object) uint64 {
+		return o.GroupID<<8 | o.ObjectID
+	}
+	newBuffer := func(window time.Duration, maxObjects int, descending bool) (*reorderBuffer, *result) {
+		r := &result{}
+		b := newReorderBuffer(window, maxObjects, descending,
+			func(o *Object) {
+				r.lock.Lock()
+				defer r.lock.Unlock()
+				r.released = append(r.released, id(o))
+			},
+			func(o *Object) {
+				r.lock.Lock()
+				defer r.lock.Unlock()
+				r.dropped = append(r.dropped, id(o))
+			},
+		)
+		return b, r
+	}
+	released := func(r *result) []uint64 {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		return append([]uint64{}, r.released...)
+	}
+	push := func(b *reorderBuffer, ids ...uint64) {
+		for _, id := range ids {
+			b.push(&Object{GroupID: id >> 8, ObjectID: id & 0xff})
+		}
+	}
+
+	t.Run("reorders_within_object_window", func(t *testing.T) {
+		b, r := newBuffer(0, 2, false)
+		push(b, 0x000, 0x002)
+		assert.Equal(t, []uint64{0x000}, released(r))
+		push(b, 0x001)
+		assert.Equal(t, []uint64{0x000, 0x001, 0x002}, released(r))
+		push(b, 0x100, 0x102, 0x101)
+		assert.Equal(t, []uint64{0x000, 0x001, 0x002, 0x100, 0x101, 0x102}, released(r))
+	})
+
+	t.Run("first_object_is_in_order", func(t *testing.T) {
+		// The first object does not have to be object 0. Without a time
+		// window, neither does the first object of the next group.
+		b, r := newBuffer(0, 2, false)
+		push(b, 0x105, 0x106, 0x202)
+		assert.Equal(t, []uint64{0x105, 0x106, 0x202}, released(r))
+	})
+
+	t.Run("holds_next_group_until_object_0", func(t *testing.T) {
+		b, r := newBuffer(time.Minute, 0, false)
+		defer b.flush()
+		push(b, 0x000, 0x101)
+		assert.Equal(t, []uint64{0x000}, released(r))
+		// Object 1/0 arrives after 1/1 within the window and is not dropped.
+		push(b, 0x100)
+		assert.Equal(t, []uint64{0x000, 0x100, 0x101}, released(r))
+		assert.Empty(t, r.dropped)
+	})
+
+	t.Run("releases_next_group_without_object_0_when_window_expires", func(t *testing.T) {
+		b, r := newBuffer(20*time.Millisecond, 0, false)
+		defer b.flush()
+		push(b, 0x000, 0x102)
+		assert.Equal(t, []uint64{0x000}, released(r))
+		assert.Eventually(t, func() bool {
+			return len(released(r)) == 2
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []uint64{0x000, 0x102}, released(r))
+	})
+
+	t.Run("skips_ahead_when_object_window_is_full", func(t *testing.T) {
+		b, r := newBuffer(0, 2, false)
+		push(b, 0x000, 0x001, 0x002)
+		push(b, 0x005, 0x201)
+		assert.Equal(t, []uint64{0x000, 0x001, 0x002}, released(r))
+		// Object 0/5 is released when the window is full and object 0/3 is
+		// still missing.
+		push(b, 0x202)
+		assert.Equal(t, []uint64{0x000, 0x001, 0x002, 0x005}, released(r))
+		// Object 0/3 arrives after 0/5 was released and is dropped.
+		push(b, 0x003)
+		assert.Equal(t, []uint64{0x003}, r.dropped)
+	})
+
+	t.Run("descending_group_order", func(t *testing.T) {
+		b, r := newBuffer(0, 3, true)
+		push(b, 0x300, 0x101, 0x100)
+		assert.Equal(t, []uint64{0x300}, released(r))
+		push(b, 0x200)
+		assert.Equal(t, []uint64{0x300, 0x200, 0x100, 0x101}, released(r))
+	})
+
+	t.Run("skips_ahead_when_time_window_expires", func(t *testing.T) {
+		b, r := newBuffer(20*time.Millisecond, 0, false)
+		defer b.flush()
+		push(b, 0x000, 0x002)
+		assert.Equal(t, []uint64{0x000}, released(r))
+		assert.Eventually(t, func() bool {
+			return len(released(r)) == 2
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []uint64{0x000, 0x002}, released(r))
+		push(b, 0x003)
+		assert.Equal(t, []uint64{0x000, 0x002, 0x003}, released(r))
+	})
+
+	t.Run("flush_releases_held_objects", func(t *testing.T) {
+		b, r := newBuffer(time.Minute, 0, false)
+		push(b, 0x000, 0x003, 0x002)
+		b.flush()
+		assert.Equal(t, []uint64{0x000, 0x002, 0x003}, released(r))
+		push(b, 0x001)
+		assert.Equal(t, []uint64{0x000, 0x002, 0x003, 0x001}, released(r))
+	})
+}
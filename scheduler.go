@@ -0,0 +1,217 @@
+package moqtransport
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// schedulerChunkSize is the maximum number of bytes written in one scheduled
+// write. Larger writes are split into chunks so that more important data can
+// preempt large objects.
+const schedulerChunkSize = 16 * 1024
+
+// schedulerStallTimeout is the time after which a write that has not
+// completed gives up its turn. Writes block while a stream is blocked by flow
+// control, for example because the peer does not read, and such a stream must
+// not stall the other streams of the session.
+const schedulerStallTimeout = 5 * time.Millisecond
+
+// sendPriority determines the order in which pending object writes are sent.
+type sendPriority struct {
+	subscriberPriority uint8
+	publisherPriority  uint8
+	groupOrder         GroupOrder
+	groupID            uint64
+}
+
+// before reports whether p should be sent before o. Lower subscriber
+// priorities are sent first, then lower publisher priorities. Ties are broken
+// by group ID: newest group first if both are in descending group order,
+// oldest group first otherwise.
+func (p sendPriority) before(o sendPriority) bool {
+	if p.subscriberPriority != o.subscriberPriority {
+		return p.subscriberPriority < o.subscriberPriority
+	}
+	if p.publisherPriority != o.publisherPriority {
+		return p.publisherPriority < o.publisherPriority
+	}
+	if p.groupOrder == GroupOrderDescending && o.groupOrder == GroupOrderDescending {
+		return p.groupID > o.groupID
+	}
+	return p.groupID < o.groupID
+}
+
+type sendRequest struct {
+	priority sendPriority
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+type sendQueue []*sendRequest
+
+func (q sendQueue) Len() int {
+	return len(q)
+}
+
+func (q sendQueue) Less(i, j int) bool {
+	if q[i].priority == q[j].priority {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].priority.before(q[j].priority)
+}
+
+func (q sendQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *sendQueue) Push(x any) {
+	r := x.(*sendRequest)
+	r.index = len(*q)
+	*q = append(*q, r)
+}
+
+func (q *sendQueue) Pop() any {
+	old := *q
+	n := len(old)
+	r := old[n-1]
+	old[n-1] = nil
+	r.index = -1
+	*q = old[:n-1]
+	return r
+}
+
+// sendScheduler orders object writes of all local tracks of a session and
+// grants pending writes in priority order. A granted write keeps its turn
+// while it makes progress. Because writes to a stream block while congestion
+// control prevents sending, data queued at the scheduler is sent in priority
+// order when bandwidth is limited. A write that does not complete within
+// schedulerStallTimeout releases its turn and continues concurrently with the
+// next write, so that a stream blocked by flow control does not hold back the
+// other streams.
+type sendScheduler struct {
+	lock    sync.Mutex
+	busy    bool
+	seq     uint64
+	pending sendQueue
+}
+
+func newSendScheduler() *sendScheduler {
+	return &sendScheduler{
+		lock:    sync.Mutex{},
+		busy:    false,
+		seq:     0,
+		pending: sendQueue{},
+	}
+}
+
+// acquire blocks until the caller may send data with priority p or ctx is
+// done. Callers must call release after sending.
+func (s *sendScheduler) acquire(ctx context.Context, p sendPriority) error {
+	s.lock.Lock()
+	if !s.busy {
+		s.busy = true
+		s.lock.Unlock()
+		return nil
+	}
+	r := &sendRequest{
+		priority: p,
+		seq:      s.seq,
+		ready:    make(chan struct{}),
+		index:    -1,
+	}
+	s.seq++
+	heap.Push(&s.pending, r)
+	s.lock.Unlock()
+
+	select {
+	case <-r.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.index < 0 {
+		// The request was granted concurrently, pass it on.
+		s.releaseLocked()
+	} else {
+		heap.Remove(&s.pending, r.index)
+	}
+	return context.Cause(ctx)
+}
+
+// hold runs f after the caller acquired the right to send. The right is
+// passed on when f returns or after schedulerStallTimeout, whichever happens
+// first.
+func (s *sendScheduler) hold(f func() error) error {
+	var once sync.Once
+	release := func() {
+		once.Do(s.release)
+	}
+	stall := time.AfterFunc(schedulerStallTimeout, release)
+	err := f()
+	stall.Stop()
+	release()
+	return err
+}
+
+// release passes the right to send to the most important pending write.
+func (s *sendScheduler) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.releaseLocked()
+}
+
+func (s *sendScheduler) releaseLocked() {
+	if s.pending.Len() == 0 {
+		s.busy = false
+		return
+	}
+	r := heap.Pop(&s.pending).(*sendRequest)
+	close(r.ready)
+}
+
+// write writes buf to w with priority p. Large buffers are written in chunks,
+// each of which is scheduled separately. If s is nil, buf is written
+// directly.
+func (s *sendScheduler) write(ctx context.Context, p sendPriority, w io.Writer, buf []byte) (int, error) {
+	if s == nil {
+		return w.Write(buf)
+	}
+	written := 0
+	for len(buf) > 0 {
+		chunk := buf[:min(len(buf), schedulerChunkSize)]
+		if err := s.acquire(ctx, p); err != nil {
+			return written, err
+		}
+		var n int
+		err := s.hold(func() error {
+			var err error
+			n, err = w.Write(chunk)
+			return err
+		})
+		written += n
+		if err != nil {
+			return written, err
+		}
+		buf = buf[len(chunk):]
+	}
+	return written, nil
+}
+
+// do runs f with priority p. If s is nil, f is run directly.
+func (s *sendScheduler) do(ctx context.Context, p sendPriority, f func() error) error {
+	if s == nil {
+		return f()
+	}
+	if err := s.acquire(ctx, p); err != nil {
+		return err
+	}
+	return s.hold(f)
+}
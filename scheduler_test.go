@@ -0,0 +1,156 @@
+package moqtransport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendPriority(t *testing.T) {
+	cases := []struct {
+		a      sendPriority
+		b      sendPriority
+		before bool
+	}{
+		{
+			a:      sendPriority{subscriberPriority: 1, publisherPriority: 200, groupID: 10},
+			b:      sendPriority{subscriberPriority: 2, publisherPriority: 0, groupID: 0},
+			before: true,
+		},
+		{
+			a:      sendPriority{subscriberPriority: 1, publisherPriority: 2, groupID: 0},
+			b:      sendPriority{subscriberPriority: 1, publisherPriority: 1, groupID: 10},
+			before: false,
+		},
+		{
+			a:      sendPriority{groupOrder: GroupOrderAscending, groupID: 1},
+			b:      sendPriority{groupOrder: GroupOrderAscending, groupID: 2},
+			before: true,
+		},
+		{
+			a:      sendPriority{groupOrder: GroupOrderDescending, groupID: 1},
+			b:      sendPriority{groupOrder: GroupOrderDescending, groupID: 2},
+			before: false,
+		},
+		{
+			a:      sendPriority{groupOrder: GroupOrderDescending, groupID: 3},
+			b:      sendPriority{groupOrder: GroupOrderDescending, groupID: 2},
+			before: true,
+		},
+		{
+			a:      sendPriority{groupOrder: GroupOrderDescending, groupID: 2},
+			b:      sendPriority{groupOrder: GroupOrderAscending, groupID: 3},
+			before: true,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			assert.Equal(t, tc.before, tc.a.before(tc.b))
+		})
+	}
+}
+
+func TestSendScheduler(t *testing.T) {
+	waitPending := func(t *testing.T, s *sendScheduler, n int) {
+		assert.Eventually(t, func() bool {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			return s.pending.Len() == n
+		}, time.Second, time.Millisecond)
+	}
+
+	t.Run("grants_in_priority_order", func(t *testing.T) {
+		s := newSendScheduler()
+		assert.NoError(t, s.acquire(context.Background(), sendPriority{}))
+
+		priorities := []sendPriority{
+			{subscriberPriority: 128, publisherPriority: 1, groupOrder: GroupOrderDescending, groupID: 1},
+			{subscriberPriority: 128, publisherPriority: 1, groupOrder: GroupOrderDescending, groupID: 5},
+			{subscriberPriority: 10, publisherPriority: 9, groupOrder: GroupOrderAscending, groupID: 0},
+			{subscriberPriority: 128, publisherPriority: 0, groupOrder: GroupOrderAscending, groupID: 0},
+		}
+		var lock sync.Mutex
+		order := []int{}
+		var wg sync.WaitGroup
+		for i, p := range priorities {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, s.acquire(context.Background(), p))
+				lock.Lock()
+				order = append(order, i)
+				lock.Unlock()
+				s.release()
+			}()
+			waitPending(t, s, i+1)
+		}
+		s.release()
+		wg.Wait()
+		assert.Equal(t, []int{2, 3, 1, 0}, order)
+		assert.False(t, s.busy)
+	})
+
+	t.Run("cancel_pending", func(t *testing.T) {
+		s := newSendScheduler()
+		assert.NoError(t, s.acquire(context.Background(), sendPriority{}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error)
+		go func() {
+			errCh <- s.acquire(ctx, sendPriority{})
+		}()
+		waitPending(t, s, 1)
+		cancel()
+		assert.ErrorIs(t, <-errCh, context.Canceled)
+		waitPending(t, s, 0)
+		s.release()
+		assert.False(t, s.busy)
+	})
+
+	t.Run("stalled_write_yields", func(t *testing.T) {
+		s := newSendScheduler()
+		unblock := make(chan struct{})
+		stalled := make(chan error)
+		go func() {
+			_, err := s.write(context.Background(), sendPriority{}, blockingWriter(unblock), []byte("stalled"))
+			stalled <- err
+		}()
+
+		// A write blocked by flow control does not hold back other writes.
+		var buf bytes.Buffer
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := s.write(ctx, sendPriority{subscriberPriority: 255}, &buf, []byte("other"))
+		assert.NoError(t, err)
+		assert.Equal(t, "other", buf.String())
+
+		close(unblock)
+		assert.NoError(t, <-stalled)
+		assert.False(t, s.busy)
+	})
+
+	t.Run("writes_in_chunks", func(t *testing.T) {
+		s := newSendScheduler()
+		var buf bytes.Buffer
+		data := make([]byte, 3*schedulerChunkSize+1)
+		n, err := s.write(context.Background(), sendPriority{}, &buf, data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.Equal(t, data, buf.Bytes())
+		assert.False(t, s.busy)
+	})
+}
+
+// blockingWriter is an io.Writer whose writes block until the channel is
+// closed.
+type blockingWriter chan struct{}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w
+	return len(p), nil
+}
@@ -0,0 +1,92 @@
+package moqtransport
+
+import (
+	"context"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// TrackObject is an object received on a RemoteTrack.
+type TrackObject struct {
+	Track  *RemoteTrack
+	Object *Object
+}
+
+// Select returns an iterator over the objects received on tracks. Objects of
+// different tracks are interleaved in the order they become available, taking
+// turns between tracks with buffered objects. A track is removed from the
+// selection after all of its objects were read and the subscription was ended
+// by SUBSCRIBE_DONE or the fetch completed. If a track ends with any other
+// error, the error is yielded together with a TrackObject holding only the
+// track and the iteration continues with the remaining tracks. The iteration
+// ends when all tracks have ended or after yielding the error of ctx.
+//
+// Select does not start goroutines. Objects not yet yielded when the
+// iteration is stopped remain buffered in their tracks.
+func Select(ctx context.Context, tracks ...*RemoteTrack) iter.Seq2[TrackObject, error] {
+	return func(yield func(TrackObject, error) bool) {
+		active := slices.Clone(tracks)
+		next := 0
+		for len(active) > 0 {
+			progress := false
+			for i := range active {
+				idx := (next + i) % len(active)
+				t := active[idx]
+				// Check whether the track ended before popping, so that objects
+				// pushed before the end are not missed.
+				ended := t.doneCtx.Err() != nil
+				if o, ok := t.buffer.pop(); ok {
+					next = idx + 1
+					progress = true
+					if !yield(TrackObject{Track: t, Object: o}, nil) {
+						return
+					}
+					break
+				}
+				if !ended {
+					continue
+				}
+				active = slices.Delete(active, idx, idx+1)
+				next = idx
+				progress = true
+				if err := context.Cause(t.doneCtx); !isTrackEnd(err) {
+					if !yield(TrackObject{Track: t}, err) {
+						return
+					}
+				}
+				break
+			}
+			if progress {
+				continue
+			}
+			if err := waitAny(ctx, active); err != nil {
+				yield(TrackObject{}, err)
+				return
+			}
+		}
+	}
+}
+
+// waitAny blocks until any of tracks receives an object or ends, or ctx is
+// done.
+func waitAny(ctx context.Context, tracks []*RemoteTrack) error {
+	cases := make([]reflect.SelectCase, 0, 1+2*len(tracks))
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	})
+	for _, t := range tracks {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(t.buffer.notEmpty),
+		}, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(t.doneCtx.Done()),
+		})
+	}
+	if chosen, _, _ := reflect.Select(cases); chosen == 0 {
+		return context.Cause(ctx)
+	}
+	return nil
+}
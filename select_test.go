@@ -0,0 +1,72 @@
+package moqtransport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	t.Run("ends_after_all_tracks", func(t *testing.T) {
+		rt1 := newRemoteTrack(0, nil, nil)
+		rt2 := newRemoteTrack(1, nil, nil)
+		rt1.push(false, &Object{ObjectID: 1})
+		rt2.push(false, &Object{ObjectID: 2})
+		rt1.done(SubscribeStatusTrackEnded, "", 0)
+		rt2.finish(errors.New("reset"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		objects := []uint64{}
+		errs := []error{}
+		for to, err := range Select(ctx, rt1, rt2) {
+			if err != nil {
+				assert.Equal(t, rt2, to.Track)
+				errs = append(errs, err)
+				continue
+			}
+			objects = append(objects, to.Object.ObjectID)
+		}
+		assert.Equal(t, []uint64{1, 2}, objects)
+		assert.Equal(t, []error{errors.New("reset")}, errs)
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("waits_for_objects", func(t *testing.T) {
+		rt1 := newRemoteTrack(0, nil, nil)
+		rt2 := newRemoteTrack(1, nil, nil)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			rt2.push(false, &Object{ObjectID: 2})
+			rt1.finish(io.EOF)
+			rt2.finish(io.EOF)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		count := 0
+		for to, err := range Select(ctx, rt1, rt2) {
+			assert.NoError(t, err)
+			assert.Equal(t, rt2, to.Track)
+			count++
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("context_done", func(t *testing.T) {
+		rt := newRemoteTrack(0, nil, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		count := 0
+		for to, err := range Select(ctx, rt) {
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Nil(t, to.Track)
+			count++
+		}
+		assert.Equal(t, 1, count)
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"iter"
 	"log/slog"
+	"math"
 	"sync/atomic"
+	"time"
 
 	"github.com/mengelbart/moqtransport/internal/slices"
 	"github.com/mengelbart/moqtransport/internal/wire"
@@ -14,6 +16,10 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// ErrSessionClosed is returned by the RemoteTracks of a session after the
+// session was closed or its connection failed.
+var ErrSessionClosed = errors.New("session closed")
+
 var (
 	errUnknownAnnouncementNamespace     = errors.New("unknown announcement namespace")
 	errMaxRequestIDViolated             = errors.New("max request ID violated")
@@ -27,6 +33,7 @@ var (
 	errMissingPathParameter             = errors.New("missing path parameter")
 	errUnexpectedPathParameter          = errors.New("unexpected path parameter on QUIC connection")
 	errUnknownTrackStatusRequest        = errors.New("got unexpected track status requrest")
+	errInvalidJoiningSubscription       = errors.New("joining fetch requires an active subscription of the session")
 )
 
 type controlMessageStream interface {
@@ -38,6 +45,7 @@ type objectMessageParser interface {
 	Type() wire.StreamType
 	Identifier() uint64
 	Messages() iter.Seq2[*wire.ObjectMessage, error]
+	SetPayloadStreamingThreshold(uint64)
 }
 
 // A Session is an endpoint of a MoQ Session session.
@@ -54,6 +62,15 @@ type Session struct {
 	// SubscribeUpdateHandler is Handler for SubscribeUpdate messages
 	SubscribeUpdateHandler SubscribeUpdateHandler
 
+	// FetchHandler is Handler for Fetch messages. If FetchHandler is nil,
+	// Fetch messages are passed to Handler.
+	FetchHandler FetchHandler
+
+	// ObjectStore holds the objects of published tracks. The FetchHandler or
+	// Handler can answer FETCH requests with the stored objects by calling
+	// AcceptFromStore on the FetchResponseWriter.
+	ObjectStore ObjectStore
+
 	// QLOG Logger
 	Qlogger *qlog.Logger
 
@@ -86,6 +103,8 @@ type Session struct {
 	remoteTracks *remoteTrackMap
 	localTracks  *localTrackMap
 
+	scheduler *sendScheduler
+
 	outgoingTrackStatusRequests *trackStatusRequestMap
 }
 
@@ -119,6 +138,7 @@ func (s *Session) Run(conn Connection) error {
 	s.trackAliases = newSequence(0, 1)
 	s.remoteTracks = newRemoteTrackMap()
 	s.localTracks = newLocalTrackMap()
+	s.scheduler = newSendScheduler()
 	s.outgoingTrackStatusRequests = newTrackStatusRequestMap()
 	s.controlStream = &controlStream{
 		stream:  cs,
@@ -129,6 +149,11 @@ func (s *Session) Run(conn Connection) error {
 	s.eg.Go(s.readControlStream)
 	s.eg.Go(func() error { return s.readStreams(s.ctx) })
 	s.eg.Go(func() error { return s.readDatagrams(s.ctx) })
+	s.eg.Go(func() error {
+		<-s.ctx.Done()
+		s.remoteTracks.closeAll(ErrSessionClosed)
+		return nil
+	})
 
 	if s.conn.Perspective() == PerspectiveClient {
 		if err := s.sendClientSetup(); err != nil {
@@ -178,7 +203,7 @@ func (s *Session) readStreams(ctx context.Context) error {
 				return
 			}
 			s.logger.Debug("parsed object stream header")
-			if err := s.handleUniStream(parser); err != nil {
+			if err := s.handleUniStream(stream, parser); err != nil {
 				return
 			}
 		}()
@@ -196,25 +221,18 @@ func (s *Session) readDatagrams(ctx context.Context) error {
 			return err
 		}
 		if s.Qlogger != nil {
-			eth := slices.Collect(slices.Map(
-				msg.ObjectExtensionHeaders,
-				func(e wire.KeyValuePair) moqt.ExtensionHeader {
-					return moqt.ExtensionHeader{
-						HeaderType:   0, // TODO
-						HeaderValue:  0, // TODO
-						HeaderLength: 0, // TODO
-						Payload:      qlog.RawInfo{},
-					}
-				}),
-			)
+			name := moqt.ObjectDatagramEventparsed
+			if msg.ObjectStatus != wire.ObjectStatusNormal {
+				name = moqt.ObjectDatagramStatusEventparsed
+			}
 			s.Qlogger.Log(moqt.ObjectDatagramEvent{
-				EventName:              moqt.ObjectDatagramEventparsed,
+				EventName:              name,
 				TrackAlias:             msg.TrackAlias,
 				GroupID:                msg.GroupID,
 				ObjectID:               msg.ObjectID,
 				PublisherPriority:      msg.PublisherPriority,
-				ExtensionHeadersLength: uint64(len(msg.ObjectExtensionHeaders)),
-				ExtensionHeaders:       eth,
+				ExtensionHeadersLength: msg.ObjectExtensionHeaders.EncodedLength(),
+				ExtensionHeaders:       msg.ObjectExtensionHeaders.QlogExtensionHeaders(),
 				ObjectStatus:           uint64(msg.ObjectStatus),
 				Payload: qlog.RawInfo{
 					Length:        uint64(len(msg.ObjectPayload)),
@@ -229,11 +247,11 @@ func (s *Session) readDatagrams(ctx context.Context) error {
 	}
 }
 
-func (s *Session) handleUniStream(parser objectMessageParser) error {
+func (s *Session) handleUniStream(stream ReceiveStream, parser objectMessageParser) error {
 	if parser.Type() == wire.StreamTypeFetch {
 		return s.readFetchStream(parser)
 	}
-	return s.readSubgroupStream(parser)
+	return s.readSubgroupStream(stream, parser)
 }
 
 func (s *Session) readFetchStream(parser objectMessageParser) error {
@@ -245,13 +263,13 @@ func (s *Session) readFetchStream(parser objectMessageParser) error {
 	return rt.readFetchStream(parser)
 }
 
-func (s *Session) readSubgroupStream(parser objectMessageParser) error {
+func (s *Session) readSubgroupStream(stream ReceiveStream, parser objectMessageParser) error {
 	s.logger.Info("reading subgroup")
 	rt, ok := s.remoteTrackByTrackAlias(parser.Identifier())
 	if !ok {
 		return errUnknownRequestID
 	}
-	return rt.readSubgroupStream(parser)
+	return rt.readSubgroupStream(stream, parser)
 }
 
 func (s *Session) receiveDatagram(msg *wire.ObjectDatagramMessage) error {
@@ -259,10 +277,16 @@ func (s *Session) receiveDatagram(msg *wire.ObjectDatagramMessage) error {
 	if !ok {
 		return errUnknownTrackAlias
 	}
-	subscription.push(&Object{
+	// Datagrams don't carry a subgroup ID, each datagram object forms its own
+	// subgroup identified by the object ID.
+	subscription.pushDatagram(&Object{
 		GroupID:              msg.GroupID,
+		SubGroupID:           msg.ObjectID,
 		ObjectID:             msg.ObjectID,
-		ForwardingPreference: ObjectForwardingPreferenceDatagarm,
+		ForwardingPreference: ObjectForwardingPreferenceDatagram,
+		PublisherPriority:    msg.PublisherPriority,
+		Status:               msg.ObjectStatus,
+		ExtensionHeaders:     objectExtensionHeaders(msg.ObjectExtensionHeaders),
 		Payload:              msg.ObjectPayload,
 	})
 	return nil
@@ -393,6 +417,90 @@ func WithAuthorizationToken(token string) SubscribeOption {
 	}
 }
 
+// WithDeliveryTimeout sets the delivery timeout parameter for the
+// subscription. The publisher resets streams carrying objects that could not
+// be delivered within the timeout and the subscriber stops reading stale
+// streams. A timeout of 0 disables the timeout (default).
+func WithDeliveryTimeout(timeout time.Duration) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.Parameters = opts.Parameters.withDeliveryTimeout(timeout)
+	}
+}
+
+// WithBufferCount sets the maximum number of received objects buffered by the
+// RemoteTrack until they are read. Zero or less means no limit. Default is 100.
+func WithBufferCount(count int) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.BufferCount = count
+	}
+}
+
+// WithBufferBytes sets the maximum total payload size of received objects
+// buffered by the RemoteTrack until they are read. Zero or less means no
+// limit. Default is no limit.
+func WithBufferBytes(bytes int) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.BufferBytes = bytes
+	}
+}
+
+// WithOverflowPolicy sets how the RemoteTrack handles received objects that
+// don't fit into the buffer. Default is OverflowPolicyDropNewest.
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.OverflowPolicy = policy
+	}
+}
+
+// WithSubgroupReaders sets whether objects received on subgroup streams are
+// returned per subgroup by RemoteTrack.AcceptSubgroup instead of merged into
+// RemoteTrack.ReadObject. Default is false.
+func WithSubgroupReaders(enabled bool) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.SubgroupReaders = enabled
+	}
+}
+
+// WithPayloadStreaming sets the payload size in bytes above which the
+// payloads of objects received on subgroup streams are not buffered in memory
+// but streamed. The payload of such objects must be read using
+// Object.PayloadReader. Zero disables streaming. Default is 0.
+//
+// Payload streaming is only available for subscriptions. Objects received on
+// fetch streams and datagrams are always delivered with their full payload.
+func WithPayloadStreaming(threshold uint64) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.PayloadStreamingThreshold = threshold
+	}
+}
+
+// WithReorderWindow enables ordered delivery of objects. Objects are returned
+// by RemoteTrack.ReadObject in the group order of the subscription and in
+// ascending object order within a group. An object is held until all objects
+// before it were received, for at most window or until more than maxObjects
+// objects are held. The first received object is not held. The next group
+// starts at its object 0, or, without a window, at its first received object.
+// Delivery then skips ahead to the next held object and objects that arrive
+// later than objects already returned are dropped. A
+// window or maxObjects of zero disables the respective limit. If both are zero,
+// objects are returned in arrival order. Objects released from the reorder
+// window are never blocked by OverflowPolicyBlock. Default is 0, 0.
+func WithReorderWindow(window time.Duration, maxObjects int) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.ReorderWindow = window
+		opts.ReorderWindowObjects = maxObjects
+	}
+}
+
+// WithGapHandler sets a function that is called for each gap detected on the
+// subscription. The handler is called from the goroutine receiving the objects
+// and must not block.
+func WithGapHandler(handler func(Gap)) SubscribeOption {
+	return func(opts *SubscribeOptions) {
+		opts.GapHandler = handler
+	}
+}
+
 // WithSubscribeParameters sets additional key-value parameters for the subscription.
 // This replaces any existing parameters.
 func WithSubscribeParameters(parameters KVPList) SubscribeOption {
@@ -438,6 +546,14 @@ func WithUpdateForward(forward bool) SubscribeUpdateOption {
 	}
 }
 
+// WithUpdateDeliveryTimeout sets the new delivery timeout for the
+// subscription update.
+func WithUpdateDeliveryTimeout(timeout time.Duration) SubscribeUpdateOption {
+	return func(opts *SubscribeUpdateOptions) {
+		opts.Parameters = opts.Parameters.withDeliveryTimeout(timeout)
+	}
+}
+
 // WithUpdateParameters sets additional key-value parameters for the subscription update.
 // This replaces any existing parameters.
 func WithUpdateParameters(parameters KVPList) SubscribeUpdateOption {
@@ -446,6 +562,67 @@ func WithUpdateParameters(parameters KVPList) SubscribeUpdateOption {
 	}
 }
 
+// FetchOption is a functional option for configuring Fetch requests.
+type FetchOption func(*FetchOptions)
+
+// WithFetchSubscriberPriority sets the delivery priority for the fetch.
+// Default is 0.
+func WithFetchSubscriberPriority(priority uint8) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.SubscriberPriority = priority
+	}
+}
+
+// WithFetchGroupOrder sets the order in which the groups of the fetch are
+// delivered. Default is GroupOrderNone, which uses the order of the
+// publisher.
+func WithFetchGroupOrder(groupOrder GroupOrder) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.GroupOrder = groupOrder
+	}
+}
+
+// WithFetchRange sets the range of objects to fetch from start to the object
+// before endObject in endGroup. An endObject of zero fetches all objects of
+// endGroup. Default is all objects of group 0.
+func WithFetchRange(start Location, endGroup, endObject uint64) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.StartLocation = start
+		opts.EndGroup = endGroup
+		opts.EndObject = endObject
+	}
+}
+
+// WithFetchAuthorizationToken sets the authorization token for the fetch.
+// This is a convenience method that adds the authorization token to
+// parameters.
+func WithFetchAuthorizationToken(token string) FetchOption {
+	return func(opts *FetchOptions) {
+		if len(token) > 0 {
+			// Replace existing auth token or add new one
+			for i, param := range opts.Parameters {
+				if param.Type == wire.AuthorizationTokenParameterKey {
+					opts.Parameters[i].ValueBytes = []byte(token)
+					return
+				}
+			}
+			// Add new auth token
+			opts.Parameters = append(opts.Parameters, KeyValuePair{
+				Type:       wire.AuthorizationTokenParameterKey,
+				ValueBytes: []byte(token),
+			})
+		}
+	}
+}
+
+// WithFetchParameters sets additional key-value parameters for the fetch.
+// This replaces any existing parameters.
+func WithFetchParameters(parameters KVPList) FetchOption {
+	return func(opts *FetchOptions) {
+		opts.Parameters = parameters
+	}
+}
+
 // Session message senders
 
 func (s *Session) sendClientSetup() error {
@@ -479,6 +656,14 @@ func (s *Session) sendClientSetup() error {
 //   - StartLocation: Location{Group: 0, Object: 0}
 //   - EndGroup: 0
 //   - Parameters: empty
+//   - BufferCount: 100
+//   - BufferBytes: 0 (no limit)
+//   - OverflowPolicy: OverflowPolicyDropNewest
+//   - SubgroupReaders: false
+//   - PayloadStreamingThreshold: 0
+//   - ReorderWindow: 0
+//   - ReorderWindowObjects: 0
+//   - GapHandler: nil
 //
 // Use WithAuthorizationToken(auth) to add authorization.
 // Note: auth should not be a simple string, but a structured object containing
@@ -494,15 +679,6 @@ func (s *Session) Subscribe(
 	if err != nil {
 		return nil, err
 	}
-	rt := newRemoteTrack(requestID, func() error {
-		return s.unsubscribe(requestID)
-	}, func(ctx context.Context, options ...SubscribeUpdateOption) error {
-		return s.UpdateSubscription(ctx, requestID, options...)
-	})
-	trackAlias := s.trackAliases.next()
-	if err = s.remoteTracks.addPendingWithAlias(requestID, trackAlias, rt); err != nil {
-		return nil, err
-	}
 
 	// Set default values
 	opts := &SubscribeOptions{
@@ -513,12 +689,42 @@ func (s *Session) Subscribe(
 		StartLocation:      Location{Group: 0, Object: 0},
 		EndGroup:           0,
 		Parameters:         KVPList{},
+		BufferCount:        defaultBufferCount,
+		BufferBytes:        0,
+		OverflowPolicy:     OverflowPolicyDropNewest,
+		SubgroupReaders:    false,
+
+		PayloadStreamingThreshold: 0,
+		ReorderWindow:             0,
+		ReorderWindowObjects:      0,
+		GapHandler:                nil,
 	}
 
 	// Apply options
 	for _, option := range options {
 		option(opts)
 	}
+	rt := newRemoteTrack(requestID, func() error {
+		return s.unsubscribe(requestID)
+	}, func(ctx context.Context, options ...SubscribeUpdateOption) error {
+		return s.UpdateSubscription(ctx, requestID, options...)
+	})
+	rt.buffer = newObjectBuffer(opts.BufferCount, opts.BufferBytes, opts.OverflowPolicy)
+	if opts.SubgroupReaders {
+		rt.subgroups = make(chan *RemoteSubgroup, defaultBufferCount)
+	}
+	rt.payloadStreamingThreshold = opts.PayloadStreamingThreshold
+	rt.gaps = newGapDetector(opts.GapHandler)
+	if opts.ReorderWindow > 0 || opts.ReorderWindowObjects > 0 {
+		rt.enableReorder(opts.ReorderWindow, opts.ReorderWindowObjects, opts.GroupOrder)
+	}
+	if timeout, ok := opts.Parameters.GetDeliveryTimeout(); ok {
+		rt.subscriberDeliveryTimeout.Store(int64(timeout))
+	}
+	trackAlias := s.trackAliases.next()
+	if err = s.remoteTracks.addPendingWithAlias(requestID, trackAlias, rt); err != nil {
+		return nil, err
+	}
 
 	cm := &wire.SubscribeMessage{
 		RequestID:          requestID,
@@ -560,7 +766,8 @@ func (s *Session) Subscribe(
 //   - Parameters: empty
 func (s *Session) UpdateSubscription(ctx context.Context, requestID uint64, options ...SubscribeUpdateOption) error {
 	// Validate that the subscription exists
-	if _, exists := s.remoteTracks.findByRequestID(requestID); !exists {
+	rt, exists := s.remoteTracks.findByRequestID(requestID)
+	if !exists {
 		return errUnknownRequestID
 	}
 
@@ -580,6 +787,9 @@ func (s *Session) UpdateSubscription(ctx context.Context, requestID uint64, opti
 	for _, option := range options {
 		option(opts)
 	}
+	if timeout, ok := opts.Parameters.GetDeliveryTimeout(); ok {
+		rt.subscriberDeliveryTimeout.Store(int64(timeout))
+	}
 
 	// Create and send SUBSCRIBE_UPDATE message
 	cm := &wire.SubscribeUpdateMessage{
@@ -596,7 +806,7 @@ func (s *Session) UpdateSubscription(ctx context.Context, requestID uint64, opti
 
 // acceptSubscriptionWithOptions accepts a subscription with relevant options.
 func (s *Session) acceptSubscriptionWithOptions(id uint64, opts *SubscribeOkOptions) error {
-	_, ok := s.localTracks.confirm(id)
+	lt, ok := s.localTracks.confirm(id)
 	if !ok {
 		return errUnknownRequestID
 	}
@@ -612,6 +822,11 @@ func (s *Session) acceptSubscriptionWithOptions(id uint64, opts *SubscribeOkOpti
 		}
 	}
 
+	lt.setGroupOrder(opts.GroupOrder)
+	if timeout, ok := opts.Parameters.GetDeliveryTimeout(); ok {
+		lt.publisherDeliveryTimeout.Store(int64(timeout))
+	}
+
 	msg := &wire.SubscribeOkMessage{
 		RequestID:     id,
 		Expires:       opts.Expires,
@@ -623,6 +838,8 @@ func (s *Session) acceptSubscriptionWithOptions(id uint64, opts *SubscribeOkOpti
 	// Set largest location if content exists and location is provided
 	if opts.ContentExists && opts.LargestLocation != nil {
 		msg.LargestLocation = *opts.LargestLocation
+		largest := *opts.LargestLocation
+		lt.largest.Store(&largest)
 	}
 
 	return s.controlStream.write(msg)
@@ -666,7 +883,110 @@ func (s *Session) Fetch(
 	ctx context.Context,
 	namespace []string,
 	track string,
+	options ...FetchOption,
+) (*RemoteTrack, error) {
+	opts := &FetchOptions{
+		SubscriberPriority: 0,
+		GroupOrder:         GroupOrderNone,
+		StartLocation:      Location{Group: 0, Object: 0},
+		EndGroup:           0,
+		EndObject:          0,
+		Parameters:         KVPList{},
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return s.fetch(ctx, &wire.FetchMessage{
+		RequestID:          0,
+		SubscriberPriority: opts.SubscriberPriority,
+		GroupOrder:         uint8(opts.GroupOrder),
+		FetchType:          wire.FetchTypeStandalone,
+		TrackNamespace:     namespace,
+		TrackName:          []byte(track),
+		StartGroup:         opts.StartLocation.Group,
+		StartObject:        opts.StartLocation.Object,
+		EndGroup:           opts.EndGroup,
+		EndObject:          opts.EndObject,
+		JoiningSubscribeID: 0,
+		JoiningStart:       0,
+		Parameters:         opts.Parameters.ToWire(),
+	})
+}
+
+// JoiningStart determines the first group of a joining fetch.
+type JoiningStart struct {
+	absolute bool
+	value    uint64
+}
+
+// JoiningStartRelative starts a joining fetch precedingGroups groups before
+// the largest group of the joined subscription.
+func JoiningStartRelative(precedingGroups uint64) JoiningStart {
+	return JoiningStart{
+		absolute: false,
+		value:    precedingGroups,
+	}
+}
+
+// JoiningStartAbsolute starts a joining fetch at group.
+func JoiningStartAbsolute(group uint64) JoiningStart {
+	return JoiningStart{
+		absolute: true,
+		value:    group,
+	}
+}
+
+// JoiningFetch fetches the objects of the track subscribed by rt from start
+// up to the largest location the publisher reported when it accepted the
+// subscription. The publisher resolves the range, so that a new subscriber
+// can fetch the groups before the live edge without knowing the largest
+// location. rt must be an active subscription of s. WithFetchRange is
+// ignored. JoiningFetch blocks until a response from the peer was received
+// or ctx is cancelled.
+func (s *Session) JoiningFetch(
+	ctx context.Context,
+	rt *RemoteTrack,
+	start JoiningStart,
+	options ...FetchOption,
 ) (*RemoteTrack, error) {
+	joined, ok := s.remoteTracks.findByRequestID(rt.RequestID())
+	if !ok || joined != rt || rt.updateFunc == nil {
+		return nil, errInvalidJoiningSubscription
+	}
+	opts := &FetchOptions{
+		SubscriberPriority: 0,
+		GroupOrder:         GroupOrderNone,
+		StartLocation:      Location{Group: 0, Object: 0},
+		EndGroup:           0,
+		EndObject:          0,
+		Parameters:         KVPList{},
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	fetchType := uint64(wire.FetchTypeRelativeJoining)
+	if start.absolute {
+		fetchType = wire.FetchTypeAbsoluteJoining
+	}
+	return s.fetch(ctx, &wire.FetchMessage{
+		RequestID:          0,
+		SubscriberPriority: opts.SubscriberPriority,
+		GroupOrder:         uint8(opts.GroupOrder),
+		FetchType:          fetchType,
+		TrackNamespace:     nil,
+		TrackName:          nil,
+		StartGroup:         0,
+		StartObject:        0,
+		EndGroup:           0,
+		EndObject:          0,
+		JoiningSubscribeID: rt.RequestID(),
+		JoiningStart:       start.value,
+		Parameters:         opts.Parameters.ToWire(),
+	})
+}
+
+// fetch sends cm with a new request ID and waits for the response.
+func (s *Session) fetch(ctx context.Context, cm *wire.FetchMessage) (*RemoteTrack, error) {
 	requestID, err := s.getRequestID()
 	if err != nil {
 		return nil, err
@@ -688,21 +1008,7 @@ func (s *Session) Fetch(
 		}
 		return nil, err
 	}
-	cm := &wire.FetchMessage{
-		RequestID:          requestID,
-		SubscriberPriority: 0,
-		GroupOrder:         0,
-		FetchType:          wire.FetchTypeStandalone,
-		TrackNamespace:     namespace,
-		TrackName:          []byte(track),
-		StartGroup:         0,
-		StartObject:        0,
-		EndGroup:           0,
-		EndObject:          0,
-		JoiningSubscribeID: 0,
-		JoiningStart:       0,
-		Parameters:         wire.KVPList{},
-	}
+	cm.RequestID = requestID
 	if err = s.controlStream.write(cm); err != nil {
 		_, _ = s.remoteTracks.reject(requestID)
 		return nil, err
@@ -722,20 +1028,25 @@ func (s *Session) Fetch(
 	return rt, nil
 }
 
-func (s *Session) acceptFetch(requestID uint64) error {
-	_, ok := s.localTracks.confirm(requestID)
+func (s *Session) acceptFetchWithOptions(requestID uint64, opts *FetchOkOptions) error {
+	lt, ok := s.localTracks.confirm(requestID)
 	if !ok {
 		return errUnknownRequestID
 	}
+	lt.setGroupOrder(opts.GroupOrder)
+	if timeout, ok := opts.Parameters.GetDeliveryTimeout(); ok {
+		lt.publisherDeliveryTimeout.Store(int64(timeout))
+	}
+	var endOfTrack uint8
+	if opts.EndOfTrack {
+		endOfTrack = 1
+	}
 	return s.controlStream.write(&wire.FetchOkMessage{
-		RequestID:  requestID,
-		GroupOrder: 1,
-		EndOfTrack: 0,
-		EndLocation: wire.Location{
-			Group:  0,
-			Object: 0,
-		},
-		SubscribeParameters: wire.KVPList{},
+		RequestID:           requestID,
+		GroupOrder:          uint8(opts.GroupOrder),
+		EndOfTrack:          endOfTrack,
+		EndLocation:         opts.EndLocation,
+		SubscribeParameters: opts.Parameters.ToWire(),
 	})
 }
 
@@ -772,8 +1083,10 @@ func (s *Session) RequestTrackStatus(ctx context.Context, namespace []string, tr
 
 	s.outgoingTrackStatusRequests.add(tsr)
 	tsrm := &wire.TrackStatusRequestMessage{
+		RequestID:      requestID,
 		TrackNamespace: namespace,
 		TrackName:      []byte(track),
+		Parameters:     wire.KVPList{},
 	}
 	if err := s.controlStream.write(tsrm); err != nil {
 		_, _ = s.outgoingTrackStatusRequests.delete(tsrm.RequestID)
@@ -787,12 +1100,15 @@ func (s *Session) RequestTrackStatus(ctx context.Context, namespace []string, tr
 	}
 }
 
-func (s *Session) sendTrackStatus(ts TrackStatus) error {
+func (s *Session) sendTrackStatus(requestID uint64, ts TrackStatus) error {
 	return s.controlStream.write(&wire.TrackStatusMessage{
-		StatusCode:      ts.StatusCode,
-		RequestID:       0,
-		LargestLocation: wire.Location{},
-		Parameters:      wire.KVPList{},
+		StatusCode: ts.StatusCode,
+		RequestID:  requestID,
+		LargestLocation: wire.Location{
+			Group:  ts.LastGroupID,
+			Object: ts.LastObjectID,
+		},
+		Parameters: wire.KVPList{},
 	})
 }
 
@@ -849,7 +1165,7 @@ func (s *Session) rejectAnnouncement(requestID uint64, c uint64, r string) error
 }
 
 func (s *Session) Unannounce(ctx context.Context, namespace []string) error {
-	if ok := s.outgoingAnnouncements.delete(namespace); ok {
+	if ok := s.outgoingAnnouncements.delete(namespace); !ok {
 		return errUnknownAnnouncementNamespace
 	}
 	u := &wire.UnannounceMessage{
@@ -1093,9 +1409,24 @@ func (s *Session) onSubscribe(msg *wire.SubscribeMessage) error {
 		EndGroup:           nil,
 		Parameters:         FromWire(msg.Parameters),
 	}
-	lt := newLocalTrack(s.conn, m.RequestID, m.TrackAlias, func(code, count uint64, reason string) error {
+	switch msg.FilterType {
+	case FilterTypeAbsoluteStart:
+		m.StartLocation = &msg.StartLocation
+	case FilterTypeAbsoluteRange:
+		m.StartLocation = &msg.StartLocation
+		m.EndGroup = &msg.EndGroup
+	}
+	lt := newLocalTrack(s.conn, s.scheduler, m.RequestID, m.TrackAlias, m.SubscriberPriority, GroupOrder(m.GroupOrder), func(code, count uint64, reason string) error {
 		return s.subscriptionDone(m.RequestID, code, count, reason)
 	}, s.Qlogger)
+	lt.setFilter(newSubscriptionFilter(msg.FilterType, msg.StartLocation, msg.EndGroup))
+	lt.forward.Store(msg.Forward == 1)
+	lt.namespace = m.Namespace
+	lt.track = m.Track
+
+	if timeout, ok := m.Parameters.GetDeliveryTimeout(); ok {
+		lt.subscriberDeliveryTimeout.Store(int64(timeout))
+	}
 
 	if err := s.addLocalTrack(lt); err != nil {
 		code := ErrorCodeInternal
@@ -1140,6 +1471,9 @@ func (s *Session) onSubscribeOk(msg *wire.SubscribeOkMessage) error {
 	// Store complete subscription information from SUBSCRIBE_OK
 	rt.expires = msg.Expires
 	rt.groupOrder = GroupOrder(msg.GroupOrder)
+	if rt.reorder != nil {
+		rt.reorder.setDescending(rt.groupOrder == GroupOrderDescending)
+	}
 	rt.contentExists = msg.ContentExists
 	if rt.contentExists {
 		rt.largestLocation = &msg.LargestLocation
@@ -1147,6 +1481,9 @@ func (s *Session) onSubscribeOk(msg *wire.SubscribeOkMessage) error {
 		rt.largestLocation = nil
 	}
 	rt.parameters = FromWire(msg.Parameters)
+	if timeout, ok := rt.parameters.GetDeliveryTimeout(); ok {
+		rt.publisherDeliveryTimeout.Store(int64(timeout))
+	}
 
 	select {
 	case rt.responseChan <- nil:
@@ -1178,12 +1515,18 @@ func (s *Session) onSubscribeError(msg *wire.SubscribeErrorMessage) error {
 
 func (s *Session) onSubscribeUpdate(msg *wire.SubscribeUpdateMessage) error {
 	// Find the local track for this request ID to validate it exists
-	_, ok := s.localTracks.findByID(msg.RequestID)
+	lt, ok := s.localTracks.findByID(msg.RequestID)
 	if !ok {
 		// According to draft-11, should close session with Protocol Violation
 		// if Request ID doesn't exist
 		return errUnknownRequestID
 	}
+	lt.setSubscriberPriority(msg.SubscriberPriority)
+	lt.updateFilter(msg.StartLocation, msg.EndGroup)
+	lt.forward.Store(msg.Forward == 1)
+	if timeout, ok := FromWire(msg.Parameters).GetDeliveryTimeout(); ok {
+		lt.subscriberDeliveryTimeout.Store(int64(timeout))
+	}
 
 	// Convert wire message to public message struct
 	publicMsg := &SubscribeUpdateMessage{
@@ -1221,49 +1564,214 @@ func (s *Session) onSubscribeDone(msg *wire.SubscribeDoneMessage) error {
 	if !ok {
 		return errUnknownRequestID
 	}
-	sub.done(msg.StatusCode, msg.ReasonPhrase)
+	sub.done(msg.StatusCode, msg.ReasonPhrase, msg.StreamCount)
 	// TODO: Remove subscription from outgoingSubscriptions map, but maybe only
 	// after timeout to wait for late coming objects?
 	return nil
 }
 
 func (s *Session) onFetch(msg *wire.FetchMessage) error {
-	m := &Message{
-		Method:        MessageFetch,
-		Namespace:     msg.TrackNamespace,
-		Track:         string(msg.TrackName),
-		RequestID:     msg.RequestID,
-		TrackAlias:    0,
-		Authorization: "",
-		NewSessionURI: "",
-		ErrorCode:     0,
-		ReasonPhrase:  "",
-	}
-	lt := newLocalTrack(s.conn, m.RequestID, m.TrackAlias, nil, s.Qlogger)
+	auth, err := validateAuthParameter(msg.Parameters)
+	if err != nil {
+		return err
+	}
+	m := &FetchMessage{
+		RequestID:          msg.RequestID,
+		Namespace:          msg.TrackNamespace,
+		Track:              string(msg.TrackName),
+		Authorization:      auth,
+		SubscriberPriority: msg.SubscriberPriority,
+		GroupOrder:         GroupOrder(msg.GroupOrder),
+		StartLocation:      Location{Group: msg.StartGroup, Object: msg.StartObject},
+		EndGroup:           msg.EndGroup,
+		EndObject:          msg.EndObject,
+		JoiningRequestID:   nil,
+		Parameters:         FromWire(msg.Parameters),
+	}
+	lt := newLocalTrack(s.conn, s.scheduler, m.RequestID, 0, msg.SubscriberPriority, GroupOrder(msg.GroupOrder), nil, s.Qlogger)
 	if err := s.addLocalTrack(lt); err != nil {
 		if rejectErr := s.rejectFetch(m.RequestID, ErrorCodeSubscribeInternal, ""); rejectErr != nil {
 			return rejectErr
 		}
 		return err
 	}
-	frw := &fetchResponseWriter{
-		id:         m.RequestID,
-		session:    s,
-		localTrack: lt,
-		handled:    false,
+	joining := msg.FetchType == wire.FetchTypeRelativeJoining || msg.FetchType == wire.FetchTypeAbsoluteJoining
+	if joining {
+		if rejected, err := s.resolveJoiningFetch(m, msg); rejected {
+			return err
+		}
+	}
+	end := Location{Group: m.EndGroup, Object: math.MaxUint64}
+	if m.EndObject > 0 {
+		end.Object = m.EndObject - 1
+	}
+	frw := &FetchResponseWriter{
+		id:               m.RequestID,
+		session:          s,
+		localTrack:       lt,
+		handled:          false,
+		namespace:        m.Namespace,
+		track:            m.Track,
+		start:            m.StartLocation,
+		end:              end,
+		groupOrder:       m.GroupOrder,
+		joining:          joining,
+		joiningRequestID: msg.JoiningSubscribeID,
+	}
+	if s.FetchHandler != nil {
+		s.FetchHandler.HandleFetch(frw, m)
+	} else if s.Handler != nil {
+		var rw ResponseWriter = fetchResponseWriter{frw}
+		if joining {
+			rw = joiningFetchResponseWriter{fetchResponseWriter{frw}}
+		}
+		s.Handler.Handle(rw, &Message{
+			Method:        MessageFetch,
+			Namespace:     m.Namespace,
+			Track:         m.Track,
+			RequestID:     m.RequestID,
+			TrackAlias:    0,
+			Authorization: m.Authorization,
+			NewSessionURI: "",
+			ErrorCode:     0,
+			ReasonPhrase:  "",
+			Parameters:    m.Parameters,
+		})
 	}
-	s.Handler.Handle(frw, m)
 	if !frw.handled {
 		return frw.Reject(0, "unhandled fetch")
 	}
 	return nil
 }
 
+// resolveJoiningFetch sets the track and range of a joining FETCH from the
+// largest location of the joined subscription. It returns true if the fetch
+// was rejected.
+func (s *Session) resolveJoiningFetch(m *FetchMessage, msg *wire.FetchMessage) (bool, error) {
+	joined, ok := s.localTracks.findOpen(msg.JoiningSubscribeID)
+	if !ok || joined.subscribeDone == nil {
+		return true, s.rejectFetch(m.RequestID, ErrorCodeFetchInvalidJoiningSubscribeID, "unknown joining subscription")
+	}
+	m.Namespace = joined.namespace
+	m.Track = joined.track
+	m.JoiningRequestID = &msg.JoiningSubscribeID
+	largest := joined.largest.Load()
+	if largest == nil {
+		return true, s.rejectFetch(m.RequestID, ErrorCodeFetchNoObjects, "no objects before subscription")
+	}
+	start := Location{Group: 0, Object: 0}
+	if msg.FetchType == wire.FetchTypeAbsoluteJoining {
+		start.Group = msg.JoiningStart
+	} else if msg.JoiningStart < largest.Group {
+		start.Group = largest.Group - msg.JoiningStart
+	}
+	if locationBefore(*largest, start, false) {
+		return true, s.rejectFetch(m.RequestID, ErrorCodeFetchInvalidRange, "end before start")
+	}
+	m.StartLocation = start
+	m.EndGroup = largest.Group
+	m.EndObject = largest.Object + 1
+	return false, nil
+}
+
+// fetchFromStore answers the FETCH of lt for the objects of track in
+// namespace from start to end with the objects held by s.ObjectStore. It
+// returns ErrTrackNotStored without answering the FETCH if the store does not
+// hold the track.
+func (s *Session) fetchFromStore(lt *localTrack, namespace []string, track string, start, end Location, order GroupOrder) error {
+	if s.ObjectStore == nil {
+		return ErrTrackNotStored
+	}
+	largest, ok := s.ObjectStore.Largest(namespace, track)
+	if !ok {
+		return ErrTrackNotStored
+	}
+	if locationBefore(end, start, false) {
+		return s.rejectFetch(lt.requestID, ErrorCodeFetchInvalidRange, "end before start")
+	}
+	if locationBefore(largest, end, false) {
+		end = largest
+	}
+	last, ok, err := lastStoredObject(s.ObjectStore.Objects(namespace, track, start, end, GroupOrderDescending))
+	if err != nil {
+		return errors.Join(err, s.rejectFetch(lt.requestID, ErrorCodeFetchInternal, "failed to read object store"))
+	}
+	if !ok {
+		return s.rejectFetch(lt.requestID, ErrorCodeFetchNoObjects, "no objects in range")
+	}
+	if order != GroupOrderDescending {
+		order = GroupOrderAscending
+	}
+	endLocation := objectLocation(&last)
+	endOfTrack := endLocation == largest && last.Status == ObjectStatusEndOfTrack
+	opts := &FetchOkOptions{
+		GroupOrder:  order,
+		EndOfTrack:  endOfTrack,
+		EndLocation: endLocation,
+		Parameters:  KVPList{},
+	}
+	if err := s.acceptFetchWithOptions(lt.requestID, opts); err != nil {
+		return err
+	}
+	go s.writeFetch(lt, s.ObjectStore.Objects(namespace, track, start, endLocation, order))
+	return nil
+}
+
+// lastStoredObject returns the last object of the first group yielded by
+// objects.
+func lastStoredObject(objects iter.Seq2[Object, error]) (last Object, ok bool, err error) {
+	for o, err := range objects {
+		if err != nil {
+			return Object{}, false, err
+		}
+		if ok && o.GroupID != last.GroupID {
+			break
+		}
+		last, ok = o, true
+	}
+	return last, ok, nil
+}
+
+// writeFetch writes objects to the fetch stream of lt and closes it. If
+// reading the objects fails, the stream is reset.
+func (s *Session) writeFetch(lt *localTrack, objects iter.Seq2[Object, error]) {
+	fs, err := lt.getFetchStream()
+	if err != nil {
+		s.logger.Debug("failed to open fetch stream", "request_id", lt.requestID, "error", err)
+		return
+	}
+	for o, err := range objects {
+		if err != nil {
+			s.logger.Warn("failed to read fetch object from object store", "request_id", lt.requestID, "error", err)
+			fs.Reset(ErrorCodeStreamInternal)
+			return
+		}
+		if o.Status == ObjectStatusNormal {
+			_, err = fs.WriteObjectWithExtensions(o.GroupID, o.SubGroupID, o.ObjectID, o.PublisherPriority, o.ExtensionHeaders, o.Payload)
+		} else {
+			err = fs.WriteStatus(o.GroupID, o.SubGroupID, o.ObjectID, o.PublisherPriority, o.Status)
+		}
+		if err != nil {
+			s.logger.Debug("failed to write fetch object", "request_id", lt.requestID, "error", err)
+			return
+		}
+	}
+	if err := fs.Close(); err != nil {
+		s.logger.Debug("failed to close fetch stream", "request_id", lt.requestID, "error", err)
+	}
+}
+
 func (s *Session) onFetchOk(msg *wire.FetchOkMessage) error {
 	rt, ok := s.remoteTracks.confirm(msg.RequestID)
 	if !ok {
 		return errUnknownRequestID
 	}
+
+	// Store fetch information from FETCH_OK
+	rt.groupOrder = GroupOrder(msg.GroupOrder)
+	rt.endOfTrack = msg.EndOfTrack == 1
+	rt.endLocation = &msg.EndLocation
+	rt.parameters = FromWire(msg.SubscribeParameters)
 	select {
 	case rt.responseChan <- nil:
 	default:
@@ -1302,8 +1810,9 @@ func (s *Session) onFetchCancel(msg *wire.FetchCancelMessage) error {
 
 func (s *Session) onTrackStatusRequest(msg *wire.TrackStatusRequestMessage) error {
 	tsrw := &trackStatusResponseWriter{
-		session: s,
-		handled: false,
+		requestID: msg.RequestID,
+		session:   s,
+		handled:   false,
 		status: TrackStatus{
 			Namespace:    msg.TrackNamespace,
 			Trackname:    string(msg.TrackName),
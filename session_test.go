@@ -2,9 +2,11 @@ package moqtransport
 
 import (
 	"context"
+	"errors"
 	"io"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/mengelbart/moqtransport/internal/wire"
 	"github.com/mengelbart/qlog"
@@ -41,6 +43,7 @@ func newSessionWithHandlers(conn Connection, cs controlMessageStream, h Handler,
 		highestRequestsBlocked:                   atomic.Uint64{},
 		remoteTracks:                             newRemoteTrackMap(),
 		localTracks:                              newLocalTrackMap(),
+		scheduler:                                newSendScheduler(),
 		outgoingTrackStatusRequests:              newTrackStatusRequestMap(),
 		localMaxRequestID:                        atomic.Uint64{},
 		trackAliases:                             newSequence(0, 1),
@@ -278,6 +281,159 @@ func TestSession(t *testing.T) {
 		assert.NotNil(t, rt)
 	})
 
+	t.Run("sends_fetch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveClient)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		s := newSession(conn, cs, nil)
+		s.requestIDs.max = 1
+		s.handshakeDone.Store(true)
+
+		cs.EXPECT().write(&wire.FetchMessage{
+			RequestID:          0,
+			SubscriberPriority: 7,
+			GroupOrder:         uint8(GroupOrderDescending),
+			FetchType:          wire.FetchTypeStandalone,
+			TrackNamespace:     []string{"namespace"},
+			TrackName:          []byte("track"),
+			StartGroup:         1,
+			StartObject:        2,
+			EndGroup:           3,
+			EndObject:          4,
+			JoiningSubscribeID: 0,
+			JoiningStart:       0,
+			Parameters: wire.KVPList{
+				wire.KeyValuePair{
+					Type:        wire.MaxCacheDurationParameterKey,
+					ValueVarInt: 1000,
+				},
+				wire.KeyValuePair{
+					Type:       wire.AuthorizationTokenParameterKey,
+					ValueBytes: []byte("auth"),
+				},
+			},
+		}).DoAndReturn(func(_ wire.ControlMessage) error {
+			err := s.receive(&wire.FetchOkMessage{
+				RequestID:   0,
+				GroupOrder:  uint8(GroupOrderDescending),
+				EndOfTrack:  1,
+				EndLocation: wire.Location{Group: 3, Object: 3},
+				SubscribeParameters: wire.KVPList{
+					wire.KeyValuePair{
+						Type:        wire.MaxCacheDurationParameterKey,
+						ValueVarInt: 500,
+					},
+				},
+			})
+			assert.NoError(t, err)
+			return nil
+		})
+		rt, err := s.Fetch(context.Background(), []string{"namespace"}, "track",
+			WithFetchSubscriberPriority(7),
+			WithFetchGroupOrder(GroupOrderDescending),
+			WithFetchRange(Location{Group: 1, Object: 2}, 3, 4),
+			WithFetchParameters(KVPList{KeyValuePair{Type: wire.MaxCacheDurationParameterKey, ValueVarInt: 1000}}),
+			WithFetchAuthorizationToken("auth"),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, GroupOrderDescending, rt.GroupOrder())
+		assert.True(t, rt.EndOfTrack())
+		end, ok := rt.EndLocation()
+		assert.True(t, ok)
+		assert.Equal(t, Location{Group: 3, Object: 3}, end)
+		maxCacheDuration, ok := rt.Parameters().GetMaxCacheDuration()
+		assert.True(t, ok)
+		assert.Equal(t, 500*time.Millisecond, maxCacheDuration)
+	})
+
+	t.Run("sends_fetch_ok_with_options", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveServer)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		s := newSession(conn, cs, nil)
+		s.FetchHandler = FetchHandlerFunc(func(w *FetchResponseWriter, m *FetchMessage) {
+			assert.Equal(t, uint64(1), m.RequestID)
+			assert.Equal(t, []string{"namespace"}, m.Namespace)
+			assert.Equal(t, "track", m.Track)
+			assert.Equal(t, uint8(9), m.SubscriberPriority)
+			assert.Equal(t, GroupOrderDescending, m.GroupOrder)
+			assert.Equal(t, Location{Group: 1, Object: 2}, m.StartLocation)
+			assert.Equal(t, uint64(3), m.EndGroup)
+			assert.Equal(t, uint64(0), m.EndObject)
+			assert.Nil(t, m.JoiningRequestID)
+			assert.NoError(t, w.Accept(
+				WithFetchOKGroupOrder(GroupOrderDescending),
+				WithEndOfTrack(true),
+				WithEndLocation(Location{Group: 3, Object: 7}),
+				WithFetchOKParameters(KVPList{KeyValuePair{Type: wire.MaxCacheDurationParameterKey, ValueVarInt: 100}}),
+			))
+		})
+		s.handshakeDone.Store(true)
+		cs.EXPECT().write(&wire.FetchOkMessage{
+			RequestID:   1,
+			GroupOrder:  uint8(GroupOrderDescending),
+			EndOfTrack:  1,
+			EndLocation: wire.Location{Group: 3, Object: 7},
+			SubscribeParameters: wire.KVPList{
+				wire.KeyValuePair{Type: wire.MaxCacheDurationParameterKey, ValueVarInt: 100},
+			},
+		})
+		err := s.receive(&wire.FetchMessage{
+			RequestID:          1,
+			SubscriberPriority: 9,
+			GroupOrder:         uint8(GroupOrderDescending),
+			FetchType:          wire.FetchTypeStandalone,
+			TrackNamespace:     []string{"namespace"},
+			TrackName:          []byte("track"),
+			StartGroup:         1,
+			StartObject:        2,
+			EndGroup:           3,
+			EndObject:          0,
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("passes_fetch_parameters_to_handler", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveServer)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		h := HandlerFunc(func(w ResponseWriter, m *Message) {
+			assert.Equal(t, MessageFetch, m.Method)
+			assert.Equal(t, "auth", m.Authorization)
+			assert.Len(t, m.Parameters, 1)
+			assert.NoError(t, w.Reject(ErrorCodeFetchUnauthorized, "unauthorized"))
+		})
+		s := newSession(conn, cs, h)
+		s.handshakeDone.Store(true)
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    1,
+			ErrorCode:    ErrorCodeFetchUnauthorized,
+			ReasonPhrase: "unauthorized",
+		})
+		err := s.receive(&wire.FetchMessage{
+			RequestID:      1,
+			FetchType:      wire.FetchTypeStandalone,
+			TrackNamespace: []string{"namespace"},
+			TrackName:      []byte("track"),
+			Parameters: wire.KVPList{
+				wire.KeyValuePair{
+					Type:       wire.AuthorizationTokenParameterKey,
+					ValueBytes: []byte("auth"),
+				},
+			},
+		})
+		assert.NoError(t, err)
+	})
+
 	t.Run("sends_subscribe_ok", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		cs := NewMockControlMessageStream(ctrl)
@@ -366,6 +522,47 @@ func TestSession(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("passes_subscribe_filter_to_handler", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveClient)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		sh := SubscribeHandlerFunc(func(w *SubscribeResponseWriter, m *SubscribeMessage) {
+			assert.Equal(t, FilterTypeAbsoluteRange, m.FilterType)
+			assert.Equal(t, &Location{Group: 3, Object: 4}, m.StartLocation)
+			endGroup := uint64(7)
+			assert.Equal(t, &endGroup, m.EndGroup)
+			assert.NoError(t, w.Reject(ErrorCodeSubscribeTrackDoesNotExist, "track not found"))
+		})
+
+		s := newSessionWithHandlers(conn, cs, nil, sh)
+		s.handshakeDone.Store(true)
+		cs.EXPECT().write(&wire.SubscribeErrorMessage{
+			RequestID:    0,
+			ErrorCode:    ErrorCodeSubscribeTrackDoesNotExist,
+			ReasonPhrase: "track not found",
+			TrackAlias:   0,
+		})
+		err := s.receive(&wire.SubscribeMessage{
+			RequestID:          0,
+			TrackAlias:         0,
+			TrackNamespace:     []string{},
+			TrackName:          []byte{},
+			SubscriberPriority: 0,
+			GroupOrder:         0,
+			FilterType:         FilterTypeAbsoluteRange,
+			StartLocation: wire.Location{
+				Group:  3,
+				Object: 4,
+			},
+			EndGroup:   7,
+			Parameters: wire.KVPList{},
+		})
+		assert.NoError(t, err)
+	})
+
 	t.Run("sends_announce", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		cs := NewMockControlMessageStream(ctrl)
@@ -391,6 +588,40 @@ func TestSession(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("sends_unannounce", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveClient)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		s := newSession(conn, cs, nil)
+		s.handshakeDone.Store(true)
+
+		cs.EXPECT().write(&wire.AnnounceMessage{
+			RequestID:      0,
+			TrackNamespace: []string{"namespace"},
+			Parameters:     wire.KVPList{},
+		}).DoAndReturn(func(_ wire.ControlMessage) error {
+			err := s.receive(&wire.AnnounceOkMessage{
+				RequestID: 0,
+			})
+			assert.NoError(t, err)
+			return nil
+		})
+		err := s.Announce(context.Background(), []string{"namespace"})
+		assert.NoError(t, err)
+
+		cs.EXPECT().write(&wire.UnannounceMessage{
+			TrackNamespace: []string{"namespace"},
+		})
+		err = s.Unannounce(context.Background(), []string{"namespace"})
+		assert.NoError(t, err)
+
+		err = s.Unannounce(context.Background(), []string{"namespace"})
+		assert.ErrorIs(t, err, errUnknownAnnouncementNamespace)
+	})
+
 	t.Run("sends_announce_ok", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		cs := NewMockControlMessageStream(ctrl)
@@ -474,7 +705,7 @@ func TestSession(t *testing.T) {
 				},
 			},
 		}).DoAndReturn(func(_ wire.ControlMessage) error {
-			assert.NoError(t, s.handleUniStream(mp))
+			assert.NoError(t, s.handleUniStream(NewMockReceiveStream(ctrl), mp))
 			assert.NoError(t, s.onSubscribeOk(&wire.SubscribeOkMessage{
 				RequestID:       0,
 				Expires:         0,
@@ -489,6 +720,280 @@ func TestSession(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, rt)
 	})
+	t.Run("receives_datagram_object_fields", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveClient)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+
+		s := newSession(conn, cs, nil)
+		s.handshakeDone.Store(true)
+
+		rt := newRemoteTrack(0, nil, nil)
+		assert.NoError(t, s.remoteTracks.addPendingWithAlias(0, 3, rt))
+
+		err := s.receiveDatagram(&wire.ObjectDatagramMessage{
+			TrackAlias:        3,
+			GroupID:           1,
+			ObjectID:          2,
+			PublisherPriority: 7,
+			ObjectExtensionHeaders: wire.KVPList{
+				wire.KeyValuePair{Type: 2, ValueVarInt: 9},
+			},
+			ObjectStatus: wire.ObjectStatusEndOfGroup,
+		})
+		assert.NoError(t, err)
+
+		o, err := rt.ReadObject(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, &Object{
+			GroupID:              1,
+			ObjectID:             2,
+			ForwardingPreference: ObjectForwardingPreferenceDatagram,
+			SubGroupID:           2,
+			PublisherPriority:    7,
+			Status:               ObjectStatusEndOfGroup,
+			ExtensionHeaders: KVPList{
+				KeyValuePair{Type: 2, ValueVarInt: 9},
+			},
+			Payload: nil,
+		}, o)
+	})
+}
+
+func TestSession_FetchFromObjectStore(t *testing.T) {
+	// fromStore answers FETCH requests with the stored objects, unless the
+	// track is not stored.
+	fromStore := HandlerFunc(func(w ResponseWriter, m *Message) {
+		if err := w.(FetchPublisher).AcceptFromStore(); errors.Is(err, ErrTrackNotStored) {
+			assert.NoError(t, w.Reject(ErrorCodeFetchTrackDoesNotExist, "unknown track"))
+		} else {
+			assert.NoError(t, err)
+		}
+	})
+	newStoreSession := func(t *testing.T, h Handler) (*Session, *MockControlMessageStream, *MockConnection) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveServer)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+		store := NewMemoryObjectStore(1000, 0)
+		for g := range uint64(3) {
+			assert.NoError(t, store.Put([]string{"namespace"}, "track", Object{GroupID: g, ObjectID: 0, Payload: []byte("a")}))
+		}
+		assert.NoError(t, store.Put([]string{"namespace"}, "track", Object{GroupID: 2, ObjectID: 1, Status: ObjectStatusEndOfTrack}))
+		s := newSession(conn, cs, h)
+		s.ObjectStore = store
+		s.handshakeDone.Store(true)
+		return s, cs, conn
+	}
+	fetch := func(track string, startGroup, endGroup, endObject uint64) *wire.FetchMessage {
+		return &wire.FetchMessage{
+			RequestID:      2,
+			GroupOrder:     uint8(GroupOrderDescending),
+			FetchType:      wire.FetchTypeStandalone,
+			TrackNamespace: []string{"namespace"},
+			TrackName:      []byte(track),
+			StartGroup:     startGroup,
+			EndGroup:       endGroup,
+			EndObject:      endObject,
+		}
+	}
+
+	t.Run("sends_fetch_ok", func(t *testing.T) {
+		s, cs, conn := newStoreSession(t, fromStore)
+		opened := make(chan struct{})
+		cs.EXPECT().write(&wire.FetchOkMessage{
+			RequestID:           2,
+			GroupOrder:          uint8(GroupOrderDescending),
+			EndOfTrack:          0,
+			EndLocation:         wire.Location{Group: 1, Object: 0},
+			SubscribeParameters: wire.KVPList{},
+		})
+		conn.EXPECT().OpenUniStream().DoAndReturn(func() (SendStream, error) {
+			close(opened)
+			return nil, errors.New("stream limit reached")
+		})
+		assert.NoError(t, s.receive(fetch("track", 0, 1, 0)))
+		<-opened
+	})
+
+	t.Run("sends_fetch_ok_end_of_track", func(t *testing.T) {
+		s, cs, conn := newStoreSession(t, fromStore)
+		opened := make(chan struct{})
+		cs.EXPECT().write(&wire.FetchOkMessage{
+			RequestID:           2,
+			GroupOrder:          uint8(GroupOrderDescending),
+			EndOfTrack:          1,
+			EndLocation:         wire.Location{Group: 2, Object: 1},
+			SubscribeParameters: wire.KVPList{},
+		})
+		conn.EXPECT().OpenUniStream().DoAndReturn(func() (SendStream, error) {
+			close(opened)
+			return nil, errors.New("stream limit reached")
+		})
+		assert.NoError(t, s.receive(fetch("track", 1, 5, 0)))
+		<-opened
+	})
+
+	t.Run("rejects_invalid_range", func(t *testing.T) {
+		s, cs, _ := newStoreSession(t, fromStore)
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchInvalidRange,
+			ReasonPhrase: "end before start",
+		})
+		assert.NoError(t, s.receive(fetch("track", 2, 1, 0)))
+	})
+
+	t.Run("rejects_empty_range", func(t *testing.T) {
+		s, cs, _ := newStoreSession(t, fromStore)
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchNoObjects,
+			ReasonPhrase: "no objects in range",
+		})
+		assert.NoError(t, s.receive(fetch("track", 4, 6, 0)))
+	})
+
+	t.Run("falls_back_for_unknown_track", func(t *testing.T) {
+		s, cs, _ := newStoreSession(t, fromStore)
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchTrackDoesNotExist,
+			ReasonPhrase: "unknown track",
+		})
+		assert.NoError(t, s.receive(fetch("other", 0, 1, 0)))
+	})
+
+	t.Run("handler_decides", func(t *testing.T) {
+		// Stored objects are only sent if the handler accepts the FETCH
+		// from the store.
+		s, cs, _ := newStoreSession(t, HandlerFunc(func(w ResponseWriter, m *Message) {
+			assert.NoError(t, w.Reject(ErrorCodeFetchUnauthorized, "unauthorized"))
+		}))
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchUnauthorized,
+			ReasonPhrase: "unauthorized",
+		})
+		assert.NoError(t, s.receive(fetch("track", 0, 1, 0)))
+	})
+}
+
+func TestSession_JoiningFetch(t *testing.T) {
+	newJoinedSession := func(t *testing.T, h FetchHandler, largest *Location) (*Session, *MockControlMessageStream) {
+		ctrl := gomock.NewController(t)
+		cs := NewMockControlMessageStream(ctrl)
+		conn := NewMockConnection(ctrl)
+		conn.EXPECT().Perspective().AnyTimes().Return(PerspectiveServer)
+		conn.EXPECT().Protocol().AnyTimes().Return(ProtocolQUIC)
+		s := newSession(conn, cs, nil)
+		s.FetchHandler = h
+		s.handshakeDone.Store(true)
+		lt := newLocalTrack(conn, s.scheduler, 0, 0, 0, GroupOrderAscending, func(uint64, uint64, string) error { return nil }, nil)
+		lt.namespace = []string{"namespace"}
+		lt.track = "track"
+		lt.largest.Store(largest)
+		assert.True(t, s.localTracks.addPending(lt))
+		_, ok := s.localTracks.confirm(0)
+		assert.True(t, ok)
+		return s, cs
+	}
+	joiningFetch := func(fetchType, joiningID, joiningStart uint64) *wire.FetchMessage {
+		return &wire.FetchMessage{
+			RequestID:          2,
+			FetchType:          fetchType,
+			JoiningSubscribeID: joiningID,
+			JoiningStart:       joiningStart,
+		}
+	}
+
+	t.Run("resolves_relative_range", func(t *testing.T) {
+		h := FetchHandlerFunc(func(w *FetchResponseWriter, m *FetchMessage) {
+			assert.Equal(t, []string{"namespace"}, m.Namespace)
+			assert.Equal(t, "track", m.Track)
+			assert.NotNil(t, m.JoiningRequestID)
+			assert.Equal(t, uint64(0), *m.JoiningRequestID)
+			assert.Equal(t, Location{Group: 3, Object: 0}, m.StartLocation)
+			assert.Equal(t, uint64(5), m.EndGroup)
+			assert.Equal(t, uint64(3), m.EndObject)
+			assert.NoError(t, w.Accept())
+		})
+		s, cs := newJoinedSession(t, h, &Location{Group: 5, Object: 2})
+		cs.EXPECT().write(&wire.FetchOkMessage{
+			RequestID:           2,
+			GroupOrder:          uint8(GroupOrderAscending),
+			EndOfTrack:          0,
+			EndLocation:         wire.Location{Group: 5, Object: 2},
+			SubscribeParameters: wire.KVPList{},
+		})
+		assert.NoError(t, s.receive(joiningFetch(wire.FetchTypeRelativeJoining, 0, 2)))
+	})
+
+	t.Run("accepts_requested_group_order", func(t *testing.T) {
+		h := FetchHandlerFunc(func(w *FetchResponseWriter, m *FetchMessage) {
+			assert.NoError(t, w.Accept())
+		})
+		s, cs := newJoinedSession(t, h, &Location{Group: 5, Object: 2})
+		cs.EXPECT().write(&wire.FetchOkMessage{
+			RequestID:           2,
+			GroupOrder:          uint8(GroupOrderDescending),
+			EndOfTrack:          0,
+			EndLocation:         wire.Location{Group: 5, Object: 2},
+			SubscribeParameters: wire.KVPList{},
+		})
+		msg := joiningFetch(wire.FetchTypeRelativeJoining, 0, 2)
+		msg.GroupOrder = uint8(GroupOrderDescending)
+		assert.NoError(t, s.receive(msg))
+	})
+
+	t.Run("resolves_absolute_range", func(t *testing.T) {
+		h := FetchHandlerFunc(func(w *FetchResponseWriter, m *FetchMessage) {
+			assert.Equal(t, Location{Group: 1, Object: 0}, m.StartLocation)
+			assert.Equal(t, uint64(5), m.EndGroup)
+			assert.Equal(t, uint64(3), m.EndObject)
+			assert.NoError(t, w.Reject(ErrorCodeFetchInternal, "test"))
+		})
+		s, cs := newJoinedSession(t, h, &Location{Group: 5, Object: 2})
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchInternal,
+			ReasonPhrase: "test",
+		})
+		assert.NoError(t, s.receive(joiningFetch(wire.FetchTypeAbsoluteJoining, 0, 1)))
+	})
+
+	t.Run("rejects_unknown_subscription", func(t *testing.T) {
+		s, cs := newJoinedSession(t, nil, &Location{Group: 5, Object: 2})
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchInvalidJoiningSubscribeID,
+			ReasonPhrase: "unknown joining subscription",
+		})
+		assert.NoError(t, s.receive(joiningFetch(wire.FetchTypeRelativeJoining, 4, 2)))
+	})
+
+	t.Run("rejects_subscription_without_objects", func(t *testing.T) {
+		s, cs := newJoinedSession(t, nil, nil)
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchNoObjects,
+			ReasonPhrase: "no objects before subscription",
+		})
+		assert.NoError(t, s.receive(joiningFetch(wire.FetchTypeRelativeJoining, 0, 2)))
+	})
+
+	t.Run("rejects_start_after_largest", func(t *testing.T) {
+		s, cs := newJoinedSession(t, nil, &Location{Group: 5, Object: 2})
+		cs.EXPECT().write(&wire.FetchErrorMessage{
+			RequestID:    2,
+			ErrorCode:    ErrorCodeFetchInvalidRange,
+			ReasonPhrase: "end before start",
+		})
+		assert.NoError(t, s.receive(joiningFetch(wire.FetchTypeAbsoluteJoining, 0, 6)))
+	})
 }
 
 func TestSession_UpdateSubscription(t *testing.T) {
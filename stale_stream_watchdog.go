@@ -0,0 +1,74 @@
+package moqtransport
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// staleStreamWatchdog stops an incoming subgroup stream that did not deliver
+// any objects for longer than the delivery timeout while objects of a newer
+// group arrived on the track. Objects still arriving on such a stream would be
+// stale.
+type staleStreamWatchdog struct {
+	track   *RemoteTrack
+	stream  ReceiveStream
+	groupID uint64
+	timeout time.Duration
+
+	lastActivity atomic.Int64
+
+	lock  sync.Mutex
+	timer *time.Timer
+}
+
+func newStaleStreamWatchdog(track *RemoteTrack, stream ReceiveStream, groupID uint64, timeout time.Duration) *staleStreamWatchdog {
+	w := &staleStreamWatchdog{
+		track:        track,
+		stream:       stream,
+		groupID:      groupID,
+		timeout:      timeout,
+		lastActivity: atomic.Int64{},
+		lock:         sync.Mutex{},
+		timer:        nil,
+	}
+	w.touch()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.timer = time.AfterFunc(timeout, w.check)
+	return w
+}
+
+// touch records that an object was received on the stream.
+func (w *staleStreamWatchdog) touch() {
+	if w == nil {
+		return
+	}
+	w.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (w *staleStreamWatchdog) check() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	idle := time.Since(time.Unix(0, w.lastActivity.Load()))
+	if idle >= w.timeout && w.track.largestGroupID.Load() > w.groupID {
+		w.track.logger.Info("stopping stale subgroup stream", "group_id", w.groupID, "idle", idle)
+		w.stream.Stop(uint32(ErrorCodeStreamDeliveryTimeout))
+		return
+	}
+	next := w.timeout - idle
+	if next <= 0 {
+		next = w.timeout
+	}
+	w.timer.Reset(next)
+}
+
+// stop stops watching the stream.
+func (w *staleStreamWatchdog) stop() {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.timer.Stop()
+}
@@ -1,29 +1,60 @@
 package moqtransport
 
 import (
+	"errors"
+	"time"
+
 	"github.com/mengelbart/moqtransport/internal/wire"
 	"github.com/mengelbart/qlog"
 	"github.com/mengelbart/qlog/moqt"
 )
 
+var (
+	errSubgroupExtensionsDisabled = errors.New("subgroup was opened without extension headers")
+	errObjectWriterOpen           = errors.New("subgroup has an open object writer")
+	errObjectPayloadTooLong       = errors.New("object payload exceeds declared length")
+	errObjectPayloadIncomplete    = errors.New("object payload is shorter than declared length")
+)
+
+// SubgroupOption is a functional option for configuring subgroups opened with
+// OpenSubgroup.
+type SubgroupOption func(*SubgroupOptions)
+
+// WithSubgroupExtensions sets whether objects on the subgroup can carry
+// extension headers. The stream type of the subgroup is chosen accordingly.
+// Disabling extensions saves one byte per object. Default is true.
+func WithSubgroupExtensions(enabled bool) SubgroupOption {
+	return func(opts *SubgroupOptions) {
+		opts.Extensions = enabled
+	}
+}
+
 type Subgroup struct {
 	qlogger *qlog.Logger
 
-	stream     SendStream
-	groupID    uint64
-	subgroupID uint64
+	track             *localTrack
+	stream            SendStream
+	groupID           uint64
+	subgroupID        uint64
+	publisherPriority uint8
+	extensions        bool
+
+	// writer is the object writer opened by OpenObject, if any.
+	writer *ObjectWriter
 }
 
-func newSubgroup(stream SendStream, trackAlias, groupID, subgroupID uint64, publisherPriority uint8, qlogger *qlog.Logger) (*Subgroup, error) {
+func newSubgroup(track *localTrack, stream SendStream, groupID, subgroupID uint64, publisherPriority uint8, extensions bool) (*Subgroup, error) {
+	qlogger := track.qlogger
 	shgm := &wire.SubgroupHeaderMessage{
-		TrackAlias:        trackAlias,
+		TrackAlias:        track.trackAlias,
 		GroupID:           groupID,
 		SubgroupID:        subgroupID,
 		PublisherPriority: publisherPriority,
+		HasExtensions:     extensions,
 	}
 	buf := make([]byte, 0, 40)
 	buf = shgm.Append(buf)
-	_, err := stream.Write(buf)
+	_, err := track.write(stream, publisherPriority, groupID, buf)
 	if err != nil {
 		return nil, err
 	}
@@ -35,29 +66,122 @@ func newSubgroup(stream SendStream, trackAlias, groupID, subgroupID uint64, publ
 		})
 	}
 	return &Subgroup{
-		qlogger:    qlogger,
-		stream:     stream,
-		groupID:    groupID,
-		subgroupID: subgroupID,
+		qlogger:           qlogger,
+		track:             track,
+		stream:            stream,
+		groupID:           groupID,
+		subgroupID:        subgroupID,
+		publisherPriority: publisherPriority,
+		extensions:        extensions,
+		writer:            nil,
 	}, nil
 }
 
 func (s *Subgroup) WriteObject(objectID uint64, payload []byte) (int, error) {
+	if err := s.writeObject(objectID, ObjectStatusNormal, nil, payload); err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// WriteObjectWithExtensions writes an object carrying extension headers to the
+// subgroup. It returns an error if the subgroup was opened without extensions.
+func (s *Subgroup) WriteObjectWithExtensions(objectID uint64, extensions KVPList, payload []byte) (int, error) {
+	if err := s.writeObject(objectID, ObjectStatusNormal, extensions, payload); err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// WriteStatus writes an object without payload carrying status to the
+// subgroup. Use ObjectStatusEndOfGroup to explicitly mark the end of the group
+// or ObjectStatusEndOfTrack to mark the end of the track. Applications should
+// not write any further objects to the subgroup after a status of
+// ObjectStatusEndOfGroup or ObjectStatusEndOfTrack.
+func (s *Subgroup) WriteStatus(objectID uint64, status ObjectStatus) error {
+	return s.writeObject(objectID, status, nil, nil)
+}
+
+// OpenObject starts writing an object with a payload of length bytes to the
+// subgroup. The payload is written using the returned ObjectWriter, so that it
+// doesn't have to be held in memory in full. No further objects can be written
+// to the subgroup until the ObjectWriter is closed. extensions may be nil.
+func (s *Subgroup) OpenObject(objectID, length uint64, extensions KVPList) (*ObjectWriter, error) {
+	if length == 0 {
+		if err := s.writeObject(objectID, ObjectStatusNormal, extensions, nil); err != nil {
+			return nil, err
+		}
+		return newObjectWriter(s, 0, time.Now()), nil
+	}
+	ext, err := s.wireExtensions(extensions)
+	if err != nil {
+		return nil, err
+	}
+	if s.writer != nil {
+		return nil, errObjectWriterOpen
+	}
+	o := wire.ObjectMessage{
+		ObjectID:               objectID,
+		ObjectExtensionHeaders: ext,
+	}
+	buf := o.AppendSubgroupHeader(make([]byte, 0, 24+int(ext.EncodedLength())), length)
+	start := time.Now()
+	if _, err = s.track.write(s.stream, s.publisherPriority, s.groupID, buf); err != nil {
+		return nil, err
+	}
+	s.logObject(objectID, ObjectStatusNormal, ext, length, nil)
+	s.writer = newObjectWriter(s, length, start)
+	return s.writer, nil
+}
+
+// wireExtensions returns the extension headers to encode for an object on the
+// subgroup. It is nil if the subgroup was opened without extensions.
+func (s *Subgroup) wireExtensions(extensions KVPList) (wire.KVPList, error) {
+	if !s.extensions {
+		if len(extensions) > 0 {
+			return nil, errSubgroupExtensionsDisabled
+		}
+		return nil, nil
+	}
+	ext := extensions.ToWire()
+	if ext == nil {
+		ext = wire.KVPList{}
+	}
+	return ext, nil
+}
+
+func (s *Subgroup) writeObject(objectID uint64, status ObjectStatus, extensions KVPList, payload []byte) error {
+	ext, err := s.wireExtensions(extensions)
+	if err != nil {
+		return err
+	}
+	if s.writer != nil {
+		return errObjectWriterOpen
+	}
 	var buf []byte
 	if len(payload) > 0 {
-		buf = make([]byte, 0, 16+len(payload))
+		buf = make([]byte, 0, 16+int(ext.EncodedLength())+len(payload))
 	} else {
-		buf = make([]byte, 0, 24)
+		buf = make([]byte, 0, 24+int(ext.EncodedLength()))
 	}
 	o := wire.ObjectMessage{
-		ObjectID:      objectID,
-		ObjectPayload: payload,
+		ObjectID:               objectID,
+		ObjectExtensionHeaders: ext,
+		ObjectStatus:           status,
+		ObjectPayload:          payload,
 	}
 	buf = o.AppendSubgroup(buf)
-	_, err := s.stream.Write(buf)
+	_, err = s.track.write(s.stream, s.publisherPriority, s.groupID, buf)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	s.logObject(objectID, status, ext, uint64(len(payload)), payload)
+	return nil
+}
+
+// logObject logs a created object. payload is nil if the payload is written
+// by an ObjectWriter.
+func (s *Subgroup) logObject(objectID uint64, status ObjectStatus, ext wire.KVPList, length uint64, payload []byte) {
 	if s.qlogger != nil {
 		gid := new(uint64)
 		sid := new(uint64)
@@ -69,21 +193,68 @@ func (s *Subgroup) WriteObject(objectID uint64, payload []byte) (int, error) {
 			GroupID:                gid,
 			SubgroupID:             sid,
 			ObjectID:               objectID,
-			ExtensionHeadersLength: 0,
-			ExtensionHeaders:       nil,
-			ObjectPayloadLength:    uint64(len(payload)),
-			ObjectStatus:           0,
+			ExtensionHeadersLength: ext.EncodedLength(),
+			ExtensionHeaders:       ext.QlogExtensionHeaders(),
+			ObjectPayloadLength:    length,
+			ObjectStatus:           uint64(status),
 			ObjectPayload: qlog.RawInfo{
-				Length:        uint64(len(payload)),
-				PayloadLength: uint64(len(payload)),
+				Length:        length,
+				PayloadLength: length,
 				Data:          payload,
 			},
 		})
 	}
-	return len(payload), nil
 }
 
 // Close closes the subgroup.
 func (s *Subgroup) Close() error {
 	return s.stream.Close()
 }
+
+// ObjectWriter writes the payload of an object opened with
+// Subgroup.OpenObject.
+type ObjectWriter struct {
+	subgroup  *Subgroup
+	remaining uint64
+
+	// start is the time the object was opened. The delivery timeout of the
+	// object is measured from start.
+	start time.Time
+}
+
+func newObjectWriter(subgroup *Subgroup, length uint64, start time.Time) *ObjectWriter {
+	return &ObjectWriter{
+		subgroup:  subgroup,
+		remaining: length,
+		start:     start,
+	}
+}
+
+// Write implements io.Writer. It returns an error if the payload would exceed
+// the length passed to OpenObject.
+func (w *ObjectWriter) Write(p []byte) (int, error) {
+	if uint64(len(p)) > w.remaining {
+		return 0, errObjectPayloadTooLong
+	}
+	s := w.subgroup
+	n, err := s.track.writeSince(w.start, s.stream, s.publisherPriority, s.groupID, p)
+	w.remaining -= uint64(n)
+	return n, err
+}
+
+// Close implements io.Closer. Further objects can be written to the subgroup
+// after the ObjectWriter was closed. If fewer bytes than the declared length
+// were written, the subgroup stream is reset, because the receiver cannot
+// parse any further objects from it.
+func (w *ObjectWriter) Close() error {
+	s := w.subgroup
+	if s.writer == w {
+		s.writer = nil
+	}
+	if w.remaining > 0 {
+		w.remaining = 0
+		s.stream.Reset(uint32(ErrorCodeStreamInternal))
+		return errObjectPayloadIncomplete
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package moqtransport
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type SubscribeResponseWriter struct {
 	id         uint64
@@ -85,12 +88,19 @@ func (w *SubscribeResponseWriter) Reject(code uint64, reason string) error {
 	return w.session.rejectSubscription(w.id, code, reason)
 }
 
-func (w *SubscribeResponseWriter) SendDatagram(o Object) error {
-	return w.localTrack.sendDatagram(o)
+func (w *SubscribeResponseWriter) SendDatagram(o Object, options ...DatagramOption) error {
+	return w.localTrack.sendDatagram(o, options...)
 }
 
-func (w *SubscribeResponseWriter) OpenSubgroup(groupID, subgroupID uint64, priority uint8) (*Subgroup, error) {
-	return w.localTrack.openSubgroup(groupID, subgroupID, priority)
+func (w *SubscribeResponseWriter) OpenSubgroup(groupID, subgroupID uint64, priority uint8, options ...SubgroupOption) (*Subgroup, error) {
+	return w.localTrack.openSubgroup(groupID, subgroupID, priority, options...)
+}
+
+// Context returns a context that is cancelled when the subscription ends. Its
+// cause is ErrUnsusbcribed if the peer sent UNSUBSCRIBE and
+// ErrSubscriptionDone if the subscription was closed by CloseWithError.
+func (w *SubscribeResponseWriter) Context() context.Context {
+	return w.localTrack.ctx
 }
 
 func (w *SubscribeResponseWriter) CloseWithError(code uint64, reason string) error {
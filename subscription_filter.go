@@ -0,0 +1,74 @@
+package moqtransport
+
+// subscriptionFilter selects the objects delivered on a subscription.
+type subscriptionFilter struct {
+	filterType FilterType
+	start      Location
+	hasEnd     bool
+	endGroup   uint64 // last group delivered if hasEnd is true
+}
+
+// newSubscriptionFilter creates the filter requested in a SUBSCRIBE message.
+// The start of FilterTypeLatestObject and FilterTypeNextGroupStart filters is
+// set by resolve.
+func newSubscriptionFilter(filterType FilterType, start Location, endGroup uint64) subscriptionFilter {
+	f := subscriptionFilter{
+		filterType: filterType,
+		start:      Location{Group: 0, Object: 0},
+		hasEnd:     false,
+		endGroup:   0,
+	}
+	switch filterType {
+	case FilterTypeAbsoluteStart:
+		f.start = start
+	case FilterTypeAbsoluteRange:
+		f.start = start
+		f.hasEnd = true
+		f.endGroup = endGroup
+	}
+	return f
+}
+
+// resolve sets the start of relative filters given the location of the next
+// object published on the track.
+func (f *subscriptionFilter) resolve(next Location) {
+	start := f.start
+	switch f.filterType {
+	case FilterTypeLatestObject:
+		start = next
+	case FilterTypeNextGroupStart:
+		start = next
+		if next.Object > 0 {
+			start = Location{Group: next.Group + 1, Object: 0}
+		}
+	}
+	if locationBefore(f.start, start, false) {
+		f.start = start
+	}
+}
+
+// update narrows the filter as requested by a SUBSCRIBE_UPDATE message.
+// endGroup is the last group plus one or zero for an open-ended
+// subscription.
+func (f *subscriptionFilter) update(start Location, endGroup uint64) {
+	if locationBefore(f.start, start, false) {
+		f.start = start
+	}
+	if endGroup == 0 {
+		return
+	}
+	if !f.hasEnd || endGroup-1 < f.endGroup {
+		f.hasEnd = true
+		f.endGroup = endGroup - 1
+	}
+}
+
+// includes reports whether the object at l is delivered.
+func (f subscriptionFilter) includes(l Location) bool {
+	return !locationBefore(l, f.start, false) && !f.ended(l.Group)
+}
+
+// ended reports whether the subscription ended before group.
+func (f subscriptionFilter) ended(group uint64) bool {
+	return f.hasEnd && group > f.endGroup
+}
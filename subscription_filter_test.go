@@ -0,0 +1,85 @@
+package moqtransport
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionFilter(t *testing.T) {
+	loc := func(group, object uint64) Location {
+		return Location{Group: group, Object: object}
+	}
+	cases := []struct {
+		filter   subscriptionFilter
+		next     Location
+		included []Location
+		excluded []Location
+		ended    uint64
+	}{
+		{
+			filter:   newSubscriptionFilter(FilterTypeLatestObject, loc(0, 0), 0),
+			next:     loc(3, 2),
+			included: []Location{loc(3, 2), loc(3, 3), loc(4, 0)},
+			excluded: []Location{loc(3, 1), loc(2, 5)},
+		},
+		{
+			filter:   newSubscriptionFilter(FilterTypeNextGroupStart, loc(0, 0), 0),
+			next:     loc(3, 2),
+			included: []Location{loc(4, 0), loc(5, 1)},
+			excluded: []Location{loc(3, 2), loc(3, 3)},
+		},
+		{
+			filter:   newSubscriptionFilter(FilterTypeNextGroupStart, loc(0, 0), 0),
+			next:     loc(3, 0),
+			included: []Location{loc(3, 0), loc(3, 1)},
+			excluded: []Location{loc(2, 7)},
+		},
+		{
+			filter:   newSubscriptionFilter(FilterTypeAbsoluteStart, loc(1, 1), 0),
+			next:     loc(3, 2),
+			included: []Location{loc(1, 1), loc(3, 2)},
+			excluded: []Location{loc(1, 0), loc(0, 3)},
+		},
+		{
+			filter:   newSubscriptionFilter(FilterTypeAbsoluteRange, loc(1, 0), 2),
+			next:     loc(0, 0),
+			included: []Location{loc(1, 0), loc(2, 9)},
+			excluded: []Location{loc(0, 3), loc(3, 0)},
+			ended:    3,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			tc.filter.resolve(tc.next)
+			for _, l := range tc.included {
+				assert.True(t, tc.filter.includes(l), "expected %v to be included", l)
+			}
+			for _, l := range tc.excluded {
+				assert.False(t, tc.filter.includes(l), "expected %v to be excluded", l)
+			}
+			if tc.ended > 0 {
+				assert.False(t, tc.filter.ended(tc.ended-1))
+				assert.True(t, tc.filter.ended(tc.ended))
+			}
+		})
+	}
+}
+
+func TestSubscriptionFilterUpdate(t *testing.T) {
+	f := newSubscriptionFilter(FilterTypeAbsoluteStart, Location{Group: 2, Object: 0}, 0)
+	assert.False(t, f.ended(100))
+
+	// Updates cannot widen the filter.
+	f.update(Location{Group: 1, Object: 0}, 0)
+	assert.Equal(t, Location{Group: 2, Object: 0}, f.start)
+
+	f.update(Location{Group: 3, Object: 0}, 6)
+	assert.Equal(t, Location{Group: 3, Object: 0}, f.start)
+	assert.False(t, f.ended(5))
+	assert.True(t, f.ended(6))
+
+	f.update(Location{Group: 3, Object: 0}, 10)
+	assert.True(t, f.ended(6))
+}
@@ -1,15 +1,16 @@
 package moqtransport
 
 type trackStatusResponseWriter struct {
-	session *Session
-	handled bool
-	status  TrackStatus
+	requestID uint64
+	session   *Session
+	handled   bool
+	status    TrackStatus
 }
 
 // Accept commits the status and sends a response to the peer.
 func (w *trackStatusResponseWriter) Accept() error {
 	w.handled = true
-	return w.session.sendTrackStatus(w.status)
+	return w.session.sendTrackStatus(w.requestID, w.status)
 }
 
 // Reject sends a track does not exist status
@@ -0,0 +1,742 @@
+package moqtransport
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultSubscriberQueue is the default maximum number of objects queued per
+// subscriber of a TrackWriter.
+const defaultSubscriberQueue = 64
+
+var errTrackWriterClosed = errors.New("track writer closed")
+
+// TrackWriterOption is a functional option for configuring a TrackWriter.
+type TrackWriterOption func(*TrackWriterOptions)
+
+// WithTrackPriority sets the publisher priority of the subgroups opened by the
+// TrackWriter. Default is 0.
+func WithTrackPriority(priority uint8) TrackWriterOption {
+	return func(opts *TrackWriterOptions) {
+		opts.Priority = priority
+	}
+}
+
+// WithGroupDuration makes the TrackWriter start a new group with the first
+// object written after the current group is older than d. Default is 0, which
+// disables starting groups by time.
+func WithGroupDuration(d time.Duration) TrackWriterOption {
+	return func(opts *TrackWriterOptions) {
+		opts.GroupDuration = d
+	}
+}
+
+// WithGroupSize makes the TrackWriter start a new group with the first object
+// written after the payloads of the current group reached size bytes. Default
+// is 0, which disables starting groups by size.
+func WithGroupSize(size uint64) TrackWriterOption {
+	return func(opts *TrackWriterOptions) {
+		opts.GroupSize = size
+	}
+}
+
+// WithSubscriberQueue sets the maximum number of objects queued for a
+// subscriber that cannot keep up with the track. When the queue of a
+// subscriber is full, the remaining objects of the group are not sent to the
+// subscriber. Default is 64.
+func WithSubscriberQueue(n int) TrackWriterOption {
+	return func(opts *TrackWriterOptions) {
+		opts.SubscriberQueue = n
+	}
+}
+
+// WithObjectStore records the objects written by the TrackWriter in store as
+// objects of track in namespace, so that sessions using the store can answer
+// FETCH requests for the track. Subscribers whose filter starts before the
+// next object receive the stored objects from the start of their filter. If
+// store already holds objects of the track, for example written before a
+// restart, the TrackWriter continues with the group after the largest stored
+// object. Default is to not record objects.
+func WithObjectStore(store ObjectStore, namespace []string, track string) TrackWriterOption {
+	return func(opts *TrackWriterOptions) {
+		opts.Store = store
+		opts.Namespace = namespace
+		opts.Track = track
+	}
+}
+
+// DeliveryMode determines how a TrackWriter sends objects to a subscriber.
+type DeliveryMode int
+
+const (
+	// DeliveryModeSubgroup sends each group on a subgroup stream.
+	DeliveryModeSubgroup DeliveryMode = iota
+
+	// DeliveryModeDatagram sends each object in a datagram. Objects too
+	// large for a datagram are sent on a single-object subgroup stream.
+	DeliveryModeDatagram
+)
+
+func (m DeliveryMode) String() string {
+	switch m {
+	case DeliveryModeSubgroup:
+		return "subgroup"
+	case DeliveryModeDatagram:
+		return "datagram"
+	}
+	return "unknown"
+}
+
+// TrackSubscriberOption is a functional option for configuring subscribers
+// added to a TrackWriter.
+type TrackSubscriberOption func(*TrackSubscriberOptions)
+
+// WithDeliveryMode sets how objects are sent to the subscriber. Default is
+// DeliveryModeSubgroup.
+func WithDeliveryMode(mode DeliveryMode) TrackSubscriberOption {
+	return func(opts *TrackSubscriberOptions) {
+		opts.DeliveryMode = mode
+	}
+}
+
+// A TrackWriter writes the objects of a track to its subscribers and assigns
+// group and object IDs. The application writes each object once and the
+// TrackWriter sends it to all current subscribers, which may belong to
+// different sessions. Groups are ended with an ObjectStatusEndOfGroup object
+// when a new group is started by NewGroup or by the time or size policy
+// configured with WithGroupDuration and WithGroupSize.
+//
+// Subscribers are added with Accept or AddSubscriber. Each subscriber
+// receives the objects written after it was added that match the filter of
+// its subscription, as long as the subscriber requested forwarding. If the
+// TrackWriter records objects in an ObjectStore, subscriptions with an
+// absolute start also receive the stored objects from their start. Updates
+// of the filter and forward state by SUBSCRIBE_UPDATE are applied to
+// subsequent objects. A subscription is closed with
+// SubscribeStatusSubscriptionEnded after the end group of its filter.
+//
+// Objects are queued per subscriber and sent asynchronously, so that slow
+// subscribers do not stall others. A subscriber is removed when opening a
+// subgroup for it fails, for example because the subscription ended. A
+// TrackWriter is safe for concurrent use.
+type TrackWriter struct {
+	logger *slog.Logger
+
+	priority      uint8
+	groupDuration time.Duration
+	groupSize     uint64
+	queueSize     int
+	store         ObjectStore
+	namespace     []string
+	track         string
+
+	lock        sync.Mutex
+	groupID     uint64
+	nextObject  uint64 // ID of the next object of the current group
+	groupStart  time.Time
+	groupBytes  uint64
+	largest     *Location
+	closed      bool
+	subscribers map[Publisher]*trackSubscriber
+}
+
+// NewTrackWriter creates a new TrackWriter. The first object is written with
+// group ID 0 and object ID 0, unless the ObjectStore set by WithObjectStore
+// holds objects of the track. Then the first object starts the group after
+// the largest stored object.
+//
+// Default behavior when no options are provided:
+//   - Priority: 0
+//   - GroupDuration: 0 (groups are not started by time)
+//   - GroupSize: 0 (groups are not started by size)
+//   - SubscriberQueue: 64
+//   - Store: nil (objects are not recorded)
+func NewTrackWriter(options ...TrackWriterOption) *TrackWriter {
+	opts := &TrackWriterOptions{
+		Priority:        0,
+		GroupDuration:   0,
+		GroupSize:       0,
+		SubscriberQueue: defaultSubscriberQueue,
+		Store:           nil,
+		Namespace:       nil,
+		Track:           "",
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	w := &TrackWriter{
+		logger:        defaultLogger,
+		priority:      opts.Priority,
+		groupDuration: opts.GroupDuration,
+		groupSize:     opts.GroupSize,
+		queueSize:     opts.SubscriberQueue,
+		store:         opts.Store,
+		namespace:     opts.Namespace,
+		track:         opts.Track,
+		lock:          sync.Mutex{},
+		groupID:       0,
+		nextObject:    0,
+		groupStart:    time.Time{},
+		groupBytes:    0,
+		largest:       nil,
+		closed:        false,
+		subscribers:   map[Publisher]*trackSubscriber{},
+	}
+	if w.store != nil {
+		if largest, ok := w.store.Largest(w.namespace, w.track); ok {
+			w.groupID = largest.Group + 1
+			w.largest = &largest
+		}
+	}
+	return w
+}
+
+// LargestLocation returns the location of the largest object written so far
+// or nil if no object was written.
+func (w *TrackWriter) LargestLocation() *Location {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.largestLocation()
+}
+
+func (w *TrackWriter) largestLocation() *Location {
+	if w.largest == nil {
+		return nil
+	}
+	l := *w.largest
+	return &l
+}
+
+// Status returns the status code and the largest location of the track as
+// sent in TRACK_STATUS.
+func (w *TrackWriter) Status() (statusCode, lastGroupID, lastObjectID uint64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	statusCode = TrackStatusInProgress
+	if w.closed {
+		statusCode = TrackStatusFinished
+	}
+	if w.largest == nil {
+		if !w.closed {
+			statusCode = TrackStatusNotYetBegun
+		}
+		return statusCode, 0, 0
+	}
+	return statusCode, w.largest.Group, w.largest.Object
+}
+
+// Accept accepts the subscription of rw with the largest location of the
+// track and adds rw as a subscriber with default options. Options set by the
+// caller are applied after the largest location, so that WithLargestLocation
+// overrides it.
+func (w *TrackWriter) Accept(rw *SubscribeResponseWriter, options ...SubscribeOKOption) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errTrackWriterClosed
+	}
+	options = append([]SubscribeOKOption{WithLargestLocation(w.largestLocation())}, options...)
+	if err := rw.Accept(options...); err != nil {
+		return err
+	}
+	w.addSubscriber(rw)
+	return nil
+}
+
+// AcceptTrackStatus responds to a TRACK_STATUS_REQUEST with the status of the
+// track. rw must be the ResponseWriter passed to the Handler for a message
+// with Method MessageTrackStatusRequest.
+func (w *TrackWriter) AcceptTrackStatus(rw ResponseWriter) error {
+	if h, ok := rw.(StatusRequestHandler); ok {
+		h.SetStatus(w.Status())
+	}
+	return rw.Accept()
+}
+
+// AddSubscriber adds p as a subscriber. Use AddSubscriber instead of Accept
+// for subscriptions that were already accepted or to set options for the
+// subscriber. Filters and forward state are only known for
+// SubscribeResponseWriters. Other Publishers receive all objects written
+// after they were added.
+func (w *TrackWriter) AddSubscriber(p Publisher, options ...TrackSubscriberOption) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errTrackWriterClosed
+	}
+	w.addSubscriber(p, options...)
+	return nil
+}
+
+func (w *TrackWriter) addSubscriber(p Publisher, options ...TrackSubscriberOption) {
+	opts := &TrackSubscriberOptions{
+		DeliveryMode: DeliveryModeSubgroup,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	s := newTrackSubscriber(w, p, opts.DeliveryMode)
+	if s.track != nil {
+		next := Location{
+			Group:  w.groupID,
+			Object: w.nextObject,
+		}
+		s.track.resolveFilter(next)
+		if w.store != nil && s.track.forward.Load() {
+			if start := s.track.getFilter().start; locationBefore(start, next, false) {
+				s.replay(start, next)
+			}
+		}
+	}
+	w.subscribers[p] = s
+}
+
+// RemoveSubscriber removes p from the subscribers. Objects queued for p are
+// discarded. RemoveSubscriber does not end the subscription.
+func (w *TrackWriter) RemoveSubscriber(p Publisher) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	s, ok := w.subscribers[p]
+	if !ok {
+		return
+	}
+	delete(w.subscribers, p)
+	s.remove()
+}
+
+// NewGroup ends the current group, so that the next object starts a new
+// group. Call NewGroup before writing an object that starts an independently
+// decodable unit, such as a keyframe. NewGroup does nothing if no object was
+// written to the current group.
+func (w *TrackWriter) NewGroup() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errTrackWriterClosed
+	}
+	w.endGroup(ObjectStatusEndOfGroup)
+	return nil
+}
+
+// WriteObject writes an object with payload to all subscribers and returns
+// its location. The payload is copied once and can be reused after
+// WriteObject returns.
+func (w *TrackWriter) WriteObject(payload []byte) (Location, error) {
+	return w.WriteObjectWithExtensions(nil, payload)
+}
+
+// WriteObjectWithExtensions writes an object with payload and extension
+// headers to all subscribers and returns its location.
+func (w *TrackWriter) WriteObjectWithExtensions(extensions KVPList, payload []byte) (Location, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return Location{}, errTrackWriterClosed
+	}
+	if w.groupExpired(time.Now()) {
+		w.endGroup(ObjectStatusEndOfGroup)
+	}
+	if w.nextObject == 0 {
+		w.groupStart = time.Now()
+	}
+	location := Location{
+		Group:  w.groupID,
+		Object: w.nextObject,
+	}
+	if len(w.subscribers) > 0 || w.store != nil {
+		e := trackEntry{
+			location:   location,
+			status:     ObjectStatusNormal,
+			extensions: slices.Clone(extensions),
+			payload:    slices.Clone(payload),
+		}
+		w.record(e)
+		for p, s := range w.subscribers {
+			if s.filterEnded(location.Group) {
+				delete(w.subscribers, p)
+				s.end(SubscribeStatusSubscriptionEnded, "subscription ended")
+				continue
+			}
+			s.enqueue(e)
+		}
+	}
+	w.nextObject++
+	w.groupBytes += uint64(len(payload))
+	w.largest = &location
+	return location, nil
+}
+
+// Close ends the track. Current groups are ended with an
+// ObjectStatusEndOfTrack object and all subscriptions are closed with
+// SubscribeStatusTrackEnded after the queued objects were sent.
+func (w *TrackWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return errTrackWriterClosed
+	}
+	w.endGroup(ObjectStatusEndOfTrack)
+	w.closed = true
+	for p, s := range w.subscribers {
+		delete(w.subscribers, p)
+		s.end(SubscribeStatusTrackEnded, "track ended")
+	}
+	return nil
+}
+
+// groupExpired reports whether the current group reached the duration or
+// size after which a new group is started.
+func (w *TrackWriter) groupExpired(now time.Time) bool {
+	if w.nextObject == 0 {
+		return false
+	}
+	if w.groupDuration > 0 && now.Sub(w.groupStart) >= w.groupDuration {
+		return true
+	}
+	return w.groupSize > 0 && w.groupBytes >= w.groupSize
+}
+
+// endGroup sends an object with status to the subscribers of the current
+// group and advances to the next group.
+func (w *TrackWriter) endGroup(status ObjectStatus) {
+	if w.nextObject == 0 {
+		return
+	}
+	e := trackEntry{
+		location: Location{
+			Group:  w.groupID,
+			Object: w.nextObject,
+		},
+		status:     status,
+		extensions: nil,
+		payload:    nil,
+	}
+	w.record(e)
+	for _, s := range w.subscribers {
+		s.enqueue(e)
+	}
+	w.groupID++
+	w.nextObject = 0
+	w.groupBytes = 0
+}
+
+// record stores e in the ObjectStore of the TrackWriter, if configured.
+func (w *TrackWriter) record(e trackEntry) {
+	if w.store == nil {
+		return
+	}
+	err := w.store.Put(w.namespace, w.track, Object{
+		GroupID:              e.location.Group,
+		ObjectID:             e.location.Object,
+		ForwardingPreference: ObjectForwardingPreferenceSubgroup,
+		SubGroupID:           0,
+		PublisherPriority:    w.priority,
+		Status:               e.status,
+		ExtensionHeaders:     e.extensions,
+		Payload:              e.payload,
+	})
+	if err != nil {
+		w.logger.Warn("failed to store object", "group_id", e.location.Group, "object_id", e.location.Object, "error", err)
+	}
+}
+
+// trackEntry is an object queued for a subscriber of a TrackWriter. Entries
+// are shared by all subscribers and must not be modified.
+type trackEntry struct {
+	location   Location
+	status     ObjectStatus
+	extensions KVPList
+	payload    []byte
+}
+
+// trackSubscriber queues the objects of a TrackWriter for a subscriber and
+// sends them from a goroutine that runs while objects are queued.
+type trackSubscriber struct {
+	writer    *TrackWriter
+	publisher Publisher
+	mode      DeliveryMode
+
+	// track is the subscription of the subscriber, if publisher is a
+	// SubscribeResponseWriter.
+	track *localTrack
+
+	lock    sync.Mutex
+	queue   []trackEntry
+	running bool
+	removed bool
+
+	// replaying is set if the stored objects from replayStart to before
+	// replayEnd are sent before the queue.
+	replaying   bool
+	replayStart Location
+	replayEnd   Location
+
+	// skipping is set if an object of skipGroup was dropped because the queue
+	// was full. Further objects of the group are dropped.
+	skipping  bool
+	skipGroup uint64
+
+	// ending is set when the subscription is closed with endCode and
+	// endReason after the queue was sent.
+	ending    bool
+	endCode   uint64
+	endReason string
+
+	// Fields below are only accessed by the goroutine sending the queue.
+	subgroup    *Subgroup
+	failed      bool
+	failedGroup uint64
+}
+
+func newTrackSubscriber(w *TrackWriter, p Publisher, mode DeliveryMode) *trackSubscriber {
+	var track *localTrack
+	if rw, ok := p.(*SubscribeResponseWriter); ok {
+		track = rw.localTrack
+	}
+	return &trackSubscriber{
+		writer:      w,
+		publisher:   p,
+		mode:        mode,
+		track:       track,
+		lock:        sync.Mutex{},
+		queue:       []trackEntry{},
+		running:     false,
+		removed:     false,
+		replaying:   false,
+		replayStart: Location{Group: 0, Object: 0},
+		replayEnd:   Location{Group: 0, Object: 0},
+		skipping:    false,
+		skipGroup:   0,
+		ending:      false,
+		endCode:     0,
+		endReason:   "",
+		subgroup:    nil,
+		failed:      false,
+		failedGroup: 0,
+	}
+}
+
+// filterEnded reports whether the filter of the subscription ended before
+// group.
+func (s *trackSubscriber) filterEnded(group uint64) bool {
+	return s.track != nil && s.track.getFilter().ended(group)
+}
+
+// enqueue queues e unless it is excluded by the filter or forward state of
+// the subscription or the queue is full.
+func (s *trackSubscriber) enqueue(e trackEntry) {
+	if e.status == ObjectStatusNormal && s.track != nil {
+		if !s.track.forward.Load() || !s.track.getFilter().includes(e.location) {
+			return
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.removed || s.ending {
+		return
+	}
+	if s.skipping && s.skipGroup == e.location.Group {
+		return
+	}
+	s.skipping = false
+	if s.writer.queueSize > 0 && len(s.queue) >= s.writer.queueSize {
+		s.writer.logger.Debug("subscriber queue full, skipping rest of group", "group_id", e.location.Group)
+		s.skipping = true
+		s.skipGroup = e.location.Group
+		return
+	}
+	s.queue = append(s.queue, e)
+	s.start()
+}
+
+// replay sends the stored objects from start to before end that are included
+// in the filter of the subscription before the queue. The stored objects are
+// read from the ObjectStore and sent one by one by the goroutine sending the
+// queue, so that they do not count for the queue size.
+func (s *trackSubscriber) replay(start, end Location) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.replaying = true
+	s.replayStart = start
+	s.replayEnd = end
+	s.start()
+}
+
+// end closes the subscription with code and reason after the queue was sent.
+func (s *trackSubscriber) end(code uint64, reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.removed || s.ending {
+		return
+	}
+	s.ending = true
+	s.endCode = code
+	s.endReason = reason
+	s.start()
+}
+
+// remove discards the queue without closing the subscription.
+func (s *trackSubscriber) remove() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.removed = true
+	s.queue = nil
+	s.start()
+}
+
+// start starts the goroutine sending the queue. s.lock must be held.
+func (s *trackSubscriber) start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	go s.run()
+}
+
+func (s *trackSubscriber) run() {
+	for {
+		s.lock.Lock()
+		if s.replaying && !s.removed {
+			s.replaying = false
+			start, end := s.replayStart, s.replayEnd
+			s.lock.Unlock()
+			s.sendStored(start, end)
+			continue
+		}
+		if len(s.queue) == 0 {
+			if !s.removed && !s.ending {
+				s.running = false
+				s.lock.Unlock()
+				return
+			}
+			// running stays set, because the subscriber is finished and no
+			// further goroutine must access the subgroup.
+			removed := s.removed
+			code, reason := s.endCode, s.endReason
+			s.lock.Unlock()
+			s.closeSubgroup()
+			if !removed {
+				if err := s.publisher.CloseWithError(code, reason); err != nil {
+					s.writer.logger.Debug("failed to close subscription", "error", err)
+				}
+			}
+			return
+		}
+		e := s.queue[0]
+		s.queue[0] = trackEntry{}
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+		s.send(e)
+	}
+}
+
+// sendStored sends the stored objects from start to before end. It stops
+// early if the subscriber is removed.
+func (s *trackSubscriber) sendStored(start, end Location) {
+	w := s.writer
+	objects := w.store.Objects(w.namespace, w.track, start, Location{Group: end.Group, Object: math.MaxUint64}, GroupOrderAscending)
+	for o, err := range objects {
+		if err != nil {
+			w.logger.Warn("failed to read stored objects", "error", err)
+			return
+		}
+		l := objectLocation(&o)
+		if !locationBefore(l, end, false) {
+			return
+		}
+		if o.Status == ObjectStatusNormal && !s.track.getFilter().includes(l) {
+			continue
+		}
+		s.lock.Lock()
+		removed := s.removed
+		s.lock.Unlock()
+		if removed {
+			return
+		}
+		s.send(trackEntry{
+			location:   l,
+			status:     o.Status,
+			extensions: o.ExtensionHeaders,
+			payload:    o.Payload,
+		})
+	}
+}
+
+func (s *trackSubscriber) send(e trackEntry) {
+	if s.subgroup != nil && s.subgroup.groupID != e.location.Group {
+		s.closeSubgroup()
+	}
+	if s.failed && s.failedGroup == e.location.Group {
+		return
+	}
+	s.failed = false
+	if s.mode == DeliveryModeDatagram {
+		// The ends of groups and of the track are sent as status datagrams,
+		// unless they are outside of the range of the subscription, like
+		// the end of the group before the start of a subscriber that starts
+		// at the next group.
+		if e.status != ObjectStatusNormal && !s.track.getFilter().includes(e.location) {
+			return
+		}
+		err := s.publisher.SendDatagram(Object{
+			GroupID:           e.location.Group,
+			SubGroupID:        0,
+			ObjectID:          e.location.Object,
+			PublisherPriority: s.writer.priority,
+			Status:            e.status,
+			ExtensionHeaders:  e.extensions,
+			Payload:           e.payload,
+		}, WithDatagramStreamFallback())
+		if err != nil {
+			s.fail(e.location.Group, err)
+		}
+		return
+	}
+	if e.status != ObjectStatusNormal {
+		if s.subgroup != nil {
+			if err := s.subgroup.WriteStatus(e.location.Object, e.status); err != nil {
+				s.writer.logger.Debug("failed to end group", "group_id", e.location.Group, "error", err)
+			}
+			s.closeSubgroup()
+		}
+		return
+	}
+	if s.subgroup == nil {
+		sg, err := s.publisher.OpenSubgroup(e.location.Group, 0, s.writer.priority)
+		if err != nil {
+			s.writer.logger.Debug("removing subscriber after failing to open subgroup", "group_id", e.location.Group, "error", err)
+			s.writer.RemoveSubscriber(s.publisher)
+			return
+		}
+		s.subgroup = sg
+	}
+	if _, err := s.subgroup.WriteObjectWithExtensions(e.location.Object, e.extensions, e.payload); err != nil {
+		s.closeSubgroup()
+		s.fail(e.location.Group, err)
+	}
+}
+
+// fail skips the rest of group after sending an object failed. If the
+// subscription ended, the subscriber is removed.
+func (s *trackSubscriber) fail(group uint64, err error) {
+	s.writer.logger.Debug("skipping rest of group after failed write", "group_id", group, "error", err)
+	s.failed = true
+	s.failedGroup = group
+	if s.track != nil && s.track.closed() != nil {
+		s.writer.RemoveSubscriber(s.publisher)
+	}
+}
+
+func (s *trackSubscriber) closeSubgroup() {
+	if s.subgroup == nil {
+		return
+	}
+	if err := s.subgroup.Close(); err != nil {
+		s.writer.logger.Debug("failed to close subgroup", "group_id", s.subgroup.groupID, "error", err)
+	}
+	s.subgroup = nil
+}
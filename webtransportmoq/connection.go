@@ -2,11 +2,18 @@ package webtransportmoq
 
 import (
 	"context"
+	"errors"
 
 	"github.com/mengelbart/moqtransport"
+	"github.com/quic-go/quic-go"
 	"github.com/quic-go/webtransport-go"
 )
 
+// maxQuarterStreamIDLen is the maximum length of the quarter stream ID that
+// prefixes every WebTransport datagram. The session does not expose its
+// stream ID, so the maximum varint length is assumed.
+const maxQuarterStreamIDLen = int64(8)
+
 type webTransportConn struct {
 	session     *webtransport.Session
 	perspective moqtransport.Perspective
@@ -85,7 +92,14 @@ func (c *webTransportConn) OpenUniStreamSync(ctx context.Context) (moqtransport.
 }
 
 func (c *webTransportConn) SendDatagram(b []byte) error {
-	return c.session.SendDatagram(b)
+	err := c.session.SendDatagram(b)
+	var tooLarge *quic.DatagramTooLargeError
+	if errors.As(err, &tooLarge) {
+		return &moqtransport.DatagramTooLargeError{
+			MaxDatagramSize: max(tooLarge.MaxDatagramPayloadSize-maxQuarterStreamIDLen, 0),
+		}
+	}
+	return err
 }
 
 func (c *webTransportConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
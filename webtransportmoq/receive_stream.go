@@ -1,6 +1,8 @@
 package webtransportmoq
 
 import (
+	"errors"
+
 	"github.com/mengelbart/moqtransport"
 	"github.com/quic-go/webtransport-go"
 )
@@ -13,7 +15,21 @@ type ReceiveStream struct {
 
 // Read implements moqtransport.ReceiveStream.
 func (r *ReceiveStream) Read(p []byte) (n int, err error) {
-	return r.stream.Read(p)
+	n, err = r.stream.Read(p)
+	return n, readError(err)
+}
+
+// readError converts stream resets by the peer to
+// *moqtransport.StreamResetError.
+func readError(err error) error {
+	var streamErr *webtransport.StreamError
+	if errors.As(err, &streamErr) && streamErr.Remote {
+		return &moqtransport.StreamResetError{
+			ErrorCode: uint64(streamErr.ErrorCode),
+			Err:       err,
+		}
+	}
+	return err
 }
 
 // Stop implements moqtransport.ReceiveStream.